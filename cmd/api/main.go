@@ -2,84 +2,367 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
+	"user-management-api/db"
 	database "user-management-api/db/sqlc"
 	_ "user-management-api/docs" // Swagger generated docs
+	"user-management-api/internal/auth"
 	"user-management-api/internal/config"
+	"user-management-api/internal/events"
 	"user-management-api/internal/handlers"
+	"user-management-api/internal/health"
+	"user-management-api/internal/logging"
+	"user-management-api/internal/mail"
+	"user-management-api/internal/metrics"
 	"user-management-api/internal/middleware"
+	"user-management-api/internal/ratelimit"
 	"user-management-api/internal/service"
+	"user-management-api/internal/tenant"
+	"user-management-api/internal/tracing"
 	"user-management-api/internal/validator"
+	"user-management-api/internal/webhooks"
 
 	"github.com/go-chi/chi/v5"
 	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	httpSwagger "github.com/swaggo/http-swagger"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
+// @title User Management API
+// @version 1.0
+// @description API for managing users
+// @description Errors are RFC 9457 problem details (application/problem+json) with a stable machine-readable code.
+// @description Every request acts in one organization: the org_id claim of the token, the X-Org-ID header (ID or slug) or the subdomain, otherwise the default organization.
+// @host localhost:8080
+// @BasePath /api/v1
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description Type "Bearer" followed by a space and the JWT
 func main() {
 
-	cfg, err := config.LoadConfig()
+	// "config print" shows the effective config with secrets redacted, then exits
+	args := os.Args[1:]
+	printConfig := len(args) >= 2 && args[0] == "config" && args[1] == "print"
+	if printConfig {
+		args = args[2:]
+	}
+
+	// Defaults, then the -config file, then environment variables, then flags
+	cfg, err := config.Load(args)
 	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		// Plain text - a multi-line list of problems is easier to read than a log line
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if printConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			fatal("Failed to print config", err)
+		}
+		return
 	}
 
-	pool, err := connectDB(cfg)
+	// Everything logged through slog (including slog.Default() in background jobs) uses this logger
+	logger, err := logging.New(os.Stdout, cfg.Log.Format, cfg.Log.Level)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		fatal("Failed to load config", err)
+	}
+	slog.SetDefault(logger)
+
+	// Spans of requests, UserService methods and queries go to the configured exporter
+	traceExporter, err := newTraceExporter(cfg.Tracing)
+	if err != nil {
+		fatal("Failed to set up tracing", err)
+	}
+	tracerProvider := tracing.NewProvider(traceExporter, cfg.Tracing.ServiceName)
+	tracing.SetDefault(tracerProvider)
+
+	// With row-level security every connection of the API is set to the organization of the request
+	pool, err := connectDB(cfg.Database, cfg.Database.URL(), cfg.Tenancy.RowLevelSecurity)
+	if err != nil {
+		fatal("Failed to connect to database", err)
 	}
 	defer pool.Close() // Close when main() exits - defer is used to schedule a function call to run just before the surrounding function returns
 
-	log.Println("Successfully connected to database")
+	// Background jobs work across organizations, so they get their own pool when they have their own role
+	jobsPool := pool
+	if cfg.Database.JobsUser != "" {
+		jobsPool, err = connectDB(cfg.Database, cfg.Database.JobsURL(), false)
+		if err != nil {
+			fatal("Failed to connect to database as database.jobs_user", err)
+		}
+		defer jobsPool.Close()
+	}
+	if cfg.Tenancy.RowLevelSecurity {
+		if err := checkRowLevelSecurity(pool, jobsPool); err != nil {
+			fatal("Row-level security is not set up", err)
+		}
+	}
+
+	slog.Info("Successfully connected to database")
 
 	// Initialize dependencies
 	queries := database.New(pool)
-	userService := service.NewUserService(pool, queries)
+	jobsQueries := database.New(jobsPool)
+	txOptions := service.TxOptions{
+		IsoLevel:   pgx.TxIsoLevel(cfg.Database.TxIsolation),
+		MaxRetries: cfg.Database.TxMaxRetries,
+	}
+	// Metrics are served on /metrics of the admin listener only, so they are off without ADMIN_PORT
+	registry := metrics.NewRegistry()
+	metrics.RegisterPoolStats(registry, pool)
+
+	mailer, err := newMailer(cfg.Mail)
+	if err != nil {
+		fatal("Failed to set up mail", err)
+	}
+	mailTemplates, err := mail.LoadTemplates(cfg.Mail.TemplatesDir)
+	if err != nil {
+		fatal("Failed to load mail templates", err)
+	}
+	verificationService := service.NewVerificationService(pool, queries, txOptions, mailer, mailTemplates, service.VerificationOptions{
+		URL: cfg.Mail.VerificationURL,
+		TTL: cfg.Mail.VerificationTTL,
+	})
+
+	userMetrics := metrics.NewServiceMetrics(registry, "user_service")
+	userService := service.NewUserService(pool, queries, txOptions, verificationService, userMetrics)
 	validatorInstance := validator.NewValidator()
 	userHandler := handlers.NewUserHandler(userService, validatorInstance)
+	verificationHandler := handlers.NewVerificationHandler(verificationService, validatorInstance)
+
+	verifier, err := auth.NewVerifier(&cfg.Auth)
+	if err != nil {
+		fatal("Failed to set up authentication", err)
+	}
+	issuer, err := auth.NewIssuer(&cfg.Auth)
+	if err != nil {
+		fatal("Failed to set up token issuing", err)
+	}
+
+	authService := service.NewAuthService(pool, queries, txOptions, issuer, cfg.Auth.RefreshTokenTTL)
+	authHandler := handlers.NewAuthHandler(authService, validatorInstance)
+	roleService := service.NewRoleService(pool, queries, txOptions)
+	roleHandler := handlers.NewRoleHandler(roleService, validatorInstance)
+	auditService := service.NewAuditService(queries)
+	auditHandler := handlers.NewAuditHandler(auditService, validatorInstance)
+	webhookOptions := service.WebhookOptions{
+		MaxAttempts: cfg.Webhooks.MaxAttempts,
+		BackoffBase: cfg.Webhooks.BackoffBase,
+		BackoffMax:  cfg.Webhooks.BackoffMax,
+		BatchSize:   cfg.Webhooks.BatchSize,
+		Timeout:     cfg.Webhooks.Timeout,
+	}
+	webhookSender := webhooks.NewSender(cfg.Webhooks.Timeout, cfg.Webhooks.AllowPrivateNetworks)
+	webhookService := service.NewWebhookService(queries, webhookSender, webhookOptions)
+	webhookHandler := handlers.NewWebhookHandler(webhookService, validatorInstance)
+	orgService := service.NewOrgService(queries)
+	orgHandler := handlers.NewOrgHandler(orgService, validatorInstance)
+	groupService := service.NewGroupService(pool, queries, txOptions)
+	groupHandler := handlers.NewGroupHandler(groupService, validatorInstance)
+	importOptions := service.ImportOptions{
+		BatchSize:     cfg.Import.BatchSize,
+		SyncMaxRows:   cfg.Import.SyncMaxRows,
+		MaxRows:       cfg.Import.MaxRows,
+		LeaseDuration: cfg.Import.LeaseDuration,
+		Retention:     cfg.Import.Retention,
+	}
+	importService := service.NewImportService(pool, queries, txOptions, validatorInstance, verificationService, importOptions)
+	importHandler := handlers.NewImportHandler(importService, validatorInstance, int64(cfg.Import.MaxBodySize))
+	// A key still processing after the request timeout belongs to a request that will never finish
+	idempotencyService := service.NewIdempotencyService(queries, cfg.Idempotency.KeyTTL, cfg.Server.RequestTimeout)
+
+	trustedProxies, err := middleware.ParseTrustedProxies(cfg.Server.TrustedProxies)
+	if err != nil {
+		fatal("Failed to load config", err)
+	}
+	// Postgres shares the limits between instances, memory is per instance
+	var rateLimitStore ratelimit.Store = ratelimit.NewMemoryStore()
+	var rateLimitService *service.RateLimitService
+	if cfg.RateLimit.Store == "postgres" {
+		rateLimitService = service.NewRateLimitService(pool, queries)
+		rateLimitStore = rateLimitService
+	}
+
+	// Readiness checks - /readyz fails while any of them fails
+	schemaVersion, err := db.SchemaVersion()
+	if err != nil {
+		fatal("Failed to read migrations", err)
+	}
+	checks := health.NewRegistry(cfg.Server.HealthCheckTimeout)
+	checks.Register("database", health.PingCheck(pool))
+	checks.Register("migrations", health.MigrationCheck(pool, schemaVersion))
+
+	cors, err := middleware.CORS(cfg.CORS)
+	if err != nil {
+		fatal("Invalid CORS config", err)
+	}
+
+	var swaggerURL string
+	if cfg.Features.Swagger {
+		swaggerURL = cfg.Features.SwaggerURL
+	}
 
 	// Setup router
-	router := setupRouter(userHandler)
+	router := setupRouter(routerDeps{
+		userHandler:         userHandler,
+		authHandler:         authHandler,
+		roleHandler:         roleHandler,
+		auditHandler:        auditHandler,
+		webhookHandler:      webhookHandler,
+		importHandler:       importHandler,
+		orgHandler:          orgHandler,
+		groupHandler:        groupHandler,
+		verificationHandler: verificationHandler,
+		idempotency:         idempotencyService,
+		idempotencyWait:     cfg.Idempotency.Wait,
+		requestTimeout:      cfg.Server.RequestTimeout,
+		trustedProxies:      trustedProxies,
+		cors:                cors,
+		swaggerURL:          swaggerURL,
+		rateLimitStore:      rateLimitStore,
+		authLimit:           cfg.RateLimit.Auth.Limit,
+		apiLimit:            cfg.RateLimit.API.Limit,
+		userCreateLimit:     cfg.RateLimit.UserCreate.Limit,
+		verifier:            verifier,
+		tenant:              middleware.Tenant(orgService, cfg.Tenancy),
+		permissions:         roleService,
+		healthHandler:       handlers.NewHealthHandler(checks),
+		httpMetrics:         metrics.NewHTTPMetrics(registry),
+	})
 
 	// Create HTTP server
-	server := &http.Server{
-		Addr:         fmt.Sprintf(":%s", cfg.ServerPort),
-		Handler:      router,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+	server := newServer(cfg.Server, cfg.Server.Port, router)
+
+	// Background jobs stop when jobsCtx is cancelled on shutdown, jobs tracks them so shutdown can wait for them
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	var jobs sync.WaitGroup
+
+	// The jobs below cross organizations, so their services use the jobs pool
+	if cfg.Users.PurgeInterval > 0 {
+		purgeService := service.NewUserService(jobsPool, jobsQueries, txOptions, verificationService, userMetrics)
+		jobs.Go(func() { purgeService.RunPurgeJob(jobsCtx, cfg.Users.PurgeInterval, cfg.Users.DeletedRetention) })
+	}
+
+	// The relay publishes the domain events UserService writes to the outbox
+	if cfg.Events.PollInterval > 0 {
+		publisher, err := newEventPublisher(cfg.Events)
+		if err != nil {
+			fatal("Failed to set up event publishing", err)
+		}
+		relay := service.NewOutboxRelay(jobsPool, jobsQueries, publisher, cfg.Events.BatchSize)
+		jobs.Go(func() { relay.Run(jobsCtx, cfg.Events.PollInterval, cfg.Events.Retention) })
+	}
+
+	// The mail relay sends the verification mails of created and imported users
+	if cfg.Mail.PollInterval > 0 {
+		mailRelay := service.NewMailRelay(jobsPool, jobsQueries, mailer, service.MailRelayOptions{
+			BatchSize:   cfg.Mail.BatchSize,
+			MaxAttempts: cfg.Mail.MaxAttempts,
+			BackoffBase: cfg.Mail.BackoffBase,
+			BackoffMax:  cfg.Mail.BackoffMax,
+		})
+		jobs.Go(func() { mailRelay.Run(jobsCtx, cfg.Mail.PollInterval) })
+	}
+
+	if cfg.Webhooks.PollInterval > 0 {
+		dispatcher := service.NewWebhookService(jobsQueries, webhookSender, webhookOptions)
+		jobs.Go(func() { dispatcher.RunDispatcher(jobsCtx, cfg.Webhooks.PollInterval) })
+	}
+
+	if cfg.Import.PollInterval > 0 {
+		importWorker := service.NewImportService(jobsPool, jobsQueries, txOptions, validatorInstance, verificationService, importOptions)
+		jobs.Go(func() { importWorker.RunWorker(jobsCtx, cfg.Import.PollInterval) })
+	}
+
+	jobs.Go(func() { idempotencyService.RunCleanup(jobsCtx, time.Hour) })
+
+	if rateLimitService != nil {
+		jobs.Go(func() { rateLimitService.RunCleanup(jobsCtx, time.Minute) })
 	}
 
 	// Start server in a goroutine - non blocking manner
 	go func() {
-		log.Printf("Server starting on port %s", cfg.ServerPort)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Server failed to start: %v", err)
+		tls := cfg.Server.TLS
+		slog.Info("Server starting", "port", cfg.Server.Port, "tls", tls.Enabled())
+
+		if tls.Enabled() {
+			err = server.ListenAndServeTLS(tls.CertFile, tls.KeyFile)
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			fatal("Server failed to start", err)
 		}
 	}()
 
+	servers := []*http.Server{server}
+	if cfg.Server.AdminPort != "" {
+		adminServer := newServer(cfg.Server, cfg.Server.AdminPort, setupAdminRouter(metrics.Handler(registry)))
+		servers = append(servers, adminServer)
+
+		go func() {
+			slog.Info("Admin server starting", "port", cfg.Server.AdminPort)
+			if err := adminServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				fatal("Admin server failed to start", err)
+			}
+		}()
+	} else {
+		slog.Warn("ADMIN_PORT is not set, /metrics is not served")
+	}
+
 	// Graceful shutdown
-	gracefulShutdown(server)
+	gracefulShutdown(checks, cfg.Server.ShutdownDrainDelay, cfg.Server.ShutdownTimeout, servers...)
+	// The jobs still write spans and use the pools until they returned
+	stopJobs()
+	jobs.Wait()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := tracerProvider.Shutdown(ctx); err != nil {
+		slog.Warn("Flushing spans failed", "error", err)
+	}
 }
 
-func connectDB(cfg *config.Config) (*pgxpool.Pool, error) {
-	poolConfig, err := pgxpool.ParseConfig(cfg.GetDatabaseURL())
+// connectDB creates a pool connecting to url - tenantScoped sets app.org_id on every connection it hands out,
+// see tenant.PrepareConn
+func connectDB(cfg config.DatabaseConfig, url string, tenantScoped bool) (*pgxpool.Pool, error) {
+	poolConfig, err := pgxpool.ParseConfig(url)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse database config: %w", err)
 	}
 
-	poolConfig.MaxConns = 25
-	poolConfig.MinConns = 5
-	poolConfig.MaxConnLifetime = 5 * time.Minute
+	poolConfig.MaxConns = int32(cfg.MaxConns)
+	poolConfig.MinConns = int32(cfg.MinConns)
+	poolConfig.MaxConnLifetime = cfg.MaxConnLifetime
+	poolConfig.MaxConnIdleTime = cfg.MaxConnIdleTime
+	poolConfig.ConnConfig.ConnectTimeout = cfg.ConnectTimeout
+	poolConfig.ConnConfig.Tracer = tracing.NewPgxTracer() // a span per query
+	if tenantScoped {
+		poolConfig.PrepareConn = tenant.PrepareConn
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ConnectTimeout)
 	defer cancel()
 
 	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
@@ -94,59 +377,307 @@ func connectDB(cfg *config.Config) (*pgxpool.Pool, error) {
 	return pool, nil
 }
 
-func setupRouter(userHandler *handlers.UserHandler) *chi.Mux {
+// checkRowLevelSecurity makes sure the policies apply to the connections of the API and not to those of the jobs
+func checkRowLevelSecurity(pool, jobsPool *pgxpool.Pool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var active bool
+	if err := pool.QueryRow(ctx, "SELECT row_security_active('users')").Scan(&active); err != nil {
+		return err
+	}
+	if !active {
+		return errors.New("the policies don't apply to database.user, it must not be a superuser, have BYPASSRLS or own the tables")
+	}
+
+	if err := jobsPool.QueryRow(ctx, "SELECT row_security_active('users')").Scan(&active); err != nil {
+		return err
+	}
+	if active {
+		return errors.New("the policies apply to database.jobs_user, it needs BYPASSRLS")
+	}
+
+	return nil
+}
+
+// newServer creates an HTTP server on port with the configured timeouts
+func newServer(cfg config.ServerConfig, port string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:         fmt.Sprintf(":%s", port),
+		Handler:      handler,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		IdleTimeout:  cfg.IdleTimeout,
+	}
+}
+
+// newEventPublisher creates the publisher selected by events.publisher
+func newEventPublisher(cfg config.EventsConfig) (events.Publisher, error) {
+	if cfg.Publisher == "file" {
+		return events.NewFilePublisher(cfg.File)
+	}
+	return events.NewLogPublisher(), nil
+}
+
+// newMailer creates the mailer selected by mail.mailer
+func newMailer(cfg config.MailConfig) (mail.Mailer, error) {
+	switch cfg.Mailer {
+	case "file":
+		return mail.NewFileMailer(cfg.File)
+	case "smtp":
+		return mail.NewSMTPMailer(mail.SMTPOptions{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.From,
+			Timeout:  cfg.Timeout,
+		})
+	}
+	return mail.NewLogMailer(), nil
+}
+
+// newTraceExporter creates the exporter selected by tracing.exporter, nil for "none"
+func newTraceExporter(cfg config.TracingConfig) (sdktrace.SpanExporter, error) {
+	switch cfg.Exporter {
+	case "stdout":
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case "otlp":
+		// The exporter only connects when it sends the first batch
+		return otlptracehttp.New(context.Background(),
+			otlptracehttp.WithEndpointURL(strings.TrimSuffix(cfg.OTLPEndpoint, "/")+"/v1/traces"))
+	}
+	return nil, nil
+}
+
+// routerDeps holds everything the routes need
+type routerDeps struct {
+	userHandler         *handlers.UserHandler
+	authHandler         *handlers.AuthHandler
+	roleHandler         *handlers.RoleHandler
+	auditHandler        *handlers.AuditHandler
+	webhookHandler      *handlers.WebhookHandler
+	importHandler       *handlers.ImportHandler
+	orgHandler          *handlers.OrgHandler
+	groupHandler        *handlers.GroupHandler
+	verificationHandler *handlers.VerificationHandler
+	healthHandler       *handlers.HealthHandler
+	idempotency         middleware.IdempotencyStore
+	idempotencyWait     time.Duration
+	requestTimeout      time.Duration
+	trustedProxies      []*net.IPNet
+	cors                func(http.Handler) http.Handler
+	swaggerURL          string // "" when the docs are off
+	rateLimitStore      ratelimit.Store
+	authLimit           *ratelimit.Limit // nil when rate limiting of the group is off
+	apiLimit            *ratelimit.Limit
+	userCreateLimit     *ratelimit.Limit
+	verifier            *auth.Verifier
+	tenant              func(http.Handler) http.Handler // resolves the organization, see config.TenancyConfig
+	permissions         middleware.PermissionLoader
+	httpMetrics         *metrics.HTTPMetrics
+}
+
+func setupRouter(deps routerDeps) *chi.Mux {
+	userHandler := deps.userHandler
+	authHandler := deps.authHandler
+	roleHandler := deps.roleHandler
+	auditHandler := deps.auditHandler
+	webhookHandler := deps.webhookHandler
+	importHandler := deps.importHandler
+	orgHandler := deps.orgHandler
+	groupHandler := deps.groupHandler
+	verificationHandler := deps.verificationHandler
+
 	// Create new Chi router
 	r := chi.NewRouter()
 
 	// Global middleware (applies to all routes)
-	r.Use(chimiddleware.RequestID)                 // Adds request ID for tracing
-	r.Use(middleware.Logger)                       // custom logger
-	r.Use(middleware.Recovery)                     // Recover from panics
-	r.Use(middleware.CORS)                         // CORS headers
-	r.Use(middleware.ContentTypeJSON)              // Set JSON content type
-	r.Use(chimiddleware.Timeout(60 * time.Second)) // Request timeout
-
-	// Health check endpoint
+	r.Use(chimiddleware.RequestID)                // Adds request ID for tracing
+	r.Use(middleware.RealIP(deps.trustedProxies)) // Client IP from X-Forwarded-For of trusted proxies
+	r.Use(middleware.RequestInfo)                 // Request ID, IP and user agent for the audit log
+	r.Use(middleware.Tracing)                     // Server span per request, W3C traceparent propagation
+	r.Use(middleware.Logger)                      // Structured request log, request scoped logger on the context
+	r.Use(middleware.Metrics(deps.httpMetrics))   // Request count and latency by route
+	r.Use(middleware.Recovery)                    // Recover from panics
+	r.Use(deps.cors)                              // CORS policies, answers preflight requests
+	r.Use(middleware.ContentTypeJSON)             // Set JSON content type
+
+	// Request timeout - exports stream for as long as the client stays connected
+	r.Use(middleware.Timeout(deps.requestTimeout, "/api/v1/users/export"))
+
+	// Unknown routes and methods get problem responses like every other error
+	r.NotFound(handlers.NotFound)
+	r.MethodNotAllowed(handlers.MethodNotAllowed)
+
+	// Probes - /livez restarts a stuck process, /readyz takes the instance out of the load balancer
+	r.Get("/livez", deps.healthHandler.Livez)
+	r.Get("/readyz", deps.healthHandler.Readyz)
+
+	// Health check endpoint - kept for existing monitors, it only says the process is up (like /livez)
 	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte(`{"status":"healthy"}`))
 	})
 
 	// Swagger documentation
-	r.Get("/docs/*", httpSwagger.Handler(
-		httpSwagger.URL("http://localhost:8080/docs/doc.json"),
-	))
+	if deps.swaggerURL != "" {
+		r.Get("/docs/*", httpSwagger.Handler(
+			httpSwagger.URL(deps.swaggerURL),
+		))
+	}
+
+	// rateLimit limits a route group, a nil limit (configured as "off") lets everything through
+	rateLimit := func(group string, limit *ratelimit.Limit) func(http.Handler) http.Handler {
+		if limit == nil {
+			return func(next http.Handler) http.Handler { return next }
+		}
+		return middleware.RateLimit(deps.rateLimitStore, group, *limit)
+	}
 
 	// API routes under /api/v1
 	r.Route("/api/v1", func(r chi.Router) {
-		// User routes
-		r.Route("/users", func(r chi.Router) {
-			r.Post("/", userHandler.CreateUser)       // POST /api/v1/users
-			r.Get("/", userHandler.ListUsers)         // GET /api/v1/users
-			r.Get("/{id}", userHandler.GetUser)       // GET /api/v1/users/{id}
-			r.Patch("/{id}", userHandler.UpdateUser)  // PATCH /api/v1/users/{id}
-			r.Delete("/{id}", userHandler.DeleteUser) // DELETE /api/v1/users/{id}
+		// Auth routes - public, these are how clients get a token
+		r.Route("/auth", func(r chi.Router) {
+			r.Use(rateLimit("auth", deps.authLimit))
+			r.Use(deps.tenant) // users log in to one organization
+
+			r.Post("/login", authHandler.Login)     // POST /api/v1/auth/login
+			r.Post("/refresh", authHandler.Refresh) // POST /api/v1/auth/refresh
+			r.Post("/logout", authHandler.Logout)   // POST /api/v1/auth/logout
+
+			r.Post("/verify-email", verificationHandler.VerifyEmail) // POST /api/v1/auth/verify-email
+		})
+
+		// Everything else needs a valid JWT, and a permission per route
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.Authenticate(deps.verifier))
+			r.Use(rateLimit("api", deps.apiLimit)) // before LoadPermissions, limited requests cost no query
+			r.Use(deps.tenant)                     // after Authenticate, the token's organization wins
+			r.Use(middleware.LoadPermissions(deps.permissions))
+
+			// Shorthands for the permission checks
+			can := middleware.RequirePermission
+			canOrSelf := func(permission string) func(http.Handler) http.Handler {
+				return middleware.RequirePermissionOrSelf(permission, "id")
+			}
+			// Retries with the same Idempotency-Key get the first response instead of running again
+			idempotent := middleware.Idempotency(deps.idempotency, deps.idempotencyWait)
+			// Creating users has a tighter limit on top of the api one
+			limitCreate := rateLimit("user_create", deps.userCreateLimit)
+
+			// User routes - users can always read and edit themselves
+			r.Route("/users", func(r chi.Router) {
+				r.With(can(auth.PermUsersWrite), limitCreate, idempotent).Post("/", userHandler.CreateUser) // POST /api/v1/users
+				r.With(can(auth.PermUsersRead)).Get("/", userHandler.ListUsers)                             // GET /api/v1/users
+				r.With(can(auth.PermUsersRead)).Get("/search", userHandler.SearchUsers)                     // GET /api/v1/users/search
+				r.With(can(auth.PermUsersRead)).Get("/export", userHandler.ExportUsers)                     // GET /api/v1/users/export
+
+				// Bulk import, large files run as jobs
+				r.With(can(auth.PermUsersWrite), limitCreate).Post("/import", importHandler.ImportUsers) // POST /api/v1/users/import
+				r.With(can(auth.PermUsersWrite)).Get("/import/{jobId}", importHandler.GetImportJob)      // GET /api/v1/users/import/{jobId}
+
+				r.With(canOrSelf(auth.PermUsersRead)).Get("/{id}", userHandler.GetUser)          // GET /api/v1/users/{id}
+				r.With(canOrSelf(auth.PermUsersWrite)).Patch("/{id}", userHandler.UpdateUser)    // PATCH /api/v1/users/{id}
+				r.With(can(auth.PermUsersDelete)).Delete("/{id}", userHandler.DeleteUser)        // DELETE /api/v1/users/{id}
+				r.With(can(auth.PermUsersDelete)).Post("/{id}/restore", userHandler.RestoreUser) // POST /api/v1/users/{id}/restore
+
+				r.With(canOrSelf(auth.PermUsersWrite)).Post("/{id}/verification", verificationHandler.ResendVerification) // POST /api/v1/users/{id}/verification
+
+				// Role assignment
+				r.With(canOrSelf(auth.PermRolesAssign)).Get("/{id}/roles", roleHandler.GetUserRoles)   // GET /api/v1/users/{id}/roles
+				r.With(can(auth.PermRolesAssign)).Post("/{id}/roles", roleHandler.AssignRole)          // POST /api/v1/users/{id}/roles
+				r.With(can(auth.PermRolesAssign)).Delete("/{id}/roles/{role}", roleHandler.RemoveRole) // DELETE /api/v1/users/{id}/roles/{role}
+
+				r.With(can(auth.PermAuditRead)).Get("/{id}/audit", auditHandler.ListUserAuditEvents) // GET /api/v1/users/{id}/audit
+
+				r.With(canOrSelf(auth.PermUsersRead)).Get("/{id}/groups", groupHandler.ListUserGroups) // GET /api/v1/users/{id}/groups
+			})
+
+			r.With(can(auth.PermRolesAssign)).Get("/roles", roleHandler.ListRoles)      // GET /api/v1/roles
+			r.With(can(auth.PermAuditRead)).Get("/audit", auditHandler.ListAuditEvents) // GET /api/v1/audit
+
+			// Webhook routes - all of them need webhooks:manage
+			r.Route("/webhooks", func(r chi.Router) {
+				r.Use(can(auth.PermWebhooksManage))
+
+				r.Post("/", webhookHandler.CreateWebhook)                                     // POST /api/v1/webhooks
+				r.Get("/", webhookHandler.ListWebhooks)                                       // GET /api/v1/webhooks
+				r.Get("/{id}", webhookHandler.GetWebhook)                                     // GET /api/v1/webhooks/{id}
+				r.Patch("/{id}", webhookHandler.UpdateWebhook)                                // PATCH /api/v1/webhooks/{id}
+				r.Delete("/{id}", webhookHandler.DeleteWebhook)                               // DELETE /api/v1/webhooks/{id}
+				r.Get("/{id}/deliveries", webhookHandler.ListDeliveries)                      // GET /api/v1/webhooks/{id}/deliveries
+				r.Post("/{id}/deliveries/{deliveryId}/replay", webhookHandler.ReplayDelivery) // POST /api/v1/webhooks/{id}/deliveries/{deliveryId}/replay
+			})
+
+			// Group routes - changing members is also open to the owners of a group, the service checks that
+			r.Route("/groups", func(r chi.Router) {
+				r.With(can(auth.PermGroupsManage)).Post("/", groupHandler.CreateGroup)         // POST /api/v1/groups
+				r.With(can(auth.PermUsersRead)).Get("/", groupHandler.ListGroups)              // GET /api/v1/groups
+				r.With(can(auth.PermUsersRead)).Get("/{id}", groupHandler.GetGroup)            // GET /api/v1/groups/{id}
+				r.With(can(auth.PermGroupsManage)).Patch("/{id}", groupHandler.UpdateGroup)    // PATCH /api/v1/groups/{id}
+				r.With(can(auth.PermGroupsManage)).Delete("/{id}", groupHandler.DeleteGroup)   // DELETE /api/v1/groups/{id}
+				r.With(can(auth.PermUsersRead)).Get("/{id}/members", groupHandler.ListMembers) // GET /api/v1/groups/{id}/members
+				r.Post("/{id}/members", groupHandler.AddMembers)                               // POST /api/v1/groups/{id}/members
+				r.Post("/{id}/members/remove", groupHandler.RemoveMembers)                     // POST /api/v1/groups/{id}/members/remove
+				r.Delete("/{id}/members/{userId}", groupHandler.RemoveMember)                  // DELETE /api/v1/groups/{id}/members/{userId}
+			})
+
+			// Organization routes - all of them need orgs:manage, which only platform_admin grants
+			r.Route("/orgs", func(r chi.Router) {
+				r.Use(can(auth.PermOrgsManage))
+
+				r.Post("/", orgHandler.CreateOrganization)       // POST /api/v1/orgs
+				r.Get("/", orgHandler.ListOrganizations)         // GET /api/v1/orgs
+				r.Get("/{id}", orgHandler.GetOrganization)       // GET /api/v1/orgs/{id}
+				r.Patch("/{id}", orgHandler.UpdateOrganization)  // PATCH /api/v1/orgs/{id}
+				r.Delete("/{id}", orgHandler.DeleteOrganization) // DELETE /api/v1/orgs/{id}
+			})
 		})
 	})
 
 	return r
 }
 
+// fatal logs err and exits - the slog replacement for log.Fatalf
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// setupAdminRouter builds the router of the admin listener - keep it off the public network
+func setupAdminRouter(metricsHandler http.Handler) *chi.Mux {
+	r := chi.NewRouter()
+	r.Use(middleware.Recovery)
+
+	r.Get("/metrics", metricsHandler.ServeHTTP)
+
+	return r
+}
+
 // gracefulShutdown handles graceful shutdown on SIGINT/SIGTERM
-func gracefulShutdown(server *http.Server) {
+// /readyz fails right away, and the servers keep serving for drainDelay so load balancers
+// stop sending traffic before the listeners close
+func gracefulShutdown(checks *health.Registry, drainDelay, timeout time.Duration, servers ...*http.Server) {
 	quit := make(chan os.Signal, 1)
 
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
 	<-quit
-	log.Println("Shutting down server...")
+	checks.StartDraining()
+	slog.Info("Draining before shutdown...", "delay", drainDelay.String())
+	time.Sleep(drainDelay)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	slog.Info("Shutting down server...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	if err := server.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+	for _, server := range servers {
+		if err := server.Shutdown(ctx); err != nil {
+			fatal("Server forced to shutdown", err)
+		}
 	}
 
-	log.Println("Server stopped gracefully")
+	slog.Info("Server stopped gracefully")
 }
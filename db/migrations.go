@@ -0,0 +1,41 @@
+package db
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+// migrations are embedded so the binary knows which schema version it was built for
+//
+//go:embed migrations/*.up.sql
+var migrations embed.FS
+
+// SchemaVersion returns the version of the newest migration ("000011_add_rate_limits.up.sql" is 11)
+// The database must be migrated to at least this version before the API can serve requests.
+func SchemaVersion() (int64, error) {
+	files, err := fs.Glob(migrations, "migrations/*.up.sql")
+	if err != nil {
+		return 0, err
+	}
+
+	var latest int64
+	for _, file := range files {
+		name := strings.TrimPrefix(file, "migrations/")
+		prefix, _, ok := strings.Cut(name, "_")
+		if !ok {
+			return 0, fmt.Errorf("migration %s has no version prefix", name)
+		}
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("migration %s has an invalid version: %w", name, err)
+		}
+		if version > latest {
+			latest = version
+		}
+	}
+
+	return latest, nil
+}
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_users_updated_at_user_id;
+
+DROP INDEX IF EXISTS idx_users_created_at_user_id;
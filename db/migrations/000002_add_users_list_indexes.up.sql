@@ -0,0 +1,5 @@
+-- keyset pagination index for the default listing order (created_at DESC, user_id DESC)
+CREATE INDEX idx_users_created_at_user_id ON users(created_at DESC, user_id DESC);
+
+-- keyset pagination index when sorting by last update
+CREATE INDEX idx_users_updated_at_user_id ON users(updated_at DESC, user_id DESC);
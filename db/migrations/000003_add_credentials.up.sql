@@ -0,0 +1,21 @@
+-- bcrypt hash of the user's password, NULL for users that can't sign in yet
+ALTER TABLE users ADD COLUMN password_hash TEXT;
+
+-- refresh tokens, rotated on every use
+-- all tokens issued from one login share a family_id, so a reused token can revoke the whole chain
+CREATE TABLE refresh_tokens (
+    token_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    family_id UUID NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE, -- SHA-256 of the token, the token itself is never stored
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE, -- set when the token is exchanged for a new one
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- index on family_id for revoking a whole family
+CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens(family_id);
+
+-- index on user_id for the foreign key
+CREATE INDEX idx_refresh_tokens_user_id ON refresh_tokens(user_id);
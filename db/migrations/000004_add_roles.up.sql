@@ -0,0 +1,57 @@
+-- roles that can be assigned to users
+CREATE TABLE roles (
+    role_name VARCHAR(50) PRIMARY KEY,
+    description TEXT
+);
+
+-- permissions checked by the API, named <resource>:<action>
+CREATE TABLE permissions (
+    permission_name VARCHAR(100) PRIMARY KEY,
+    description TEXT
+);
+
+-- which permissions each role grants
+CREATE TABLE role_permissions (
+    role_name VARCHAR(50) NOT NULL REFERENCES roles(role_name) ON DELETE CASCADE,
+    permission_name VARCHAR(100) NOT NULL REFERENCES permissions(permission_name) ON DELETE CASCADE,
+    PRIMARY KEY (role_name, permission_name)
+);
+
+-- roles assigned to each user
+CREATE TABLE user_roles (
+    user_id UUID NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    role_name VARCHAR(50) NOT NULL REFERENCES roles(role_name) ON DELETE CASCADE,
+    assigned_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, role_name)
+);
+
+-- index on role_name for "who has this role" lookups
+CREATE INDEX idx_user_roles_role_name ON user_roles(role_name);
+
+INSERT INTO roles (role_name, description) VALUES
+    ('admin', 'Full access, including role assignment'),
+    ('manager', 'Can read and edit all users'),
+    ('member', 'Can read and edit their own profile');
+
+INSERT INTO permissions (permission_name, description) VALUES
+    ('users:read', 'Read any user'),
+    ('users:write', 'Create and update any user, including their status'),
+    ('users:delete', 'Delete any user'),
+    ('roles:assign', 'List roles and assign them to users');
+
+INSERT INTO role_permissions (role_name, permission_name) VALUES
+    ('admin', 'users:read'),
+    ('admin', 'users:write'),
+    ('admin', 'users:delete'),
+    ('admin', 'roles:assign'),
+    ('manager', 'users:read'),
+    ('manager', 'users:write');
+
+-- members get no permissions: they only reach their own record through the self rules in the API
+
+-- the first admin has to be assigned by hand, for example:
+-- INSERT INTO user_roles (user_id, role_name) SELECT user_id, 'admin' FROM users WHERE email = 'admin@example.com';
+
+-- existing users become members
+INSERT INTO user_roles (user_id, role_name)
+SELECT user_id, 'member' FROM users;
@@ -0,0 +1,9 @@
+-- soft delete: deleted users keep their row until the purge job removes them
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+
+-- emails only have to be unique among users that aren't deleted
+ALTER TABLE users DROP CONSTRAINT users_email_key;
+CREATE UNIQUE INDEX idx_users_email_not_deleted ON users(email) WHERE deleted_at IS NULL;
+
+-- index on deleted_at for the purge job
+CREATE INDEX idx_users_deleted_at ON users(deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,2 @@
+-- version for optimistic concurrency, bumped on every change and sent to clients as the ETag
+ALTER TABLE users ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
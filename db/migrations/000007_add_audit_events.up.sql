@@ -0,0 +1,28 @@
+-- audit trail of every change to a user, written in the same transaction as the change
+CREATE TABLE audit_events (
+    event_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    occurred_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    actor_id TEXT, -- subject of the caller's token, NULL for changes made by the system
+    action VARCHAR(50) NOT NULL, -- user.created, user.updated, user.deleted, user.restored
+    resource_type VARCHAR(50) NOT NULL,
+    resource_id UUID NOT NULL, -- no foreign key: events outlive purged users
+    request_id TEXT,
+    ip_address TEXT,
+    user_agent TEXT,
+    changes JSONB NOT NULL DEFAULT '{}' -- {"field": {"old": ..., "new": ...}}
+);
+
+-- index for the history of one resource, newest first
+CREATE INDEX idx_audit_events_resource ON audit_events(resource_id, occurred_at DESC, event_id DESC);
+
+-- index for listing all events, newest first
+CREATE INDEX idx_audit_events_occurred_at ON audit_events(occurred_at DESC, event_id DESC);
+
+-- index for filtering by actor
+CREATE INDEX idx_audit_events_actor_id ON audit_events(actor_id, occurred_at DESC);
+
+INSERT INTO permissions (permission_name, description) VALUES
+    ('audit:read', 'Read the audit log');
+
+INSERT INTO role_permissions (role_name, permission_name) VALUES
+    ('admin', 'audit:read');
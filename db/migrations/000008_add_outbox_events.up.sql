@@ -0,0 +1,18 @@
+-- transactional outbox: domain events are written in the same transaction as the change
+-- and published afterwards by the relay, so an event exists if and only if the change was committed
+CREATE TABLE outbox_events (
+    event_id UUID PRIMARY KEY,
+    sequence_num BIGSERIAL NOT NULL, -- publish order, events of one user are always published in this order
+    aggregate_type VARCHAR(50) NOT NULL,
+    aggregate_id UUID NOT NULL, -- user ID for user events
+    event_type VARCHAR(50) NOT NULL, -- UserCreated, UserUpdated, UserStatusChanged, UserDeleted, UserRestored
+    schema_version INT NOT NULL,
+    payload JSONB NOT NULL, -- the full event as published
+    occurred_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    published_at TIMESTAMP WITH TIME ZONE, -- NULL until the relay has published it
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT
+);
+
+-- index for the relay, which only reads unpublished events
+CREATE INDEX idx_outbox_events_pending ON outbox_events(sequence_num) WHERE published_at IS NULL;
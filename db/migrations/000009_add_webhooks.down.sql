@@ -0,0 +1,4 @@
+DELETE FROM permissions WHERE permission_name = 'webhooks:manage';
+
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhook_subscriptions;
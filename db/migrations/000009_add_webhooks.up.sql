@@ -0,0 +1,39 @@
+-- webhook subscriptions: partners get an HTTP callback for every matching user event
+CREATE TABLE webhook_subscriptions (
+    subscription_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL, -- HMAC-SHA256 key, stored as is because deliveries are signed with it
+    event_types TEXT[] NOT NULL DEFAULT '{}', -- empty means every event type
+    description TEXT,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- one row per event per subscription, kept as the delivery log
+CREATE TABLE webhook_deliveries (
+    delivery_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    subscription_id UUID NOT NULL REFERENCES webhook_subscriptions(subscription_id) ON DELETE CASCADE,
+    event_id UUID NOT NULL,
+    event_type VARCHAR(50) NOT NULL,
+    payload JSONB NOT NULL, -- the request body, the same event envelope the outbox publishes
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'succeeded', 'dead')),
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_attempt_at TIMESTAMP WITH TIME ZONE,
+    last_status_code INT,
+    last_error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- index for the dispatcher, which only reads pending deliveries that are due
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';
+
+-- index for the delivery log of a subscription, newest first
+CREATE INDEX idx_webhook_deliveries_subscription ON webhook_deliveries(subscription_id, created_at DESC, delivery_id DESC);
+
+INSERT INTO permissions (permission_name, description) VALUES
+    ('webhooks:manage', 'Manage webhook subscriptions and their deliveries');
+
+INSERT INTO role_permissions (role_name, permission_name) VALUES
+    ('admin', 'webhooks:manage');
@@ -0,0 +1,16 @@
+-- responses of requests sent with an Idempotency-Key header, replayed when the client retries
+CREATE TABLE idempotency_keys (
+    caller TEXT NOT NULL, -- subject of the caller's token, keys of different callers never clash
+    idempotency_key TEXT NOT NULL,
+    request_hash TEXT NOT NULL, -- sha256 of method, path and body, a retry must send the same request
+    status VARCHAR(20) NOT NULL DEFAULT 'processing' CHECK (status IN ('processing', 'completed')),
+    response_status INT,
+    response_headers JSONB,
+    response_body BYTEA,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (caller, idempotency_key)
+);
+
+-- index for the cleanup of expired keys
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
@@ -0,0 +1,5 @@
+-- rate limiter state shared by all instances, one row per client and route group
+CREATE UNLOGGED TABLE rate_limits (
+    limit_key TEXT PRIMARY KEY,
+    tat TIMESTAMP WITH TIME ZONE NOT NULL -- GCRA theoretical arrival time, rows in the past can be deleted
+);
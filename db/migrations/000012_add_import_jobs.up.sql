@@ -0,0 +1,41 @@
+-- bulk user imports: imports too large to run during the request are queued here for the import worker
+CREATE TABLE import_jobs (
+    job_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'completed', 'failed')),
+    format VARCHAR(10) NOT NULL CHECK (format IN ('csv', 'ndjson')),
+    dry_run BOOLEAN NOT NULL DEFAULT FALSE,
+    on_conflict VARCHAR(10) NOT NULL CHECK (on_conflict IN ('skip', 'update', 'fail')),
+    column_mapping JSONB NOT NULL DEFAULT '{}', -- CSV header -> field, overriding the header names
+    payload BYTEA, -- the uploaded file, cleared once the job has finished
+    total_rows INT NOT NULL DEFAULT 0,
+    processed_rows INT NOT NULL DEFAULT 0, -- rows before this are committed, a job picked up again resumes here
+    created_count INT NOT NULL DEFAULT 0,
+    updated_count INT NOT NULL DEFAULT 0,
+    skipped_count INT NOT NULL DEFAULT 0,
+    failed_count INT NOT NULL DEFAULT 0,
+    error TEXT, -- why the job failed as a whole
+    created_by TEXT, -- subject of the caller, the actor of the audit events the job writes
+    request_id TEXT,
+    lease_until TIMESTAMP WITH TIME ZONE, -- a running job belongs to its worker until then
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMP WITH TIME ZONE,
+    finished_at TIMESTAMP WITH TIME ZONE
+);
+
+-- index for the worker, which only reads unfinished jobs
+CREATE INDEX idx_import_jobs_queue ON import_jobs(created_at) WHERE status IN ('pending', 'running');
+
+-- index for the cleanup of finished jobs
+CREATE INDEX idx_import_jobs_finished_at ON import_jobs(finished_at) WHERE finished_at IS NOT NULL;
+
+-- the per-row report of a job
+CREATE TABLE import_job_rows (
+    job_id UUID NOT NULL REFERENCES import_jobs(job_id) ON DELETE CASCADE,
+    line INT NOT NULL, -- line number in the uploaded file
+    email TEXT,
+    status VARCHAR(20) NOT NULL,
+    user_id UUID,
+    message TEXT,
+    invalid_params JSONB, -- [{"name": ..., "reason": ...}] for rows that failed validation
+    PRIMARY KEY (job_id, line)
+);
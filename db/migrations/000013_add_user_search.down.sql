@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_users_phone_trgm;
+DROP INDEX IF EXISTS idx_users_email_trgm;
+DROP INDEX IF EXISTS idx_users_last_name_trgm;
+DROP INDEX IF EXISTS idx_users_first_name_trgm;
+
+DROP INDEX IF EXISTS idx_users_search;
+
+-- the extension is left installed, other objects may depend on it
@@ -0,0 +1,14 @@
+-- trigram matching for typo tolerant search ("jon smth")
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- full-text search over every searchable field
+-- SearchUsers must use exactly this expression, otherwise the index is not used
+CREATE INDEX idx_users_search ON users USING GIN (
+    to_tsvector('simple', first_name || ' ' || last_name || ' ' || email || ' ' || COALESCE(phone, ''))
+) WHERE deleted_at IS NULL;
+
+-- trigram indexes for the fuzzy matches of SearchUsers
+CREATE INDEX idx_users_first_name_trgm ON users USING GIN (first_name gin_trgm_ops) WHERE deleted_at IS NULL;
+CREATE INDEX idx_users_last_name_trgm ON users USING GIN (last_name gin_trgm_ops) WHERE deleted_at IS NULL;
+CREATE INDEX idx_users_email_trgm ON users USING GIN (email gin_trgm_ops) WHERE deleted_at IS NULL;
+CREATE INDEX idx_users_phone_trgm ON users USING GIN (phone gin_trgm_ops) WHERE deleted_at IS NULL;
@@ -0,0 +1,16 @@
+DELETE FROM roles WHERE role_name = 'platform_admin';
+DELETE FROM permissions WHERE permission_name = 'orgs:manage';
+
+DROP POLICY IF EXISTS users_org_isolation ON users;
+ALTER TABLE users NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE users DISABLE ROW LEVEL SECURITY;
+
+ALTER TABLE import_jobs DROP COLUMN IF EXISTS org_id;
+
+-- fails when the same email is used in two organizations, those users have to be merged or renamed first
+DROP INDEX IF EXISTS idx_users_org_email_not_deleted;
+CREATE UNIQUE INDEX idx_users_email_not_deleted ON users(email) WHERE deleted_at IS NULL;
+
+ALTER TABLE users DROP COLUMN IF EXISTS org_id;
+
+DROP TABLE IF EXISTS organizations;
@@ -0,0 +1,46 @@
+-- organizations are the tenants of the service, every user belongs to exactly one
+CREATE TABLE organizations (
+    org_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    slug VARCHAR(63) NOT NULL UNIQUE, -- the subdomain of the organization, also accepted in the X-Org-ID header
+    name VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- existing users move into the default organization, which requests naming no organization use
+INSERT INTO organizations (org_id, slug, name) VALUES
+    ('00000000-0000-0000-0000-000000000001', 'default', 'Default');
+
+-- an organization can't be deleted while it has users, soft deleted ones included
+ALTER TABLE users ADD COLUMN org_id UUID REFERENCES organizations(org_id);
+UPDATE users SET org_id = '00000000-0000-0000-0000-000000000001';
+ALTER TABLE users ALTER COLUMN org_id SET NOT NULL;
+
+-- emails only have to be unique within an organization
+DROP INDEX IF EXISTS idx_users_email_not_deleted;
+CREATE UNIQUE INDEX idx_users_org_email_not_deleted ON users(org_id, email) WHERE deleted_at IS NULL;
+
+-- imports run in the organization of the caller that started them
+ALTER TABLE import_jobs ADD COLUMN org_id UUID REFERENCES organizations(org_id) ON DELETE CASCADE;
+UPDATE import_jobs SET org_id = '00000000-0000-0000-0000-000000000001';
+ALTER TABLE import_jobs ALTER COLUMN org_id SET NOT NULL;
+
+-- row-level security: with app.org_id set (SET LOCAL by the API when tenancy.row_level_security is on)
+-- only the users of that organization are visible, without it the policy lets everything through,
+-- so background jobs and tools that don't set it keep working
+-- FORCE applies the policy to the owner of the table too, superusers still bypass it
+ALTER TABLE users ENABLE ROW LEVEL SECURITY;
+ALTER TABLE users FORCE ROW LEVEL SECURITY;
+CREATE POLICY users_org_isolation ON users
+    USING (COALESCE(current_setting('app.org_id', true), '') = '' OR org_id = current_setting('app.org_id', true)::uuid)
+    WITH CHECK (COALESCE(current_setting('app.org_id', true), '') = '' OR org_id = current_setting('app.org_id', true)::uuid);
+
+INSERT INTO permissions (permission_name, description) VALUES
+    ('orgs:manage', 'Create, update and delete organizations');
+
+-- managing organizations crosses tenants, so it is not part of admin - assign platform_admin by hand
+INSERT INTO roles (role_name, description) VALUES
+    ('platform_admin', 'Manages the organizations of the service');
+
+INSERT INTO role_permissions (role_name, permission_name) VALUES
+    ('platform_admin', 'orgs:manage');
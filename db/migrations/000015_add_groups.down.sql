@@ -0,0 +1,4 @@
+DELETE FROM permissions WHERE permission_name = 'groups:manage';
+
+DROP TABLE IF EXISTS group_memberships;
+DROP TABLE IF EXISTS groups;
@@ -0,0 +1,33 @@
+-- groups (teams) of users within an organization
+CREATE TABLE groups (
+    group_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    org_id UUID NOT NULL REFERENCES organizations(org_id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (org_id, name)
+);
+
+-- members of each group - owners can manage the members of their group without groups:manage
+-- deleting a user removes their memberships (soft deletes in the API, hard deletes through the cascade)
+CREATE TABLE group_memberships (
+    group_id UUID NOT NULL REFERENCES groups(group_id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL DEFAULT 'member' CHECK (role IN ('owner', 'member')),
+    joined_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (group_id, user_id)
+);
+
+-- index for the member list, which is paginated on (joined_at, user_id)
+CREATE INDEX idx_group_memberships_joined_at ON group_memberships(group_id, joined_at, user_id);
+
+-- index for "which groups is this user in"
+CREATE INDEX idx_group_memberships_user_id ON group_memberships(user_id);
+
+INSERT INTO permissions (permission_name, description) VALUES
+    ('groups:manage', 'Create, update and delete groups and manage the members of any group');
+
+INSERT INTO role_permissions (role_name, permission_name) VALUES
+    ('admin', 'groups:manage'),
+    ('manager', 'groups:manage');
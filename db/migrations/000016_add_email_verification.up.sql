@@ -0,0 +1,19 @@
+-- when the user confirmed their current email address, NULL until they do (and again after an email change)
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP WITH TIME ZONE;
+
+-- single-use tokens mailed to confirm an address, deleted when one of them is used or a new one is sent
+CREATE TABLE email_verification_tokens (
+    token_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    org_id UUID NOT NULL REFERENCES organizations(org_id) ON DELETE CASCADE, -- the organization of the user, so the link works without naming it
+    email VARCHAR(255) NOT NULL, -- the address the token confirms
+    token_hash TEXT NOT NULL UNIQUE, -- SHA-256 of the token, the token itself is never stored
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- index on user_id for the foreign key and for replacing a user's tokens
+CREATE INDEX idx_email_verification_tokens_user_id ON email_verification_tokens(user_id);
+
+-- index on expires_at for the cleanup of expired tokens
+CREATE INDEX idx_email_verification_tokens_expires_at ON email_verification_tokens(expires_at);
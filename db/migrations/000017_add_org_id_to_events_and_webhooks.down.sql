@@ -0,0 +1,14 @@
+DROP INDEX IF EXISTS idx_webhook_deliveries_subscription;
+CREATE INDEX idx_webhook_deliveries_subscription ON webhook_deliveries(subscription_id, created_at DESC, delivery_id DESC);
+ALTER TABLE webhook_deliveries DROP COLUMN IF EXISTS org_id;
+
+DROP INDEX IF EXISTS idx_webhook_subscriptions_org_id;
+ALTER TABLE webhook_subscriptions DROP COLUMN IF EXISTS org_id;
+
+ALTER TABLE outbox_events DROP COLUMN IF EXISTS org_id;
+
+DROP INDEX IF EXISTS idx_audit_events_org_actor_id;
+DROP INDEX IF EXISTS idx_audit_events_org_occurred_at;
+CREATE INDEX idx_audit_events_occurred_at ON audit_events(occurred_at DESC, event_id DESC);
+CREATE INDEX idx_audit_events_actor_id ON audit_events(actor_id, occurred_at DESC);
+ALTER TABLE audit_events DROP COLUMN IF EXISTS org_id;
@@ -0,0 +1,40 @@
+-- audit events, the outbox and webhooks belong to an organization like the users they are about
+-- existing rows move into the organization of their user, rows of purged users into the default organization
+
+ALTER TABLE audit_events ADD COLUMN org_id UUID REFERENCES organizations(org_id) ON DELETE CASCADE;
+UPDATE audit_events a SET org_id = COALESCE(
+    (SELECT u.org_id FROM users u WHERE u.user_id = a.resource_id),
+    '00000000-0000-0000-0000-000000000001'
+);
+ALTER TABLE audit_events ALTER COLUMN org_id SET NOT NULL;
+
+-- the audit log is only ever listed within one organization
+DROP INDEX IF EXISTS idx_audit_events_occurred_at;
+DROP INDEX IF EXISTS idx_audit_events_actor_id;
+CREATE INDEX idx_audit_events_org_occurred_at ON audit_events(org_id, occurred_at DESC, event_id DESC);
+CREATE INDEX idx_audit_events_org_actor_id ON audit_events(org_id, actor_id, occurred_at DESC);
+
+ALTER TABLE outbox_events ADD COLUMN org_id UUID REFERENCES organizations(org_id) ON DELETE CASCADE;
+UPDATE outbox_events o SET org_id = COALESCE(
+    (SELECT u.org_id FROM users u WHERE u.user_id = o.aggregate_id),
+    '00000000-0000-0000-0000-000000000001'
+);
+ALTER TABLE outbox_events ALTER COLUMN org_id SET NOT NULL;
+
+-- subscriptions only get the events of their own organization
+ALTER TABLE webhook_subscriptions ADD COLUMN org_id UUID REFERENCES organizations(org_id) ON DELETE CASCADE;
+UPDATE webhook_subscriptions SET org_id = '00000000-0000-0000-0000-000000000001';
+ALTER TABLE webhook_subscriptions ALTER COLUMN org_id SET NOT NULL;
+
+-- index for listing the subscriptions of an organization and for the fan-out of its events
+CREATE INDEX idx_webhook_subscriptions_org_id ON webhook_subscriptions(org_id, created_at, subscription_id);
+
+ALTER TABLE webhook_deliveries ADD COLUMN org_id UUID REFERENCES organizations(org_id) ON DELETE CASCADE;
+UPDATE webhook_deliveries d SET org_id = s.org_id
+FROM webhook_subscriptions s
+WHERE s.subscription_id = d.subscription_id;
+ALTER TABLE webhook_deliveries ALTER COLUMN org_id SET NOT NULL;
+
+-- the delivery log is read per subscription, so the organization leads the existing index
+DROP INDEX IF EXISTS idx_webhook_deliveries_subscription;
+CREATE INDEX idx_webhook_deliveries_subscription ON webhook_deliveries(org_id, subscription_id, created_at DESC, delivery_id DESC);
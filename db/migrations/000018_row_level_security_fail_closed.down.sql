@@ -0,0 +1,23 @@
+DROP POLICY IF EXISTS groups_org_isolation ON groups;
+ALTER TABLE groups DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS import_jobs_org_isolation ON import_jobs;
+ALTER TABLE import_jobs DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS webhook_deliveries_org_isolation ON webhook_deliveries;
+ALTER TABLE webhook_deliveries DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS webhook_subscriptions_org_isolation ON webhook_subscriptions;
+ALTER TABLE webhook_subscriptions DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS outbox_events_org_isolation ON outbox_events;
+ALTER TABLE outbox_events DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS audit_events_org_isolation ON audit_events;
+ALTER TABLE audit_events DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS users_org_isolation ON users;
+ALTER TABLE users FORCE ROW LEVEL SECURITY;
+CREATE POLICY users_org_isolation ON users
+    USING (COALESCE(current_setting('app.org_id', true), '') = '' OR org_id = current_setting('app.org_id', true)::uuid)
+    WITH CHECK (COALESCE(current_setting('app.org_id', true), '') = '' OR org_id = current_setting('app.org_id', true)::uuid);
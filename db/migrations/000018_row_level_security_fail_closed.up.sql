@@ -0,0 +1,45 @@
+-- row-level security fails closed: without app.org_id no row of an organization is visible or writable
+-- The API sets app.org_id on every connection it takes from its pool (tenancy.row_level_security), background
+-- jobs that cross organizations connect as a role with BYPASSRLS (database.jobs_user) instead of relying on
+-- the setting being missing. The policies don't apply to the owner of the tables (NO FORCE), so the role
+-- running the migrations and deployments without tenancy.row_level_security keep seeing every row - run the
+-- API as a role that doesn't own the tables for the policies to have an effect.
+DROP POLICY IF EXISTS users_org_isolation ON users;
+ALTER TABLE users NO FORCE ROW LEVEL SECURITY;
+CREATE POLICY users_org_isolation ON users
+    USING (org_id = NULLIF(current_setting('app.org_id', true), '')::uuid)
+    WITH CHECK (org_id = NULLIF(current_setting('app.org_id', true), '')::uuid);
+
+-- the other tables holding data of one organization get the same policy
+ALTER TABLE audit_events ENABLE ROW LEVEL SECURITY;
+CREATE POLICY audit_events_org_isolation ON audit_events
+    USING (org_id = NULLIF(current_setting('app.org_id', true), '')::uuid)
+    WITH CHECK (org_id = NULLIF(current_setting('app.org_id', true), '')::uuid);
+
+ALTER TABLE outbox_events ENABLE ROW LEVEL SECURITY;
+CREATE POLICY outbox_events_org_isolation ON outbox_events
+    USING (org_id = NULLIF(current_setting('app.org_id', true), '')::uuid)
+    WITH CHECK (org_id = NULLIF(current_setting('app.org_id', true), '')::uuid);
+
+ALTER TABLE webhook_subscriptions ENABLE ROW LEVEL SECURITY;
+CREATE POLICY webhook_subscriptions_org_isolation ON webhook_subscriptions
+    USING (org_id = NULLIF(current_setting('app.org_id', true), '')::uuid)
+    WITH CHECK (org_id = NULLIF(current_setting('app.org_id', true), '')::uuid);
+
+ALTER TABLE webhook_deliveries ENABLE ROW LEVEL SECURITY;
+CREATE POLICY webhook_deliveries_org_isolation ON webhook_deliveries
+    USING (org_id = NULLIF(current_setting('app.org_id', true), '')::uuid)
+    WITH CHECK (org_id = NULLIF(current_setting('app.org_id', true), '')::uuid);
+
+ALTER TABLE import_jobs ENABLE ROW LEVEL SECURITY;
+CREATE POLICY import_jobs_org_isolation ON import_jobs
+    USING (org_id = NULLIF(current_setting('app.org_id', true), '')::uuid)
+    WITH CHECK (org_id = NULLIF(current_setting('app.org_id', true), '')::uuid);
+
+ALTER TABLE groups ENABLE ROW LEVEL SECURITY;
+CREATE POLICY groups_org_isolation ON groups
+    USING (org_id = NULLIF(current_setting('app.org_id', true), '')::uuid)
+    WITH CHECK (org_id = NULLIF(current_setting('app.org_id', true), '')::uuid);
+
+-- email_verification_tokens stays without a policy: a token is looked up by its hash before the organization
+-- is known, the organization comes from the token
@@ -0,0 +1,29 @@
+-- mail outbox: verification mails are written in the transaction that issued their token and sent by the
+-- mail relay afterwards, so a mail exists if and only if its token was committed
+-- A row holds the rendered message, the token in the link included, and is deleted once the mail is sent.
+CREATE TABLE mail_outbox (
+    mail_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    org_id UUID NOT NULL REFERENCES organizations(org_id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(user_id) ON DELETE CASCADE, -- the recipient, a new token replaces their queued mails
+    recipient VARCHAR(255) NOT NULL,
+    subject TEXT NOT NULL,
+    text_body TEXT NOT NULL,
+    html_body TEXT NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- index for the relay, which sends the mails that are due
+CREATE INDEX idx_mail_outbox_next_attempt_at ON mail_outbox(next_attempt_at);
+
+-- index on user_id for the foreign key and for replacing a user's queued mails
+CREATE INDEX idx_mail_outbox_user_id ON mail_outbox(user_id);
+
+-- the same row-level security as the other tables holding data of one organization, the relay connects as
+-- database.jobs_user
+ALTER TABLE mail_outbox ENABLE ROW LEVEL SECURITY;
+CREATE POLICY mail_outbox_org_isolation ON mail_outbox
+    USING (org_id = NULLIF(current_setting('app.org_id', true), '')::uuid)
+    WITH CHECK (org_id = NULLIF(current_setting('app.org_id', true), '')::uuid);
@@ -0,0 +1,3 @@
+ALTER TABLE users
+    ALTER COLUMN created_at DROP NOT NULL,
+    ALTER COLUMN updated_at DROP NOT NULL;
@@ -0,0 +1,11 @@
+-- created_at and updated_at are sort keys of the keyset pagination, (created_at, user_id) < (...) never
+-- matches a NULL, so rows without them would drop out of every page
+-- Backfill first: a missing created_at takes updated_at and the other way round, the current time if both are missing
+UPDATE users
+SET created_at = COALESCE(created_at, updated_at, CURRENT_TIMESTAMP),
+    updated_at = COALESCE(updated_at, created_at, CURRENT_TIMESTAMP)
+WHERE created_at IS NULL OR updated_at IS NULL;
+
+ALTER TABLE users
+    ALTER COLUMN created_at SET NOT NULL,
+    ALTER COLUMN updated_at SET NOT NULL;
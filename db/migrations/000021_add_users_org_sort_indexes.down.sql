@@ -0,0 +1,8 @@
+CREATE INDEX idx_users_created_at_user_id ON users(created_at DESC, user_id DESC);
+CREATE INDEX idx_users_updated_at_user_id ON users(updated_at DESC, user_id DESC);
+
+DROP INDEX IF EXISTS idx_users_org_email_user_id;
+DROP INDEX IF EXISTS idx_users_org_last_name_user_id;
+DROP INDEX IF EXISTS idx_users_org_first_name_user_id;
+DROP INDEX IF EXISTS idx_users_org_updated_at_user_id;
+DROP INDEX IF EXISTS idx_users_org_created_at_user_id;
@@ -0,0 +1,11 @@
+-- keyset pagination indexes for every sort field of the user list, which always filters on org_id
+-- a btree reads both ways, so each index serves the ascending and the descending sort, user_id breaking ties
+CREATE INDEX idx_users_org_created_at_user_id ON users(org_id, created_at, user_id);
+CREATE INDEX idx_users_org_updated_at_user_id ON users(org_id, updated_at, user_id);
+CREATE INDEX idx_users_org_first_name_user_id ON users(org_id, first_name, user_id);
+CREATE INDEX idx_users_org_last_name_user_id ON users(org_id, last_name, user_id);
+CREATE INDEX idx_users_org_email_user_id ON users(org_id, email, user_id);
+
+-- replaced by the indexes above, they don't start with org_id
+DROP INDEX IF EXISTS idx_users_created_at_user_id;
+DROP INDEX IF EXISTS idx_users_updated_at_user_id;
@@ -0,0 +1,31 @@
+-- name: CreateAuditEvent :exec
+-- Records a change to a resource
+INSERT INTO audit_events (
+    org_id,
+    actor_id,
+    action,
+    resource_type,
+    resource_id,
+    request_id,
+    ip_address,
+    user_agent,
+    changes
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9
+);
+
+-- name: ListAuditEvents :many
+-- Retrieves a page of the audit events of an organization, newest first, with optional filters
+-- The cursor is the (occurred_at, event_id) of the last event of the previous page
+SELECT * FROM audit_events
+WHERE org_id = sqlc.arg('org_id')
+  AND (sqlc.narg('resource_id')::uuid IS NULL OR resource_id = sqlc.narg('resource_id')::uuid)
+  AND (sqlc.narg('actor_id')::text IS NULL OR actor_id = sqlc.narg('actor_id')::text)
+  AND (sqlc.narg('from_time')::timestamptz IS NULL OR occurred_at >= sqlc.narg('from_time')::timestamptz)
+  AND (sqlc.narg('to_time')::timestamptz IS NULL OR occurred_at < sqlc.narg('to_time')::timestamptz)
+  AND (
+    sqlc.narg('cursor_occurred_at')::timestamptz IS NULL
+    OR (occurred_at, event_id) < (sqlc.narg('cursor_occurred_at')::timestamptz, sqlc.narg('cursor_event_id')::uuid)
+  )
+ORDER BY occurred_at DESC, event_id DESC
+LIMIT sqlc.arg('page_limit');
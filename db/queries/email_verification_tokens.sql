@@ -0,0 +1,32 @@
+-- name: CreateEmailVerificationToken :one
+-- Stores a newly mailed verification token (only its hash)
+INSERT INTO email_verification_tokens (
+    user_id,
+    org_id,
+    email,
+    token_hash,
+    expires_at
+) VALUES (
+    $1, $2, $3, $4, $5
+)
+RETURNING *;
+
+-- name: GetEmailVerificationTokenByHash :one
+-- Retrieves a verification token by the hash of the token value, expired or not
+SELECT * FROM email_verification_tokens
+WHERE token_hash = $1;
+
+-- name: ConsumeEmailVerificationToken :execrows
+-- Deletes a token as it is used, affects 0 rows if a concurrent request used it first
+DELETE FROM email_verification_tokens
+WHERE token_id = $1;
+
+-- name: DeleteUserEmailVerificationTokens :execrows
+-- Invalidates every outstanding token of a user, e.g. when a new one is sent or the email changes
+DELETE FROM email_verification_tokens
+WHERE user_id = $1;
+
+-- name: DeleteExpiredEmailVerificationTokens :execrows
+-- Removes tokens that expired before the cutoff, in every organization
+DELETE FROM email_verification_tokens
+WHERE expires_at < $1;
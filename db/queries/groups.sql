@@ -0,0 +1,98 @@
+-- Groups belong to an organization, every query is scoped to one (org_id)
+
+-- name: CreateGroup :one
+INSERT INTO groups (
+    org_id,
+    name,
+    description
+) VALUES (
+    $1, $2, $3
+)
+RETURNING *;
+
+-- name: GetGroup :one
+SELECT * FROM groups
+WHERE org_id = $1
+  AND group_id = $2;
+
+-- name: ListGroups :many
+SELECT * FROM groups
+WHERE org_id = $1
+ORDER BY name;
+
+-- name: UpdateGroup :one
+-- Partial update - NULL keeps the current value
+UPDATE groups
+SET
+    name = COALESCE(sqlc.narg('name'), name),
+    description = COALESCE(sqlc.narg('description'), description),
+    updated_at = CURRENT_TIMESTAMP
+WHERE org_id = sqlc.arg('org_id')
+  AND group_id = sqlc.arg('group_id')
+RETURNING *;
+
+-- name: DeleteGroup :execrows
+-- Deletes a group together with its memberships
+DELETE FROM groups
+WHERE org_id = $1
+  AND group_id = $2;
+
+-- name: AddGroupMembers :many
+-- Adds users to a group in one statement - the arrays hold one element per user, user IDs must be unique
+-- Users that are already members get the new role. Only active users of the organization are added, the
+-- others are missing from the result. The users are locked (FOR SHARE), so a concurrent delete of one of
+-- them waits and then removes the new membership too.
+INSERT INTO group_memberships (group_id, user_id, role)
+SELECT sqlc.arg('group_id'), u.user_id, t.role
+FROM (
+    -- set-returning functions in one select list step through the arrays together
+    SELECT
+        unnest(sqlc.arg('user_ids')::uuid[]) AS user_id,
+        unnest(sqlc.arg('roles')::text[]) AS role
+) AS t
+JOIN users u ON u.user_id = t.user_id
+WHERE u.org_id = sqlc.arg('org_id')
+  AND u.deleted_at IS NULL
+FOR SHARE OF u
+ON CONFLICT (group_id, user_id) DO UPDATE SET role = EXCLUDED.role
+RETURNING *;
+
+-- name: RemoveGroupMembers :many
+-- Removes users from a group and returns the IDs of the users that were members
+DELETE FROM group_memberships
+WHERE group_id = sqlc.arg('group_id')
+  AND user_id = ANY(sqlc.arg('user_ids')::uuid[])
+RETURNING user_id;
+
+-- name: GetGroupMemberRole :one
+-- The role of a user in a group - no row (pgx.ErrNoRows) when they are not a member
+SELECT role FROM group_memberships
+WHERE group_id = $1
+  AND user_id = $2;
+
+-- name: ListGroupMembers :many
+-- Retrieves a page of the members of a group in the order they joined
+SELECT m.user_id, u.first_name, u.last_name, u.email, m.role, m.joined_at
+FROM group_memberships m
+JOIN users u ON u.user_id = m.user_id
+WHERE m.group_id = sqlc.arg('group_id')
+  AND (
+    sqlc.narg('cursor_joined_at')::timestamptz IS NULL
+    OR (m.joined_at, m.user_id) > (sqlc.narg('cursor_joined_at')::timestamptz, sqlc.narg('cursor_user_id')::uuid)
+  )
+ORDER BY m.joined_at, m.user_id
+LIMIT sqlc.arg('page_limit');
+
+-- name: ListUserGroups :many
+-- Retrieves the groups a user is a member of, with their role in each
+SELECT g.group_id, g.name, g.description, m.role, m.joined_at
+FROM group_memberships m
+JOIN groups g ON g.group_id = m.group_id
+WHERE g.org_id = $1
+  AND m.user_id = $2
+ORDER BY g.name;
+
+-- name: DeleteUserGroupMemberships :execrows
+-- Removes a user from every group - run it in the transaction that deletes the user
+DELETE FROM group_memberships
+WHERE user_id = $1;
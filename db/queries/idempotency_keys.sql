@@ -0,0 +1,49 @@
+-- name: ClaimIdempotencyKey :one
+-- Starts processing a key - returns no row (pgx.ErrNoRows) when someone else holds it
+-- An expired key, or one stuck in processing since before stale_before (the server died), is taken over
+INSERT INTO idempotency_keys (
+    caller,
+    idempotency_key,
+    request_hash,
+    expires_at
+) VALUES (
+    sqlc.arg('caller'), sqlc.arg('idempotency_key'), sqlc.arg('request_hash'), sqlc.arg('expires_at')
+)
+ON CONFLICT (caller, idempotency_key) DO UPDATE
+SET
+    request_hash = EXCLUDED.request_hash,
+    status = 'processing',
+    response_status = NULL,
+    response_headers = NULL,
+    response_body = NULL,
+    created_at = CURRENT_TIMESTAMP,
+    expires_at = EXCLUDED.expires_at
+WHERE idempotency_keys.expires_at < CURRENT_TIMESTAMP
+   OR (idempotency_keys.status = 'processing' AND idempotency_keys.created_at < sqlc.arg('stale_before'))
+RETURNING *;
+
+-- name: GetIdempotencyKey :one
+SELECT * FROM idempotency_keys
+WHERE caller = $1
+  AND idempotency_key = $2;
+
+-- name: CompleteIdempotencyKey :exec
+-- Stores the response so retries get it instead of running the request again
+UPDATE idempotency_keys
+SET
+    status = 'completed',
+    response_status = $3,
+    response_headers = $4,
+    response_body = $5
+WHERE caller = $1
+  AND idempotency_key = $2;
+
+-- name: DeleteIdempotencyKey :exec
+-- Releases a key whose request failed, so a retry runs it again
+DELETE FROM idempotency_keys
+WHERE caller = $1
+  AND idempotency_key = $2;
+
+-- name: DeleteExpiredIdempotencyKeys :execrows
+DELETE FROM idempotency_keys
+WHERE expires_at < CURRENT_TIMESTAMP;
@@ -0,0 +1,90 @@
+-- name: CreateImportJob :one
+-- Queues an import for the worker
+INSERT INTO import_jobs (
+    org_id,
+    format,
+    dry_run,
+    on_conflict,
+    column_mapping,
+    payload,
+    total_rows,
+    created_by,
+    request_id
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9
+)
+RETURNING *;
+
+-- name: GetImportJob :one
+-- Jobs of another organization are not found
+SELECT * FROM import_jobs
+WHERE org_id = $1
+  AND job_id = $2;
+
+-- name: ClaimImportJob :one
+-- Takes the oldest pending job and leases it until lease_until - returns no row (pgx.ErrNoRows) when there is none
+-- A running job whose lease ran out lost its worker, it is taken over and resumes after processed_rows.
+UPDATE import_jobs
+SET
+    status = 'running',
+    started_at = COALESCE(started_at, CURRENT_TIMESTAMP),
+    lease_until = sqlc.arg('lease_until')
+WHERE job_id = (
+    SELECT job_id FROM import_jobs
+    WHERE status = 'pending'
+       OR (status = 'running' AND lease_until < CURRENT_TIMESTAMP)
+    ORDER BY created_at
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: RecordImportJobProgress :exec
+-- Adds the outcome of a batch and extends the lease - run it in the batch's transaction
+UPDATE import_jobs
+SET
+    processed_rows = sqlc.arg('processed_rows'),
+    created_count = created_count + sqlc.arg('created'),
+    updated_count = updated_count + sqlc.arg('updated'),
+    skipped_count = skipped_count + sqlc.arg('skipped'),
+    failed_count = failed_count + sqlc.arg('failed'),
+    lease_until = sqlc.arg('lease_until')
+WHERE job_id = sqlc.arg('job_id');
+
+-- name: FinishImportJob :exec
+-- Marks a job 'completed' or 'failed' and drops the uploaded file
+UPDATE import_jobs
+SET
+    status = sqlc.arg('status'),
+    error = sqlc.narg('error'),
+    payload = NULL,
+    lease_until = NULL,
+    finished_at = CURRENT_TIMESTAMP
+WHERE job_id = sqlc.arg('job_id');
+
+-- name: DeleteFinishedImportJobs :execrows
+-- Removes jobs, and their row reports, that finished before the cutoff
+DELETE FROM import_jobs
+WHERE finished_at < $1;
+
+-- name: CreateImportJobRows :exec
+-- Stores the row reports of a batch, given as a JSON array of models.ImportRowResult
+INSERT INTO import_job_rows (job_id, line, email, status, user_id, message, invalid_params)
+SELECT sqlc.arg('job_id'), r.line, NULLIF(r.email, ''), r.status, r."userId", NULLIF(r.message, ''), r."invalidParams"
+FROM jsonb_to_recordset(sqlc.arg('rows')::jsonb) AS r(
+    line INT,
+    email TEXT,
+    status TEXT,
+    "userId" UUID,
+    message TEXT,
+    "invalidParams" JSONB
+)
+ON CONFLICT (job_id, line) DO NOTHING;
+
+-- name: ListImportJobRows :many
+-- A page of the row report of a job, in file order
+SELECT * FROM import_job_rows
+WHERE job_id = sqlc.arg('job_id')
+  AND line > sqlc.arg('after_line')
+ORDER BY line
+LIMIT sqlc.arg('page_limit');
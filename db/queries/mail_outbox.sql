@@ -0,0 +1,40 @@
+-- name: CreateOutboxMail :exec
+-- Adds a mail to the outbox - call it in the transaction that issued the token the mail carries
+INSERT INTO mail_outbox (
+    org_id,
+    user_id,
+    recipient,
+    subject,
+    text_body,
+    html_body
+) VALUES (
+    $1, $2, $3, $4, $5, $6
+);
+
+-- name: DeleteUserOutboxMails :execrows
+-- Drops the queued mails of a user, e.g. when a new token replaces the one they carry
+DELETE FROM mail_outbox
+WHERE user_id = $1;
+
+-- name: ListDueOutboxMails :many
+-- Retrieves the oldest mails that are due and locks them until the transaction ends
+-- A second relay skips them instead of sending them twice
+SELECT * FROM mail_outbox
+WHERE next_attempt_at <= CURRENT_TIMESTAMP
+ORDER BY created_at
+LIMIT $1
+FOR UPDATE SKIP LOCKED;
+
+-- name: DeleteOutboxMail :exec
+-- Removes a mail once it is sent (or given up on), so its token isn't kept around
+DELETE FROM mail_outbox
+WHERE mail_id = $1;
+
+-- name: RecordOutboxMailFailure :exec
+-- Keeps the mail queued so the relay retries it at next_attempt_at
+UPDATE mail_outbox
+SET
+    attempts = attempts + 1,
+    last_error = $2,
+    next_attempt_at = $3
+WHERE mail_id = $1;
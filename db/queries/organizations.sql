@@ -0,0 +1,35 @@
+-- name: CreateOrganization :one
+INSERT INTO organizations (
+    slug,
+    name
+) VALUES (
+    $1, $2
+)
+RETURNING *;
+
+-- name: GetOrganization :one
+SELECT * FROM organizations
+WHERE org_id = $1;
+
+-- name: GetOrganizationBySlug :one
+SELECT * FROM organizations
+WHERE slug = $1;
+
+-- name: ListOrganizations :many
+SELECT * FROM organizations
+ORDER BY slug;
+
+-- name: UpdateOrganization :one
+-- Partial update - NULL keeps the current value
+UPDATE organizations
+SET
+    slug = COALESCE(sqlc.narg('slug'), slug),
+    name = COALESCE(sqlc.narg('name'), name),
+    updated_at = CURRENT_TIMESTAMP
+WHERE org_id = sqlc.arg('org_id')
+RETURNING *;
+
+-- name: DeleteOrganization :execrows
+-- Fails with a foreign key violation while the organization still has users
+DELETE FROM organizations
+WHERE org_id = $1;
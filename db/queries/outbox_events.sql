@@ -0,0 +1,45 @@
+-- name: CreateOutboxEvent :exec
+-- Adds an event to the outbox - call it in the transaction of the change the event describes
+INSERT INTO outbox_events (
+    event_id,
+    org_id,
+    aggregate_type,
+    aggregate_id,
+    event_type,
+    schema_version,
+    payload,
+    occurred_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8
+);
+
+-- name: ListPendingOutboxEvents :many
+-- Retrieves the oldest unpublished events and locks them until the transaction ends
+-- A second relay blocks here instead of publishing the same events out of order
+SELECT * FROM outbox_events
+WHERE published_at IS NULL
+ORDER BY sequence_num
+LIMIT $1
+FOR UPDATE;
+
+-- name: MarkOutboxEventPublished :exec
+UPDATE outbox_events
+SET
+    published_at = CURRENT_TIMESTAMP,
+    attempts = attempts + 1,
+    last_error = NULL
+WHERE event_id = $1;
+
+-- name: RecordOutboxEventFailure :exec
+-- Keeps the event pending so the relay retries it
+UPDATE outbox_events
+SET
+    attempts = attempts + 1,
+    last_error = $2
+WHERE event_id = $1;
+
+-- name: DeletePublishedOutboxEvents :execrows
+-- Removes events that were published before the cutoff
+DELETE FROM outbox_events
+WHERE published_at IS NOT NULL
+  AND published_at < $1;
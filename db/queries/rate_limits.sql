@@ -0,0 +1,21 @@
+-- name: CreateRateLimit :exec
+-- Makes sure the row exists so GetRateLimitForUpdate always has something to lock
+INSERT INTO rate_limits (limit_key, tat)
+VALUES ($1, $2)
+ON CONFLICT (limit_key) DO NOTHING;
+
+-- name: GetRateLimitForUpdate :one
+-- Locks the row until the transaction ends, so concurrent requests of one client are counted one after the other
+SELECT tat FROM rate_limits
+WHERE limit_key = $1
+FOR UPDATE;
+
+-- name: UpdateRateLimit :exec
+UPDATE rate_limits
+SET tat = $2
+WHERE limit_key = $1;
+
+-- name: DeleteExpiredRateLimits :execrows
+-- Rows whose TAT has passed are equivalent to missing rows
+DELETE FROM rate_limits
+WHERE tat < $1;
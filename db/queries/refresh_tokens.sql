@@ -0,0 +1,38 @@
+-- name: CreateRefreshToken :one
+-- Stores a newly issued refresh token (only its hash)
+INSERT INTO refresh_tokens (
+    user_id,
+    family_id,
+    token_hash,
+    expires_at
+) VALUES (
+    $1, $2, $3, $4
+)
+RETURNING *;
+
+-- name: GetRefreshTokenByHash :one
+-- Retrieves a refresh token by the hash of the token value
+SELECT * FROM refresh_tokens
+WHERE token_hash = $1;
+
+-- name: MarkRefreshTokenUsed :execrows
+-- Marks a token as exchanged, affects 0 rows if it was already used or revoked
+UPDATE refresh_tokens
+SET used_at = CURRENT_TIMESTAMP
+WHERE token_id = $1
+  AND used_at IS NULL
+  AND revoked_at IS NULL;
+
+-- name: RevokeRefreshTokenFamily :exec
+-- Revokes every token issued from the same login
+UPDATE refresh_tokens
+SET revoked_at = CURRENT_TIMESTAMP
+WHERE family_id = $1
+  AND revoked_at IS NULL;
+
+-- name: RevokeUserRefreshTokens :exec
+-- Revokes every token of a user, ending all of their sessions
+UPDATE refresh_tokens
+SET revoked_at = CURRENT_TIMESTAMP
+WHERE user_id = $1
+  AND revoked_at IS NULL;
@@ -0,0 +1,61 @@
+-- name: ListRoles :many
+-- Retrieves every role
+SELECT * FROM roles
+ORDER BY role_name;
+
+-- name: ListRolePermissions :many
+-- Retrieves the permissions granted by every role
+SELECT * FROM role_permissions
+ORDER BY role_name, permission_name;
+
+-- name: RoleExists :one
+-- Checks if a role exists by name
+SELECT EXISTS(
+    SELECT 1 FROM roles WHERE role_name = $1
+);
+
+-- name: GetUserRoles :many
+-- Retrieves the names of the roles assigned to a user
+SELECT role_name FROM user_roles
+WHERE user_id = $1
+ORDER BY role_name;
+
+-- name: GetUserPermissions :many
+-- Retrieves every permission a user has through their roles, deleted users and users of another organization have none
+SELECT DISTINCT rp.permission_name
+FROM user_roles ur
+JOIN role_permissions rp ON rp.role_name = ur.role_name
+JOIN users u ON u.user_id = ur.user_id
+WHERE ur.user_id = $1
+  AND u.org_id = $2
+  AND u.deleted_at IS NULL
+ORDER BY rp.permission_name;
+
+-- name: AssignUserRole :exec
+-- Assigns a role to a user, assigning it twice is a no-op
+INSERT INTO user_roles (user_id, role_name)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: RemoveUserRole :execrows
+-- Removes a role from a user
+DELETE FROM user_roles
+WHERE user_id = $1 AND role_name = $2;
+
+-- name: LockRoleHolders :many
+-- Retrieves the users of an organization that have a role, and locks their assignments until the transaction ends
+-- Deleted users are left out, they can't use the role
+SELECT ur.user_id
+FROM user_roles ur
+JOIN users u ON u.user_id = ur.user_id
+WHERE ur.role_name = sqlc.arg('role_name')
+  AND u.org_id = sqlc.arg('org_id')
+  AND u.deleted_at IS NULL
+ORDER BY ur.user_id
+FOR UPDATE OF ur;
+
+-- name: AssignRoleToUsers :exec
+-- Assigns a role to many users at once, users that already have it are skipped
+INSERT INTO user_roles (user_id, role_name)
+SELECT unnest(sqlc.arg('user_ids')::uuid[]), sqlc.arg('role_name')
+ON CONFLICT DO NOTHING;
@@ -6,70 +6,258 @@ name: <MethodName> :<command>
 :exec: Executes a query without returning rows (returns only an error).
 :execrows: Returns the number of affected rows.
 
+Deleted users (deleted_at IS NOT NULL) are skipped by every query unless its comment says otherwise.
+Every query only sees the users of one organization (org_id) unless its comment says otherwise.
+
 */
 
 -- name: CreateUser :one
 -- Creates a new user and returns the created user
 INSERT INTO users (
+    org_id,
     first_name,
     last_name,
     email,
     phone,
     age,
-    status
+    status,
+    password_hash
 ) VALUES (
-    $1, $2, $3, $4, $5, $6
+    $1, $2, $3, $4, $5, $6, $7, $8
 )
 RETURNING *;
 
 -- name: GetUserByID :one
 -- Retrieves a single user by their ID
 SELECT * FROM users
-WHERE user_id = $1;
+WHERE org_id = $1
+  AND user_id = $2
+  AND deleted_at IS NULL;
 
 -- name: GetUserByEmail :one
 -- Retrieves a single user by their email
 SELECT * FROM users
-WHERE email = $1;
-
--- name: ListUsers :many
--- Retrieves all users with optional filtering
-SELECT * FROM users
-ORDER BY created_at DESC;
+WHERE org_id = $1
+  AND email = $2
+  AND deleted_at IS NULL;
 
--- name: ListUsersByStatus :many
--- Retrieves users filtered by status
+-- name: GetUserForUpdate :one
+-- Retrieves a user, deleted or not, and locks the row until the transaction ends
 SELECT * FROM users
-WHERE status = $1
-ORDER BY created_at DESC;
+WHERE org_id = $1
+  AND user_id = $2
+FOR UPDATE;
 
 -- name: UpdateUser :one
 -- Updates a user's information
+-- With expected_version set, no row is updated (pgx.ErrNoRows) unless the version still matches
+-- A new email address is unverified until the user confirms it.
 UPDATE users
 SET
     first_name = COALESCE(sqlc.narg('first_name'), first_name),
     last_name = COALESCE(sqlc.narg('last_name'), last_name),
     email = COALESCE(sqlc.narg('email'), email),
+    email_verified_at = CASE
+        WHEN sqlc.narg('email')::text IS NULL OR sqlc.narg('email')::text = email THEN email_verified_at
+        ELSE NULL
+    END,
     phone = COALESCE(sqlc.narg('phone'), phone),
     age = COALESCE(sqlc.narg('age'), age),
     status = COALESCE(sqlc.narg('status'), status),
+    version = version + 1,
+    updated_at = CURRENT_TIMESTAMP
+WHERE org_id = sqlc.arg('org_id')
+  AND user_id = sqlc.arg('user_id')
+  AND deleted_at IS NULL
+  AND (sqlc.narg('expected_version')::int IS NULL OR version = sqlc.narg('expected_version')::int)
+RETURNING *;
+
+-- name: DeleteUser :one
+-- Soft deletes a user by ID, the purge job removes the row later
+-- With expected_version set, nothing is deleted (pgx.ErrNoRows) unless the version still matches
+UPDATE users
+SET
+    deleted_at = CURRENT_TIMESTAMP,
+    version = version + 1,
+    updated_at = CURRENT_TIMESTAMP
+WHERE org_id = sqlc.arg('org_id')
+  AND user_id = sqlc.arg('user_id')
+  AND deleted_at IS NULL
+  AND (sqlc.narg('expected_version')::int IS NULL OR version = sqlc.narg('expected_version')::int)
+RETURNING *;
+
+-- name: RestoreUser :one
+-- Undoes a soft delete
+UPDATE users
+SET
+    deleted_at = NULL,
+    version = version + 1,
+    updated_at = CURRENT_TIMESTAMP
+WHERE org_id = $1
+  AND user_id = $2
+  AND deleted_at IS NOT NULL
+RETURNING *;
+
+-- name: VerifyUserEmail :one
+-- Marks the email of a user as confirmed - no row (pgx.ErrNoRows) if the address changed since the token was sent
+UPDATE users
+SET
+    email_verified_at = CURRENT_TIMESTAMP,
+    version = version + 1,
     updated_at = CURRENT_TIMESTAMP
-WHERE user_id = $1
+WHERE org_id = $1
+  AND user_id = $2
+  AND email = $3
+  AND deleted_at IS NULL
 RETURNING *;
 
--- name: DeleteUser :exec
--- Deletes a user by ID
+-- name: PurgeDeletedUsers :execrows
+-- Hard deletes users that were soft deleted before the cutoff, in every organization
 DELETE FROM users
-WHERE user_id = $1;
+WHERE deleted_at IS NOT NULL
+  AND deleted_at < $1;
 
 -- name: UserExists :one
 -- Checks if a user exists by ID
 SELECT EXISTS(
-    SELECT 1 FROM users WHERE user_id = $1
+    SELECT 1 FROM users WHERE org_id = $1 AND user_id = $2 AND deleted_at IS NULL
 );
 
 -- name: EmailExists :one
 -- Checks if an email is already registered
 SELECT EXISTS(
-    SELECT 1 FROM users WHERE email = $1
-);
\ No newline at end of file
+    SELECT 1 FROM users WHERE org_id = $1 AND email = $2 AND deleted_at IS NULL
+);
+
+-- name: ListActiveUserEmails :many
+-- Returns which of the given emails are registered
+SELECT email FROM users
+WHERE org_id = sqlc.arg('org_id')
+  AND email = ANY(sqlc.arg('emails')::text[])
+  AND deleted_at IS NULL;
+
+-- name: LockActiveUsersByEmail :many
+-- Retrieves the users with any of the given emails and locks them until the transaction ends
+SELECT * FROM users
+WHERE org_id = sqlc.arg('org_id')
+  AND email = ANY(sqlc.arg('emails')::text[])
+  AND deleted_at IS NULL
+ORDER BY user_id
+FOR UPDATE;
+
+-- name: InsertImportedUsers :many
+-- Creates one import batch in a single statement - the arrays hold one element per user
+-- Emails that are already taken are skipped, so they are missing from the result.
+-- Empty phones and password hashes, zero ages and empty statuses mean "not given".
+INSERT INTO users (
+    org_id,
+    first_name,
+    last_name,
+    email,
+    phone,
+    age,
+    status,
+    password_hash
+)
+SELECT
+    sqlc.arg('org_id'),
+    t.first_name,
+    t.last_name,
+    t.email,
+    NULLIF(t.phone, ''),
+    NULLIF(t.age, 0),
+    COALESCE(NULLIF(t.status, ''), 'Active')::user_status,
+    NULLIF(t.password_hash, '')
+FROM (
+    -- set-returning functions in one select list step through the arrays together
+    SELECT
+        unnest(sqlc.arg('first_names')::text[]) AS first_name,
+        unnest(sqlc.arg('last_names')::text[]) AS last_name,
+        unnest(sqlc.arg('emails')::text[]) AS email,
+        unnest(sqlc.arg('phones')::text[]) AS phone,
+        unnest(sqlc.arg('ages')::int[]) AS age,
+        unnest(sqlc.arg('statuses')::text[]) AS status,
+        unnest(sqlc.arg('password_hashes')::text[]) AS password_hash
+) AS t
+ON CONFLICT (org_id, email) WHERE deleted_at IS NULL DO NOTHING
+RETURNING *;
+
+-- name: UpdateImportedUsers :many
+-- Updates the users of one import batch, matched by email - the arrays hold one element per user
+-- Empty phones, zero ages and empty statuses keep the current value. Users that already match
+-- are left alone (no new version), so they are missing from the result.
+UPDATE users u
+SET
+    first_name = t.first_name,
+    last_name = t.last_name,
+    phone = COALESCE(NULLIF(t.phone, ''), u.phone),
+    age = COALESCE(NULLIF(t.age, 0), u.age),
+    status = COALESCE(NULLIF(t.status, '')::user_status, u.status),
+    version = u.version + 1,
+    updated_at = CURRENT_TIMESTAMP
+FROM (
+    SELECT
+        unnest(sqlc.arg('emails')::text[]) AS email,
+        unnest(sqlc.arg('first_names')::text[]) AS first_name,
+        unnest(sqlc.arg('last_names')::text[]) AS last_name,
+        unnest(sqlc.arg('phones')::text[]) AS phone,
+        unnest(sqlc.arg('ages')::int[]) AS age,
+        unnest(sqlc.arg('statuses')::text[]) AS status
+) AS t
+WHERE u.org_id = sqlc.arg('org_id')
+  AND u.email = t.email
+  AND u.deleted_at IS NULL
+  AND (u.first_name, u.last_name, u.phone, u.age, u.status) IS DISTINCT FROM (
+      t.first_name,
+      t.last_name,
+      COALESCE(NULLIF(t.phone, ''), u.phone),
+      COALESCE(NULLIF(t.age, 0), u.age),
+      COALESCE(NULLIF(t.status, '')::user_status, u.status)
+  )
+RETURNING u.*;
+
+-- name: SearchUsers :many
+-- Ranked search over names, email and phone, ordered by score with user_id breaking ties
+-- Users matching the full-text query (every word, see idx_users_search) score above 1, users where one of
+-- the words is only similar to a field (typos such as "smth") score between 0 and 1.
+-- after_score and after_id are the keyset cursor, the last row of the previous page.
+SELECT user_id, first_name, last_name, email, phone, age, status, created_at, updated_at, deleted_at, version, org_id, email_verified_at, score
+FROM (
+    SELECT
+        users.*,
+        (
+            CASE
+                WHEN to_tsvector('simple', first_name || ' ' || last_name || ' ' || email || ' ' || COALESCE(phone, ''))
+                     @@ to_tsquery('simple', sqlc.arg('tsquery'))
+                THEN 1 + ts_rank(
+                    to_tsvector('simple', first_name || ' ' || last_name || ' ' || email || ' ' || COALESCE(phone, '')),
+                    to_tsquery('simple', sqlc.arg('tsquery'))
+                )
+                ELSE 0
+            END
+            + (
+                SELECT AVG(GREATEST(
+                    similarity(t.term, first_name),
+                    similarity(t.term, last_name),
+                    word_similarity(t.term, email),
+                    word_similarity(t.term, COALESCE(phone, ''))
+                ))
+                FROM unnest(sqlc.arg('terms')::text[]) AS t(term)
+            ) * 0.99
+        )::float8 AS score
+    FROM users
+    WHERE org_id = sqlc.arg('org_id')
+      AND deleted_at IS NULL
+      AND (
+          to_tsvector('simple', first_name || ' ' || last_name || ' ' || email || ' ' || COALESCE(phone, ''))
+              @@ to_tsquery('simple', sqlc.arg('tsquery'))
+          OR first_name % ANY(sqlc.arg('terms')::text[])
+          OR last_name % ANY(sqlc.arg('terms')::text[])
+          OR email %> ANY(sqlc.arg('terms')::text[])
+          OR phone %> ANY(sqlc.arg('terms')::text[])
+      )
+) AS matches
+WHERE sqlc.narg('after_score')::float8 IS NULL
+   OR (score, user_id) < (sqlc.narg('after_score')::float8, sqlc.narg('after_id')::uuid)
+ORDER BY score DESC, user_id DESC
+LIMIT sqlc.arg('page_limit');
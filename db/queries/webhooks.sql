@@ -0,0 +1,107 @@
+-- name: CreateWebhookSubscription :one
+INSERT INTO webhook_subscriptions (
+    org_id,
+    url,
+    secret,
+    event_types,
+    description,
+    active
+) VALUES (
+    $1, $2, $3, $4, $5, $6
+)
+RETURNING *;
+
+-- name: GetWebhookSubscription :one
+SELECT * FROM webhook_subscriptions
+WHERE org_id = $1
+  AND subscription_id = $2;
+
+-- name: ListWebhookSubscriptions :many
+SELECT * FROM webhook_subscriptions
+WHERE org_id = $1
+ORDER BY created_at, subscription_id;
+
+-- name: UpdateWebhookSubscription :one
+-- Partial update - NULL keeps the current value
+UPDATE webhook_subscriptions
+SET
+    url = COALESCE(sqlc.narg('url'), url),
+    secret = COALESCE(sqlc.narg('secret'), secret),
+    event_types = COALESCE(sqlc.narg('event_types')::text[], event_types),
+    description = COALESCE(sqlc.narg('description'), description),
+    active = COALESCE(sqlc.narg('active'), active),
+    updated_at = CURRENT_TIMESTAMP
+WHERE org_id = sqlc.arg('org_id')
+  AND subscription_id = sqlc.arg('subscription_id')
+RETURNING *;
+
+-- name: DeleteWebhookSubscription :execrows
+DELETE FROM webhook_subscriptions
+WHERE org_id = $1
+  AND subscription_id = $2;
+
+-- name: CreateWebhookDeliveries :execrows
+-- Queues an event for every active subscription of its organization that wants its type
+-- Call it in the transaction that writes the event to the outbox
+INSERT INTO webhook_deliveries (org_id, subscription_id, event_id, event_type, payload)
+SELECT s.org_id, s.subscription_id, sqlc.arg('event_id')::uuid, sqlc.arg('event_type')::text, sqlc.arg('payload')::jsonb
+FROM webhook_subscriptions s
+WHERE s.org_id = sqlc.arg('org_id')
+  AND s.active
+  AND (cardinality(s.event_types) = 0 OR sqlc.arg('event_type')::text = ANY(s.event_types));
+
+-- name: ClaimDueWebhookDeliveries :many
+-- Takes a batch of due deliveries and pushes their next attempt to lease_until, so no other
+-- dispatcher takes them while they are being sent. If the dispatcher dies, they are retried after the lease.
+UPDATE webhook_deliveries
+SET next_attempt_at = sqlc.arg('lease_until')
+WHERE delivery_id IN (
+    SELECT delivery_id FROM webhook_deliveries
+    WHERE status = 'pending'
+      AND next_attempt_at <= CURRENT_TIMESTAMP
+    ORDER BY next_attempt_at
+    LIMIT sqlc.arg('batch_size')
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: RecordWebhookDeliveryAttempt :exec
+-- Stores the outcome of an attempt - status is 'succeeded', 'pending' (retry at next_attempt_at) or 'dead'
+UPDATE webhook_deliveries
+SET
+    status = sqlc.arg('status'),
+    attempts = attempts + 1,
+    next_attempt_at = sqlc.arg('next_attempt_at'),
+    last_attempt_at = CURRENT_TIMESTAMP,
+    last_status_code = sqlc.narg('last_status_code'),
+    last_error = sqlc.narg('last_error')
+WHERE delivery_id = sqlc.arg('delivery_id');
+
+-- name: GetWebhookDelivery :one
+SELECT * FROM webhook_deliveries
+WHERE org_id = $1
+  AND delivery_id = $2
+  AND subscription_id = $3;
+
+-- name: ListWebhookDeliveries :many
+-- Retrieves a page of the delivery log of a subscription, newest first
+SELECT * FROM webhook_deliveries
+WHERE org_id = sqlc.arg('org_id')
+  AND subscription_id = sqlc.arg('subscription_id')
+  AND (sqlc.narg('status')::text IS NULL OR status = sqlc.narg('status')::text)
+  AND (
+    sqlc.narg('cursor_created_at')::timestamptz IS NULL
+    OR (created_at, delivery_id) < (sqlc.narg('cursor_created_at')::timestamptz, sqlc.narg('cursor_delivery_id')::uuid)
+  )
+ORDER BY created_at DESC, delivery_id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: ReplayWebhookDelivery :one
+-- Queues a new delivery of the same event, the original stays in the log as it was
+INSERT INTO webhook_deliveries (org_id, subscription_id, event_id, event_type, payload)
+SELECT d.org_id, d.subscription_id, d.event_id, d.event_type, d.payload
+FROM webhook_deliveries d
+WHERE d.org_id = $1
+  AND d.delivery_id = $2
+  AND d.subscription_id = $3
+RETURNING *;
@@ -0,0 +1,94 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+)
+
+// Actions recorded in the audit log
+const (
+	ActionUserCreated       = "user.created"
+	ActionUserUpdated       = "user.updated"
+	ActionUserDeleted       = "user.deleted"
+	ActionUserRestored      = "user.restored"
+	ActionUserEmailVerified = "user.email_verified"
+)
+
+// ResourceUser is the resource type of user events
+const ResourceUser = "user"
+
+// RequestInfo describes the HTTP request that caused a change
+type RequestInfo struct {
+	RequestID string
+	IP        string
+	UserAgent string
+}
+
+type requestInfoKey struct{}
+
+// WithRequestInfo returns a copy of ctx carrying the request info
+func WithRequestInfo(ctx context.Context, info RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, info)
+}
+
+// RequestInfoFromContext returns the request info, or an empty one outside of a request (background jobs)
+func RequestInfoFromContext(ctx context.Context) RequestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(RequestInfo)
+	return info
+}
+
+// Change is the old and new value of one field
+type Change struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// Diff compares the JSON form of two values and returns the fields that differ
+// before or after may be nil (create / purge). ignore lists fields that change on every write, like updatedAt.
+func Diff(before, after interface{}, ignore ...string) (map[string]Change, error) {
+	oldFields, err := toFields(before)
+	if err != nil {
+		return nil, err
+	}
+	newFields, err := toFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(map[string]Change)
+	for name, oldValue := range oldFields {
+		if newValue, ok := newFields[name]; !ok || !reflect.DeepEqual(oldValue, newValue) {
+			changes[name] = Change{Old: oldValue, New: newFields[name]}
+		}
+	}
+	for name, newValue := range newFields {
+		if _, ok := oldFields[name]; !ok {
+			changes[name] = Change{Old: nil, New: newValue}
+		}
+	}
+
+	for _, name := range ignore {
+		delete(changes, name)
+	}
+
+	return changes, nil
+}
+
+// toFields turns a struct into a map of its JSON fields
+func toFields(v interface{}) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Pointer && reflect.ValueOf(v).IsNil()) {
+		return fields, nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	return fields, nil
+}
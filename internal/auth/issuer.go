@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"user-management-api/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Issuer signs the access tokens handed out by the login and refresh endpoints
+type Issuer struct {
+	method   jwt.SigningMethod
+	key      interface{}
+	keyID    string
+	issuer   string
+	audience string
+	ttl      time.Duration
+}
+
+// NewIssuer uses JWTPrivateKeyFile (RS256) when set, otherwise JWTSecret (HS256)
+func NewIssuer(cfg *config.AuthConfig) (*Issuer, error) {
+	issuer := &Issuer{
+		keyID:    cfg.JWTKeyID,
+		issuer:   cfg.JWTIssuer,
+		audience: cfg.JWTAudience,
+		ttl:      cfg.AccessTokenTTL,
+	}
+
+	switch {
+	case cfg.JWTPrivateKeyFile != "":
+		data, err := os.ReadFile(cfg.JWTPrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWT private key: %w", err)
+		}
+		key, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse JWT private key: %w", err)
+		}
+		issuer.method = jwt.SigningMethodRS256
+		issuer.key = key
+	case cfg.JWTSecret != "":
+		issuer.method = jwt.SigningMethodHS256
+		issuer.key = []byte(cfg.JWTSecret)
+	default:
+		return nil, errors.New("no JWT signing key configured: set JWT_SECRET or JWT_PRIVATE_KEY_FILE")
+	}
+
+	return issuer, nil
+}
+
+// TTL is how long access tokens are valid for
+func (i *Issuer) TTL() time.Duration {
+	return i.ttl
+}
+
+// IssueAccessToken signs a short-lived access token for a user of an organization
+func (i *Issuer) IssueAccessToken(userID, orgID uuid.UUID, email string, roles []string) (string, error) {
+	now := time.Now()
+
+	claims := Claims{
+		OrgID: orgID.String(),
+		Email: email,
+		Roles: roles,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userID.String(),
+			Issuer:    i.issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(i.ttl)),
+			ID:        uuid.NewString(),
+		},
+	}
+	if i.audience != "" {
+		claims.Audience = jwt.ClaimStrings{i.audience}
+	}
+
+	token := jwt.NewWithClaims(i.method, claims)
+	if i.keyID != "" {
+		token.Header["kid"] = i.keyID
+	}
+
+	return token.SignedString(i.key)
+}
+
+// NewRefreshToken generates an opaque random refresh token and the hash to store for it
+func NewRefreshToken() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, HashRefreshToken(token), nil
+}
+
+// HashRefreshToken is the lookup key for a refresh token - a fast hash is fine for 256 random bits
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,30 @@
+package auth
+
+import (
+	"golang.org/x/crypto/bcrypt"
+)
+
+// bcryptCost is the work factor for new hashes - raising it only affects passwords hashed afterwards
+const bcryptCost = 12
+
+// dummyHash is compared against when a login email doesn't exist, so both cases take the same time
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not-a-real-password"), bcryptCost)
+
+// HashPassword hashes a password with bcrypt (the salt is part of the hash)
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword reports whether password matches a hash from HashPassword
+func CheckPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// BurnPasswordCheck does the same work as CheckPassword for a user that doesn't exist
+func BurnPasswordCheck(password string) {
+	_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+}
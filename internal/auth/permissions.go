@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+// Permission names - they match the rows seeded into the permissions table
+const (
+	PermUsersRead      = "users:read"
+	PermUsersWrite     = "users:write"
+	PermUsersDelete    = "users:delete"
+	PermRolesAssign    = "roles:assign"
+	PermAuditRead      = "audit:read"
+	PermWebhooksManage = "webhooks:manage"
+	PermOrgsManage     = "orgs:manage"
+	PermGroupsManage   = "groups:manage"
+)
+
+// DefaultRole is assigned to every new user
+const DefaultRole = "member"
+
+// HasPermission reports whether the principal was granted a permission through one of its roles
+func (p *Principal) HasPermission(permission string) bool {
+	return slices.Contains(p.Permissions, permission)
+}
+
+// IsUser reports whether the principal is the user with the given ID (the "self" rule)
+func (p *Principal) IsUser(userID string) bool {
+	subject, err := uuid.Parse(p.Subject)
+	if err != nil {
+		return false
+	}
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return false
+	}
+	return subject == id
+}
@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"context"
+	"time"
+)
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	Subject   string // "sub" claim - the user ID for tokens we issue ourselves
+	OrgID     string // "org_id" claim, empty for tokens from issuers that don't set it
+	Email     string
+	Roles     []string
+	TokenID   string // "jti" claim, empty if the token has none
+	ExpiresAt time.Time
+
+	// Permissions come from the database rather than the token, so role changes apply immediately
+	// Set by middleware.LoadPermissions
+	Permissions []string
+}
+
+// unexported key type so no other package can overwrite the principal in the context
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the principal
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the authenticated caller, ok is false for anonymous requests
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil
+}
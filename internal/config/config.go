@@ -2,48 +2,416 @@ package config
 
 import (
 	"fmt"
-	"os"
-)
+	"net/url"
+	"time"
 
+	"user-management-api/internal/ratelimit"
+)
 
+// Config is loaded in layers: defaults, then the YAML file, then environment variables, then flags
+// Every setting has a YAML key (the path of yaml tags, e.g. server.port), an environment variable
+// (env tag) and a flag named like the YAML key (-server.port=8080). Settings tagged secret are
+// redacted by "config print".
 type Config struct {
-	DBHost     string
-	DBPort     string
-	DBUser     string
-	DBPassword string
-	DBName     string
-	ServerPort string
+	Server      ServerConfig      `yaml:"server"`
+	Database    DatabaseConfig    `yaml:"database"`
+	Log         LogConfig         `yaml:"log"`
+	Tracing     TracingConfig     `yaml:"tracing"`
+	Auth        AuthConfig        `yaml:"auth"`
+	Tenancy     TenancyConfig     `yaml:"tenancy"`
+	CORS        CORSConfig        `yaml:"cors"`
+	Users       UsersConfig       `yaml:"users"`
+	Events      EventsConfig      `yaml:"events"`
+	Webhooks    WebhooksConfig    `yaml:"webhooks"`
+	Mail        MailConfig        `yaml:"mail"`
+	Import      ImportConfig      `yaml:"import"`
+	Idempotency IdempotencyConfig `yaml:"idempotency"`
+	RateLimit   RateLimitConfig   `yaml:"rate_limit"`
+	Features    FeaturesConfig    `yaml:"features"`
+}
+
+type ServerConfig struct {
+	Port      string `yaml:"port" env:"SERVER_PORT"`
+	AdminPort string `yaml:"admin_port" env:"ADMIN_PORT"` // serves /metrics on its own listener when set, without it /metrics isn't served
+
+	ReadTimeout     time.Duration `yaml:"read_timeout" env:"SERVER_READ_TIMEOUT"`
+	WriteTimeout    time.Duration `yaml:"write_timeout" env:"SERVER_WRITE_TIMEOUT"`
+	IdleTimeout     time.Duration `yaml:"idle_timeout" env:"SERVER_IDLE_TIMEOUT"`
+	RequestTimeout  time.Duration `yaml:"request_timeout" env:"SERVER_REQUEST_TIMEOUT"` // the longest a handler may run
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SERVER_SHUTDOWN_TIMEOUT"`
+
+	// Probes - each readiness check gets HealthCheckTimeout, shutdown waits ShutdownDrainDelay after /readyz starts failing
+	HealthCheckTimeout time.Duration `yaml:"health_check_timeout" env:"HEALTH_CHECK_TIMEOUT"`
+	ShutdownDrainDelay time.Duration `yaml:"shutdown_drain_delay" env:"SHUTDOWN_DRAIN_DELAY"`
+
+	TrustedProxies []string `yaml:"trusted_proxies" env:"TRUSTED_PROXIES"` // IPs or CIDR ranges whose X-Forwarded-For is believed
+
+	TLS TLSConfig `yaml:"tls"`
+}
+
+// TLSConfig serves HTTPS when both files are set
+type TLSConfig struct {
+	CertFile string `yaml:"cert_file" env:"TLS_CERT_FILE"`
+	KeyFile  string `yaml:"key_file" env:"TLS_KEY_FILE"`
+}
+
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" && c.KeyFile != ""
+}
+
+type DatabaseConfig struct {
+	Host     string `yaml:"host" env:"DB_HOST"`
+	Port     string `yaml:"port" env:"DB_PORT"`
+	User     string `yaml:"user" env:"DB_USER"`
+	Password string `yaml:"password" env:"DB_PASSWORD" secret:"true"`
+	Name     string `yaml:"name" env:"DB_NAME"`
+
+	// SSLMode is a libpq sslmode: disable, allow, prefer, require, verify-ca or verify-full
+	SSLMode     string `yaml:"sslmode" env:"DB_SSLMODE"`
+	SSLRootCert string `yaml:"sslrootcert" env:"DB_SSLROOTCERT"` // CA file for verify-ca / verify-full
+
+	// Connection pool
+	MaxConns        int           `yaml:"max_conns" env:"DB_MAX_CONNS"`
+	MinConns        int           `yaml:"min_conns" env:"DB_MIN_CONNS"`
+	MaxConnLifetime time.Duration `yaml:"max_conn_lifetime" env:"DB_MAX_CONN_LIFETIME"`
+	MaxConnIdleTime time.Duration `yaml:"max_conn_idle_time" env:"DB_MAX_CONN_IDLE_TIME"`
+	ConnectTimeout  time.Duration `yaml:"connect_timeout" env:"DB_CONNECT_TIMEOUT"`
+
+	// Transactions - TxIsolation is "read committed", "repeatable read" or "serializable"
+	TxIsolation  string `yaml:"tx_isolation" env:"TX_ISOLATION"`
+	TxMaxRetries int    `yaml:"tx_max_retries" env:"TX_MAX_RETRIES"` // retries after serialization failures and deadlocks
+
+	// Background jobs that work across organizations (purge, outbox and mail relays, webhook dispatcher, import worker)
+	// connect as JobsUser - with tenancy.row_level_security it needs BYPASSRLS. "" uses User.
+	JobsUser     string `yaml:"jobs_user" env:"DB_JOBS_USER"`
+	JobsPassword string `yaml:"jobs_password" env:"DB_JOBS_PASSWORD" secret:"true"`
 }
 
-func LoadConfig() (*Config, error) {
+// URL builds the connection string - user and password are escaped, so they may contain any character
+func (c DatabaseConfig) URL() string {
+	query := url.Values{}
+	query.Set("sslmode", c.SSLMode)
+	if c.SSLRootCert != "" {
+		query.Set("sslrootcert", c.SSLRootCert)
+	}
+
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(c.User, c.Password),
+		Host:     c.Host + ":" + c.Port,
+		Path:     "/" + c.Name,
+		RawQuery: query.Encode(),
+	}
+	return u.String()
+}
 
-	config := &Config{
-		DBHost:     getEnv("DB_HOST", "localhost"),
-		DBPort:     getEnv("DB_PORT", "5432"),
-		DBUser:     getEnv("DB_USER", "postgres"),
-		DBPassword: getEnv("DB_PASSWORD", "postgres"),
-		DBName:     getEnv("DB_NAME", "user_management"),
-		ServerPort: getEnv("SERVER_PORT", "8080"),
+// JobsURL builds the connection string of the background jobs, URL when JobsUser is not set
+func (c DatabaseConfig) JobsURL() string {
+	if c.JobsUser == "" {
+		return c.URL()
 	}
+	jobs := c
+	jobs.User, jobs.Password = c.JobsUser, c.JobsPassword
+	return jobs.URL()
+}
+
+// LogConfig - Format is "json" or "text", Level is "debug", "info", "warn" or "error"
+type LogConfig struct {
+	Format string `yaml:"format" env:"LOG_FORMAT"`
+	Level  string `yaml:"level" env:"LOG_LEVEL"`
+}
+
+// TracingConfig - Exporter is "none" (IDs for logs only), "stdout" or "otlp" (OTLP/HTTP to OTLPEndpoint, spans go to /v1/traces)
+type TracingConfig struct {
+	Exporter     string `yaml:"exporter" env:"TRACE_EXPORTER"`
+	OTLPEndpoint string `yaml:"otlp_endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	ServiceName  string `yaml:"service_name" env:"OTEL_SERVICE_NAME"` // service.name of the exported spans
+}
+
+// AuthConfig - set JWTSecret for HS256, or JWTPublicKeyFile / JWKSFile for RS256
+type AuthConfig struct {
+	JWTSecret        string        `yaml:"jwt_secret" env:"JWT_SECRET" secret:"true"`
+	JWTPublicKeyFile string        `yaml:"jwt_public_key_file" env:"JWT_PUBLIC_KEY_FILE"` // PEM encoded RSA public key
+	JWKSFile         string        `yaml:"jwks_file" env:"JWT_JWKS_FILE"`                 // JSON Web Key Set with one or more RSA keys (selected by "kid")
+	JWTIssuer        string        `yaml:"jwt_issuer" env:"JWT_ISSUER"`                   // expected "iss" claim, not checked when empty
+	JWTAudience      string        `yaml:"jwt_audience" env:"JWT_AUDIENCE"`               // expected "aud" claim, not checked when empty
+	JWTClockSkew     time.Duration `yaml:"jwt_clock_skew" env:"JWT_CLOCK_SKEW"`
+
+	// Token issuing for /auth/login - signs with JWTSecret (HS256) or JWTPrivateKeyFile (RS256)
+	JWTPrivateKeyFile string        `yaml:"jwt_private_key_file" env:"JWT_PRIVATE_KEY_FILE"` // PEM encoded RSA private key
+	JWTKeyID          string        `yaml:"jwt_key_id" env:"JWT_KEY_ID"`                     // "kid" header of RS256 tokens, must match the key in the JWKS file
+	AccessTokenTTL    time.Duration `yaml:"access_token_ttl" env:"ACCESS_TOKEN_TTL"`
+	RefreshTokenTTL   time.Duration `yaml:"refresh_token_ttl" env:"REFRESH_TOKEN_TTL"`
+}
 
-	return config, nil
+// TenancyConfig - how a request names its organization: the org_id claim of the token, the Header
+// (organization ID or slug) or the subdomain of BaseDomain (acme.users.example.com with base domain
+// users.example.com), in that order. Requests naming none use DefaultOrg, "" rejects them instead.
+// RowLevelSecurity additionally sets app.org_id on every connection of the API for the policies of the
+// database, which hide the rows of other organizations and every row when it is missing. They don't apply
+// to superusers or the owner of the tables, so connect as another role, and give database.jobs_user BYPASSRLS.
+type TenancyConfig struct {
+	Header           string `yaml:"header" env:"TENANT_HEADER"`
+	BaseDomain       string `yaml:"base_domain" env:"TENANT_BASE_DOMAIN"` // "" turns subdomains off
+	DefaultOrg       string `yaml:"default_org" env:"TENANT_DEFAULT_ORG"` // slug or ID
+	RowLevelSecurity bool   `yaml:"row_level_security" env:"TENANT_ROW_LEVEL_SECURITY"`
 }
 
-// belongs to the Config struct - method on Config struct (receiver function)
-func (c *Config) GetDatabaseURL() string {
-	return fmt.Sprintf(
-		"postgres://%s:%s@%s:%s/%s?sslmode=disable",
-		c.DBUser, c.DBPassword, c.DBHost, c.DBPort, c.DBName,
-	)
+// CORSConfig - Default applies to every path, Routes override it for a route group by path prefix
+// (e.g. /api/v1/auth), the longest matching prefix wins. Route policies are complete policies,
+// they don't inherit from Default. Routes can only be set in the config file.
+type CORSConfig struct {
+	Default CORSPolicy            `yaml:"default"`
+	Routes  map[string]CORSPolicy `yaml:"routes"`
 }
 
-// private - unexported
-func getEnv(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
+// CORSPolicy - AllowedOrigins holds exact origins (https://admin.example.com), wildcard subdomains
+// (https://*.example.com) or "*" for every origin. AllowedOriginPatterns are regular expressions
+// matched against the whole origin. No origins means no cross-origin access.
+type CORSPolicy struct {
+	AllowedOrigins        []string      `yaml:"allowed_origins" env:"CORS_ALLOWED_ORIGINS"`
+	AllowedOriginPatterns []string      `yaml:"allowed_origin_patterns" env:"CORS_ALLOWED_ORIGIN_PATTERNS"`
+	AllowedMethods        []string      `yaml:"allowed_methods" env:"CORS_ALLOWED_METHODS"`
+	AllowedHeaders        []string      `yaml:"allowed_headers" env:"CORS_ALLOWED_HEADERS"`
+	ExposedHeaders        []string      `yaml:"exposed_headers" env:"CORS_EXPOSED_HEADERS"` // response headers scripts may read
+	AllowCredentials      bool          `yaml:"allow_credentials" env:"CORS_ALLOW_CREDENTIALS"`
+	MaxAge                time.Duration `yaml:"max_age" env:"CORS_MAX_AGE"` // how long browsers cache a preflight, 0 leaves it to the browser
+}
+
+// UsersConfig - soft deleted users are purged for good after DeletedRetention, checked every PurgeInterval (0 disables the purge)
+type UsersConfig struct {
+	DeletedRetention time.Duration `yaml:"deleted_retention" env:"DELETED_USER_RETENTION"`
+	PurgeInterval    time.Duration `yaml:"purge_interval" env:"PURGE_INTERVAL"`
+}
+
+// EventsConfig is the outbox relay - Publisher is "log" or "file" (appends NDJSON to File)
+// PollInterval 0 disables the relay, e.g. when a separate process publishes the events
+type EventsConfig struct {
+	Publisher    string        `yaml:"publisher" env:"EVENT_PUBLISHER"`
+	File         string        `yaml:"file" env:"EVENT_FILE"`
+	PollInterval time.Duration `yaml:"poll_interval" env:"OUTBOX_POLL_INTERVAL"`
+	BatchSize    int           `yaml:"batch_size" env:"OUTBOX_BATCH_SIZE"`
+	Retention    time.Duration `yaml:"retention" env:"OUTBOX_RETENTION"` // published events are deleted after this, 0 keeps them
+}
+
+// WebhooksConfig - PollInterval 0 disables sending (deliveries are still queued)
+type WebhooksConfig struct {
+	PollInterval time.Duration `yaml:"poll_interval" env:"WEBHOOK_POLL_INTERVAL"`
+	Timeout      time.Duration `yaml:"timeout" env:"WEBHOOK_TIMEOUT"`
+	MaxAttempts  int           `yaml:"max_attempts" env:"WEBHOOK_MAX_ATTEMPTS"` // a delivery is dead-lettered after this many failed attempts
+	BackoffBase  time.Duration `yaml:"backoff_base" env:"WEBHOOK_BACKOFF_BASE"`
+	BackoffMax   time.Duration `yaml:"backoff_max" env:"WEBHOOK_BACKOFF_MAX"`
+	BatchSize    int           `yaml:"batch_size" env:"WEBHOOK_BATCH_SIZE"` // deliveries sent concurrently per poll
+	// AllowPrivateNetworks lets deliveries go to loopback, private and link-local addresses - for development only,
+	// subscribers could otherwise reach services that aren't meant to be reachable from outside
+	AllowPrivateNetworks bool `yaml:"allow_private_networks" env:"WEBHOOK_ALLOW_PRIVATE_NETWORKS"`
+}
+
+// MailConfig - Mailer is "log" (development), "file" (appends NDJSON to File) or "smtp"
+// Verification mails link to VerificationURL with the token added as the token query parameter,
+// the page behind it posts the token to /api/v1/auth/verify-email.
+// Mails of created and imported users go through the mail outbox, PollInterval 0 disables sending them
+// (they stay queued).
+type MailConfig struct {
+	Mailer          string        `yaml:"mailer" env:"MAILER"`
+	File            string        `yaml:"file" env:"MAIL_FILE"`
+	From            string        `yaml:"from" env:"MAIL_FROM"` // an address with an optional name such as "Acme <no-reply@acme.com>"
+	SMTPHost        string        `yaml:"smtp_host" env:"SMTP_HOST"`
+	SMTPPort        string        `yaml:"smtp_port" env:"SMTP_PORT"`         // 465 is implicit TLS, other ports upgrade with STARTTLS when offered
+	SMTPUsername    string        `yaml:"smtp_username" env:"SMTP_USERNAME"` // no authentication when empty
+	SMTPPassword    string        `yaml:"smtp_password" env:"SMTP_PASSWORD" secret:"true"`
+	Timeout         time.Duration `yaml:"timeout" env:"MAIL_TIMEOUT"`
+	TemplatesDir    string        `yaml:"templates_dir" env:"MAIL_TEMPLATES_DIR"` // replaces the built-in templates (internal/mail/templates), "" keeps them
+	VerificationURL string        `yaml:"verification_url" env:"EMAIL_VERIFICATION_URL"`
+	VerificationTTL time.Duration `yaml:"verification_ttl" env:"EMAIL_VERIFICATION_TTL"` // how long a verification link works
+
+	PollInterval time.Duration `yaml:"poll_interval" env:"MAIL_POLL_INTERVAL"`
+	BatchSize    int           `yaml:"batch_size" env:"MAIL_BATCH_SIZE"`     // mails sent per poll
+	MaxAttempts  int           `yaml:"max_attempts" env:"MAIL_MAX_ATTEMPTS"` // a mail is dropped after this many failed attempts
+	BackoffBase  time.Duration `yaml:"backoff_base" env:"MAIL_BACKOFF_BASE"`
+	BackoffMax   time.Duration `yaml:"backoff_max" env:"MAIL_BACKOFF_MAX"`
+}
+
+// ImportConfig - imports of up to SyncMaxRows rows run during the request, larger ones are queued
+// and run by the worker, PollInterval 0 disables the worker (jobs stay queued)
+type ImportConfig struct {
+	PollInterval  time.Duration `yaml:"poll_interval" env:"IMPORT_POLL_INTERVAL"`
+	BatchSize     int           `yaml:"batch_size" env:"IMPORT_BATCH_SIZE"` // rows written per transaction
+	SyncMaxRows   int           `yaml:"sync_max_rows" env:"IMPORT_SYNC_MAX_ROWS"`
+	MaxRows       int           `yaml:"max_rows" env:"IMPORT_MAX_ROWS"`
+	MaxBodySize   int           `yaml:"max_body_size" env:"IMPORT_MAX_BODY_SIZE"`   // bytes
+	LeaseDuration time.Duration `yaml:"lease_duration" env:"IMPORT_LEASE_DURATION"` // another worker takes over a job whose worker was silent this long
+	Retention     time.Duration `yaml:"retention" env:"IMPORT_RETENTION"`           // finished jobs are deleted after this, 0 keeps them
+}
+
+// IdempotencyConfig - responses are kept for KeyTTL, a retry waits up to Wait for the first request
+type IdempotencyConfig struct {
+	KeyTTL time.Duration `yaml:"key_ttl" env:"IDEMPOTENCY_KEY_TTL"`
+	Wait   time.Duration `yaml:"wait" env:"IDEMPOTENCY_WAIT"`
+}
+
+// RateLimitConfig - Store is "memory" (per instance) or "postgres" (shared by all instances)
+// Each group limit is "<requests>/<period>[:<burst>]" such as "600/1m:100", or "off"
+type RateLimitConfig struct {
+	Store      string     `yaml:"store" env:"RATE_LIMIT_STORE"`
+	Auth       LimitValue `yaml:"auth" env:"RATE_LIMIT_AUTH"`               // /auth routes, per client IP
+	API        LimitValue `yaml:"api" env:"RATE_LIMIT_API"`                 // every authenticated route, per token subject
+	UserCreate LimitValue `yaml:"user_create" env:"RATE_LIMIT_USER_CREATE"` // POST /users on top of API
+}
+
+// FeaturesConfig switches optional parts of the API
+type FeaturesConfig struct {
+	Swagger    bool   `yaml:"swagger" env:"SWAGGER_ENABLED"` // serve the docs on /docs/
+	SwaggerURL string `yaml:"swagger_url" env:"SWAGGER_URL"` // where the docs UI loads doc.json from
+}
+
+// LimitValue is a rate limit setting - Limit is nil when the setting is "off"
+type LimitValue struct {
+	Limit *ratelimit.Limit
+}
+
+func (v *LimitValue) UnmarshalText(text []byte) error {
+	if string(text) == "off" {
+		v.Limit = nil
+		return nil
+	}
+
+	limit, err := ratelimit.ParseLimit(string(text))
+	if err != nil {
+		return err
 	}
-	return value
+	v.Limit = &limit
+	return nil
 }
 
+func (v LimitValue) MarshalText() ([]byte, error) {
+	if v.Limit == nil {
+		return []byte("off"), nil
+	}
+	return []byte(v.Limit.String()), nil
+}
 
+// Default returns the config used when nothing is set - fine for local development only
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port:               "8080",
+			ReadTimeout:        15 * time.Second,
+			WriteTimeout:       15 * time.Second,
+			IdleTimeout:        60 * time.Second,
+			RequestTimeout:     60 * time.Second,
+			ShutdownTimeout:    30 * time.Second,
+			HealthCheckTimeout: 2 * time.Second,
+			ShutdownDrainDelay: 5 * time.Second,
+		},
+		Database: DatabaseConfig{
+			Host:            "localhost",
+			Port:            "5432",
+			User:            "postgres",
+			Password:        "postgres",
+			Name:            "user_management",
+			SSLMode:         "prefer",
+			MaxConns:        25,
+			MinConns:        5,
+			MaxConnLifetime: 5 * time.Minute,
+			MaxConnIdleTime: 30 * time.Minute,
+			ConnectTimeout:  5 * time.Second,
+			TxIsolation:     "read committed",
+			TxMaxRetries:    3,
+		},
+		Log: LogConfig{
+			Format: "json",
+			Level:  "info",
+		},
+		Tracing: TracingConfig{
+			Exporter:     "none",
+			OTLPEndpoint: "http://localhost:4318",
+			ServiceName:  "user-management-api",
+		},
+		Auth: AuthConfig{
+			JWTClockSkew:    30 * time.Second,
+			AccessTokenTTL:  15 * time.Minute,
+			RefreshTokenTTL: 30 * 24 * time.Hour,
+		},
+		CORS: CORSConfig{
+			Default: CORSPolicy{
+				AllowedOrigins: []string{"*"},
+				AllowedMethods: []string{"GET", "POST", "PATCH", "DELETE"},
+				AllowedHeaders: []string{"Content-Type", "Authorization", "Idempotency-Key", "If-Match", "If-None-Match", "X-Org-ID", "traceparent"},
+				ExposedHeaders: []string{
+					"ETag", "Retry-After", "Idempotent-Replayed", "traceparent",
+					"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy",
+				},
+				MaxAge: 10 * time.Minute,
+			},
+		},
+		Tenancy: TenancyConfig{
+			Header:     "X-Org-ID",
+			DefaultOrg: "default",
+		},
+		Users: UsersConfig{
+			DeletedRetention: 30 * 24 * time.Hour,
+			PurgeInterval:    time.Hour,
+		},
+		Events: EventsConfig{
+			Publisher:    "log",
+			File:         "events.ndjson",
+			PollInterval: time.Second,
+			BatchSize:    100,
+			Retention:    7 * 24 * time.Hour,
+		},
+		Webhooks: WebhooksConfig{
+			PollInterval: time.Second,
+			Timeout:      10 * time.Second,
+			MaxAttempts:  8,
+			BackoffBase:  10 * time.Second,
+			BackoffMax:   time.Hour,
+			BatchSize:    20,
+		},
+		Mail: MailConfig{
+			Mailer:          "log",
+			File:            "mail.ndjson",
+			From:            "User Management <no-reply@localhost>",
+			SMTPPort:        "587",
+			Timeout:         10 * time.Second,
+			VerificationURL: "http://localhost:3000/verify-email",
+			VerificationTTL: 24 * time.Hour,
+			PollInterval:    time.Second,
+			BatchSize:       20,
+			MaxAttempts:     8,
+			BackoffBase:     10 * time.Second,
+			BackoffMax:      30 * time.Minute,
+		},
+		Import: ImportConfig{
+			PollInterval:  time.Second,
+			BatchSize:     500,
+			SyncMaxRows:   100,
+			MaxRows:       100000,
+			MaxBodySize:   32 << 20,
+			LeaseDuration: 5 * time.Minute,
+			Retention:     7 * 24 * time.Hour,
+		},
+		Idempotency: IdempotencyConfig{
+			KeyTTL: 24 * time.Hour,
+			Wait:   5 * time.Second,
+		},
+		RateLimit: RateLimitConfig{
+			Store:      "memory",
+			Auth:       mustLimit("20/1m:10"),
+			API:        mustLimit("600/1m:100"),
+			UserCreate: mustLimit("30/1m:10"),
+		},
+		Features: FeaturesConfig{
+			Swagger:    true,
+			SwaggerURL: "/docs/doc.json",
+		},
+	}
+}
+
+func mustLimit(s string) LimitValue {
+	var v LimitValue
+	if err := v.UnmarshalText([]byte(s)); err != nil {
+		panic(fmt.Sprintf("invalid default rate limit %q: %v", s, err))
+	}
+	return v
+}
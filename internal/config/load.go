@@ -0,0 +1,204 @@
+package config
+
+import (
+	"encoding"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Load builds the config from the defaults, the YAML file, the environment and the flags in args
+// (later layers win) and validates it. The file is given with -config or CONFIG_FILE.
+func Load(args []string) (*Config, error) {
+	cfg := Default()
+	all := settings(cfg)
+
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "YAML config file (env CONFIG_FILE)")
+
+	// Flags are applied after the file and the environment, so they are only collected here
+	type flagValue struct {
+		setting setting
+		raw     string
+	}
+	var flagValues []flagValue
+	for _, s := range all {
+		s := s
+		fs.Func(s.key, "env "+s.env, func(raw string) error {
+			flagValues = append(flagValues, flagValue{setting: s, raw: raw})
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+
+	if *configFile != "" {
+		if err := loadFile(cfg, *configFile); err != nil {
+			return nil, err
+		}
+	}
+
+	var p problems
+	for _, s := range all {
+		if raw := os.Getenv(s.env); raw != "" {
+			if err := s.set(raw); err != nil {
+				p.add(s.key, "invalid %s: %v", s.env, err)
+			}
+		}
+	}
+	for _, f := range flagValues {
+		if err := f.setting.set(f.raw); err != nil {
+			p.add(f.setting.key, "invalid -%s: %v", f.setting.key, err)
+		}
+	}
+	if len(p) > 0 {
+		return nil, &ValidationError{Problems: p}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadFile reads a YAML (or JSON) file over cfg - unknown keys are errors, so typos don't go unnoticed
+func loadFile(cfg *Config, path string) error {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".json":
+	default:
+		return fmt.Errorf("config file %s: use a .yaml, .yml or .json file", path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Print writes the effective config as YAML with the secrets redacted
+func (c *Config) Print(w io.Writer) error {
+	redacted := *c
+	for _, s := range settings(&redacted) {
+		if s.secret && s.value.String() != "" {
+			s.value.SetString("[REDACTED]")
+		}
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&redacted); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// setting is one leaf value of the config
+type setting struct {
+	key    string // YAML path such as server.port, also the flag name
+	env    string
+	secret bool
+	value  reflect.Value // the field, settable
+}
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// settings lists every leaf of cfg, in declaration order
+func settings(cfg *Config) []setting {
+	var result []setting
+
+	var walk func(v reflect.Value, prefix string)
+	walk = func(v reflect.Value, prefix string) {
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			key := prefix + field.Tag.Get("yaml")
+			value := v.Field(i)
+
+			// Maps (such as cors.routes) can only be set in the file
+			if field.Type.Kind() == reflect.Map {
+				continue
+			}
+
+			// Nested sections are walked, types that parse themselves are leaves
+			if field.Type.Kind() == reflect.Struct && !reflect.PointerTo(field.Type).Implements(textUnmarshalerType) {
+				walk(value, key+".")
+				continue
+			}
+
+			result = append(result, setting{
+				key:    key,
+				env:    field.Tag.Get("env"),
+				secret: field.Tag.Get("secret") == "true",
+				value:  value,
+			})
+		}
+	}
+	walk(reflect.ValueOf(cfg).Elem(), "")
+
+	return result
+}
+
+// set parses raw (from the environment or a flag) into the setting
+// Lists are comma separated, durations use Go syntax (30s, 5m, 24h)
+func (s setting) set(raw string) error {
+	if u, ok := s.value.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(raw))
+	}
+
+	switch {
+	case s.value.Type() == durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		s.value.SetInt(int64(d))
+	case s.value.Kind() == reflect.String:
+		s.value.SetString(raw)
+	case s.value.Kind() == reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", raw)
+		}
+		s.value.SetInt(int64(n))
+	case s.value.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", raw)
+		}
+		s.value.SetBool(b)
+	case s.value.Kind() == reflect.Slice && s.value.Type().Elem().Kind() == reflect.String:
+		list := []string{}
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		s.value.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("unsupported setting type %s", s.value.Type())
+	}
+	return nil
+}
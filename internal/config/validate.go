@@ -0,0 +1,276 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ValidationError lists every problem found in a config, so all of them can be fixed in one go
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid config:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// problems collects validation failures, each prefixed with the setting's YAML key
+type problems []string
+
+func (p *problems) add(key, format string, args ...interface{}) {
+	*p = append(*p, key+": "+fmt.Sprintf(format, args...))
+}
+
+func (p *problems) oneOf(key, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	p.add(key, "%q is not one of %s", value, strings.Join(allowed, ", "))
+}
+
+func (p *problems) positive(key string, d time.Duration) {
+	if d <= 0 {
+		p.add(key, "must be a positive duration such as 30s")
+	}
+}
+
+func (p *problems) notNegative(key string, d time.Duration) {
+	if d < 0 {
+		p.add(key, "must not be negative")
+	}
+}
+
+func (p *problems) port(key, value string) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 || n > 65535 {
+		p.add(key, "%q is not a port number", value)
+	}
+}
+
+func (p *problems) fileExists(key, path string) {
+	if path == "" {
+		return
+	}
+	if _, err := os.Stat(path); err != nil {
+		p.add(key, "%v", err)
+	}
+}
+
+func (p *problems) corsPolicy(key string, policy CORSPolicy) {
+	for _, origin := range policy.AllowedOrigins {
+		if origin == "*" {
+			if policy.AllowCredentials {
+				p.add(key+".allowed_origins", "\"*\" can't be combined with allow_credentials, list the origins")
+			}
+			continue
+		}
+		u, err := url.Parse(strings.Replace(origin, "*.", "x.", 1))
+		if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") || strings.Count(origin, "*") > 1 ||
+			(strings.Contains(origin, "*") && !strings.Contains(origin, "://*.")) {
+			p.add(key+".allowed_origins", "%q is not an origin such as https://app.example.com or https://*.example.com", origin)
+		}
+	}
+	for _, pattern := range policy.AllowedOriginPatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			p.add(key+".allowed_origin_patterns", "%v", err)
+		}
+	}
+	if len(policy.AllowedOrigins)+len(policy.AllowedOriginPatterns) > 0 && len(policy.AllowedMethods) == 0 {
+		p.add(key+".allowed_methods", "must list at least one method")
+	}
+	p.notNegative(key+".max_age", policy.MaxAge)
+}
+
+// Validate checks the whole config and returns a *ValidationError listing every problem
+func (c *Config) Validate() error {
+	var p problems
+
+	// Server
+	p.port("server.port", c.Server.Port)
+	if c.Server.AdminPort != "" {
+		p.port("server.admin_port", c.Server.AdminPort)
+		if c.Server.AdminPort == c.Server.Port {
+			p.add("server.admin_port", "must differ from server.port")
+		}
+	}
+	p.positive("server.read_timeout", c.Server.ReadTimeout)
+	p.positive("server.write_timeout", c.Server.WriteTimeout)
+	p.positive("server.idle_timeout", c.Server.IdleTimeout)
+	p.positive("server.request_timeout", c.Server.RequestTimeout)
+	p.positive("server.shutdown_timeout", c.Server.ShutdownTimeout)
+	p.positive("server.health_check_timeout", c.Server.HealthCheckTimeout)
+	p.notNegative("server.shutdown_drain_delay", c.Server.ShutdownDrainDelay)
+	for _, proxy := range c.Server.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			p.add("server.trusted_proxies", "%q is neither an IP nor a CIDR range", proxy)
+		}
+	}
+	if (c.Server.TLS.CertFile == "") != (c.Server.TLS.KeyFile == "") {
+		p.add("server.tls", "set both cert_file and key_file, or neither")
+	}
+	p.fileExists("server.tls.cert_file", c.Server.TLS.CertFile)
+	p.fileExists("server.tls.key_file", c.Server.TLS.KeyFile)
+
+	// Database
+	if c.Database.Host == "" {
+		p.add("database.host", "must be set")
+	}
+	p.port("database.port", c.Database.Port)
+	if c.Database.Name == "" {
+		p.add("database.name", "must be set")
+	}
+	p.oneOf("database.sslmode", c.Database.SSLMode, "disable", "allow", "prefer", "require", "verify-ca", "verify-full")
+	p.fileExists("database.sslrootcert", c.Database.SSLRootCert)
+	if c.Database.MaxConns < 1 {
+		p.add("database.max_conns", "must be at least 1")
+	}
+	if c.Database.MinConns < 0 || c.Database.MinConns > c.Database.MaxConns {
+		p.add("database.min_conns", "must be between 0 and database.max_conns")
+	}
+	p.positive("database.max_conn_lifetime", c.Database.MaxConnLifetime)
+	p.positive("database.max_conn_idle_time", c.Database.MaxConnIdleTime)
+	p.positive("database.connect_timeout", c.Database.ConnectTimeout)
+	p.oneOf("database.tx_isolation", c.Database.TxIsolation, "read committed", "repeatable read", "serializable")
+	if c.Database.TxMaxRetries < 0 {
+		p.add("database.tx_max_retries", "must not be negative")
+	}
+
+	// Logging and tracing
+	p.oneOf("log.format", c.Log.Format, "json", "text")
+	p.oneOf("log.level", strings.ToLower(c.Log.Level), "debug", "info", "warn", "error")
+	p.oneOf("tracing.exporter", c.Tracing.Exporter, "none", "stdout", "otlp")
+	if c.Tracing.Exporter == "otlp" {
+		if u, err := url.Parse(c.Tracing.OTLPEndpoint); err != nil || u.Scheme == "" || u.Host == "" {
+			p.add("tracing.otlp_endpoint", "%q is not a URL such as http://localhost:4318", c.Tracing.OTLPEndpoint)
+		}
+	}
+
+	// Auth
+	if c.Auth.JWTSecret == "" && c.Auth.JWTPublicKeyFile == "" && c.Auth.JWKSFile == "" {
+		p.add("auth", "set jwt_secret, jwt_public_key_file or jwks_file")
+	}
+	p.fileExists("auth.jwt_public_key_file", c.Auth.JWTPublicKeyFile)
+	p.fileExists("auth.jwks_file", c.Auth.JWKSFile)
+	p.fileExists("auth.jwt_private_key_file", c.Auth.JWTPrivateKeyFile)
+	p.notNegative("auth.jwt_clock_skew", c.Auth.JWTClockSkew)
+	p.positive("auth.access_token_ttl", c.Auth.AccessTokenTTL)
+	p.positive("auth.refresh_token_ttl", c.Auth.RefreshTokenTTL)
+
+	// Tenancy
+	if c.Tenancy.Header == "" && c.Tenancy.BaseDomain == "" && c.Tenancy.DefaultOrg == "" {
+		p.add("tenancy", "set header, base_domain or default_org, otherwise only tokens can name an organization and nobody can log in")
+	}
+	if strings.HasPrefix(c.Tenancy.BaseDomain, ".") || strings.Contains(c.Tenancy.BaseDomain, ":") {
+		p.add("tenancy.base_domain", "%q is not a domain such as users.example.com", c.Tenancy.BaseDomain)
+	}
+	// The policies hide every row from connections without app.org_id, so the jobs need their own role
+	if c.Tenancy.RowLevelSecurity && c.Database.JobsUser == "" {
+		p.add("database.jobs_user", "must be set with tenancy.row_level_security, background jobs need a role with BYPASSRLS")
+	}
+
+	// CORS
+	p.corsPolicy("cors.default", c.CORS.Default)
+	for prefix, policy := range c.CORS.Routes {
+		if !strings.HasPrefix(prefix, "/") {
+			p.add("cors.routes", "%q is not a path prefix such as /api/v1/auth", prefix)
+		}
+		p.corsPolicy("cors.routes."+prefix, policy)
+	}
+
+	// Background jobs
+	p.positive("users.deleted_retention", c.Users.DeletedRetention)
+	p.notNegative("users.purge_interval", c.Users.PurgeInterval)
+
+	p.oneOf("events.publisher", c.Events.Publisher, "log", "file")
+	if c.Events.Publisher == "file" && c.Events.File == "" {
+		p.add("events.file", "must be set when events.publisher is file")
+	}
+	p.notNegative("events.poll_interval", c.Events.PollInterval)
+	if c.Events.BatchSize < 1 {
+		p.add("events.batch_size", "must be at least 1")
+	}
+	p.notNegative("events.retention", c.Events.Retention)
+
+	p.notNegative("webhooks.poll_interval", c.Webhooks.PollInterval)
+	p.positive("webhooks.timeout", c.Webhooks.Timeout)
+	if c.Webhooks.MaxAttempts < 1 {
+		p.add("webhooks.max_attempts", "must be at least 1")
+	}
+	p.positive("webhooks.backoff_base", c.Webhooks.BackoffBase)
+	if c.Webhooks.BackoffMax < c.Webhooks.BackoffBase {
+		p.add("webhooks.backoff_max", "must not be less than webhooks.backoff_base")
+	}
+	if c.Webhooks.BatchSize < 1 {
+		p.add("webhooks.batch_size", "must be at least 1")
+	}
+
+	p.oneOf("mail.mailer", c.Mail.Mailer, "log", "file", "smtp")
+	if c.Mail.Mailer == "file" && c.Mail.File == "" {
+		p.add("mail.file", "must be set when mail.mailer is file")
+	}
+	if c.Mail.Mailer == "smtp" {
+		if c.Mail.SMTPHost == "" {
+			p.add("mail.smtp_host", "must be set when mail.mailer is smtp")
+		}
+		p.port("mail.smtp_port", c.Mail.SMTPPort)
+	}
+	if _, err := mail.ParseAddress(c.Mail.From); err != nil {
+		p.add("mail.from", "%q is not an address such as \"Acme <no-reply@acme.com>\"", c.Mail.From)
+	}
+	p.positive("mail.timeout", c.Mail.Timeout)
+	p.fileExists("mail.templates_dir", c.Mail.TemplatesDir)
+	if u, err := url.Parse(c.Mail.VerificationURL); err != nil || u.Scheme == "" || u.Host == "" {
+		p.add("mail.verification_url", "%q is not a URL such as https://app.example.com/verify-email", c.Mail.VerificationURL)
+	}
+	p.positive("mail.verification_ttl", c.Mail.VerificationTTL)
+	p.notNegative("mail.poll_interval", c.Mail.PollInterval)
+	if c.Mail.BatchSize < 1 {
+		p.add("mail.batch_size", "must be at least 1")
+	}
+	if c.Mail.MaxAttempts < 1 {
+		p.add("mail.max_attempts", "must be at least 1")
+	}
+	p.positive("mail.backoff_base", c.Mail.BackoffBase)
+	if c.Mail.BackoffMax < c.Mail.BackoffBase {
+		p.add("mail.backoff_max", "must not be less than mail.backoff_base")
+	}
+
+	p.notNegative("import.poll_interval", c.Import.PollInterval)
+	if c.Import.BatchSize < 1 {
+		p.add("import.batch_size", "must be at least 1")
+	}
+	if c.Import.SyncMaxRows < 0 {
+		p.add("import.sync_max_rows", "must not be negative")
+	}
+	if c.Import.MaxRows < 1 {
+		p.add("import.max_rows", "must be at least 1")
+	}
+	if c.Import.MaxBodySize < 1 {
+		p.add("import.max_body_size", "must be at least 1")
+	}
+	p.positive("import.lease_duration", c.Import.LeaseDuration)
+	p.notNegative("import.retention", c.Import.Retention)
+
+	p.positive("idempotency.key_ttl", c.Idempotency.KeyTTL)
+	p.notNegative("idempotency.wait", c.Idempotency.Wait)
+
+	p.oneOf("rate_limit.store", c.RateLimit.Store, "memory", "postgres")
+
+	if c.Features.Swagger && c.Features.SwaggerURL == "" {
+		p.add("features.swagger_url", "must be set when features.swagger is on")
+	}
+
+	if len(p) > 0 {
+		return &ValidationError{Problems: p}
+	}
+	return nil
+}
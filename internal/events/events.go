@@ -0,0 +1,105 @@
+package events
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event types published for user lifecycle changes
+const (
+	TypeUserCreated       = "UserCreated"
+	TypeUserUpdated       = "UserUpdated"
+	TypeUserStatusChanged = "UserStatusChanged"
+	TypeUserDeleted       = "UserDeleted"
+	TypeUserRestored      = "UserRestored"
+)
+
+// AggregateUser is the aggregate type of user events
+const AggregateUser = "user"
+
+// SchemaVersion is the version of the event envelope and payloads below
+// Bump it for breaking changes (removed or retyped fields) - adding fields is not breaking
+const SchemaVersion = 1
+
+// Event is the envelope every event is published in
+// Consumers should dedupe on ID, delivery is at-least-once
+type Event struct {
+	ID            uuid.UUID       `json:"id"`
+	Type          string          `json:"type"`
+	SchemaVersion int             `json:"schemaVersion"`
+	OccurredAt    time.Time       `json:"occurredAt"`
+	OrgID         uuid.UUID       `json:"orgId"` // the organization the aggregate belongs to
+	AggregateType string          `json:"aggregateType"`
+	AggregateID   uuid.UUID       `json:"aggregateId"` // events of one aggregate are published in the order they happened
+	Data          json.RawMessage `json:"data"`        // one of the payload types below, depending on Type
+}
+
+// User is the user as it appears in event payloads
+// Kept separate from the API models so the API can change without breaking consumers
+type User struct {
+	UserID          uuid.UUID  `json:"userId"`
+	FirstName       string     `json:"firstName"`
+	LastName        string     `json:"lastName"`
+	Email           string     `json:"email"`
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt,omitempty"`
+	Phone           *string    `json:"phone,omitempty"`
+	Age             *int       `json:"age,omitempty"`
+	Status          string     `json:"status"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	UpdatedAt       time.Time  `json:"updatedAt"`
+	DeletedAt       *time.Time `json:"deletedAt,omitempty"`
+}
+
+// UserCreated is the payload of TypeUserCreated
+type UserCreated struct {
+	User User `json:"user"`
+}
+
+// UserUpdated is the payload of TypeUserUpdated - updates that change the status are published as
+// TypeUserStatusChanged instead
+type UserUpdated struct {
+	User          User     `json:"user"`
+	ChangedFields []string `json:"changedFields"`
+}
+
+// UserStatusChanged is the payload of TypeUserStatusChanged, e.g. a deactivation
+// User and ChangedFields are as in UserUpdated, other fields may have changed in the same update
+type UserStatusChanged struct {
+	UserID        uuid.UUID `json:"userId"`
+	OldStatus     string    `json:"oldStatus"`
+	NewStatus     string    `json:"newStatus"`
+	User          User      `json:"user"`
+	ChangedFields []string  `json:"changedFields"`
+}
+
+// UserDeleted is the payload of TypeUserDeleted
+type UserDeleted struct {
+	UserID    uuid.UUID `json:"userId"`
+	DeletedAt time.Time `json:"deletedAt"`
+}
+
+// UserRestored is the payload of TypeUserRestored
+type UserRestored struct {
+	User User `json:"user"`
+}
+
+// New wraps a payload in an envelope with a fresh ID
+func New(eventType string, orgID uuid.UUID, aggregateType string, aggregateID uuid.UUID, data interface{}) (Event, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+
+	return Event{
+		ID:            uuid.New(),
+		Type:          eventType,
+		SchemaVersion: SchemaVersion,
+		OccurredAt:    time.Now().UTC(),
+		OrgID:         orgID,
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID,
+		Data:          payload,
+	}, nil
+}
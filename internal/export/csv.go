@@ -0,0 +1,81 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+type csvWriter struct {
+	out io.Writer
+	buf *bufio.Writer
+	csv *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	buf := bufio.NewWriter(w)
+	return &csvWriter{out: w, buf: buf, csv: csv.NewWriter(buf)}
+}
+
+func (c *csvWriter) WriteHeader(columns []string) error {
+	return c.csv.Write(columns)
+}
+
+func (c *csvWriter) WriteRow(values []interface{}) error {
+	record := make([]string, len(values))
+	for i, value := range values {
+		switch v := value.(type) {
+		case nil:
+		case string:
+			record[i] = escapeFormula(v)
+		case int:
+			record[i] = strconv.Itoa(v)
+		case int64:
+			record[i] = strconv.FormatInt(v, 10)
+		case bool:
+			record[i] = strconv.FormatBool(v)
+		case time.Time:
+			record[i] = formatTime(v)
+		default:
+			return fmt.Errorf("unsupported value type %T", value)
+		}
+	}
+	return c.csv.Write(record)
+}
+
+func (c *csvWriter) Flush() error {
+	c.csv.Flush()
+	if err := c.csv.Error(); err != nil {
+		return err
+	}
+	if err := c.buf.Flush(); err != nil {
+		return err
+	}
+	return flushUnderlying(c.out)
+}
+
+func (c *csvWriter) Close() error {
+	return c.Flush()
+}
+
+func (c *csvWriter) Abort() {}
+
+// escapeFormula keeps spreadsheets from running a text cell as a formula (CSV injection)
+// by prefixing a quote. Numbers such as +4915112345678 are left alone, they can't do harm.
+func escapeFormula(s string) string {
+	if s == "" {
+		return s
+	}
+	switch s[0] {
+	case '=', '@', '\t', '\r':
+		return "'" + s
+	case '+', '-':
+		if _, err := strconv.ParseFloat(s, 64); err != nil {
+			return "'" + s
+		}
+	}
+	return s
+}
@@ -0,0 +1,67 @@
+// Package export writes tables row by row as CSV, NDJSON or XLSX
+// CSV and NDJSON rows go straight to the underlying writer (through a small buffer), XLSX rows are spooled
+// by excelize until Close writes the workbook - an export of any size needs constant memory.
+package export
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Writer encodes a table - WriteHeader once, then WriteRow for every row, then Close
+// Values are nil, string, int, int64, bool or time.Time.
+type Writer interface {
+	WriteHeader(columns []string) error
+	WriteRow(values []interface{}) error
+	// Flush pushes the buffered rows to the underlying writer, and flushes it too when it has a Flush() error method
+	Flush() error
+	// Close ends the file and flushes - it doesn't close the underlying writer
+	Close() error
+	// Abort gives up on a file that won't be closed, releasing what it holds (XLSX keeps temporary files)
+	Abort()
+}
+
+// flusher is an io.Writer that can push its output on, such as a response writer
+type flusher interface {
+	Flush() error
+}
+
+// NewWriter returns the writer of format ("csv", "ndjson" or "xlsx")
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case "csv":
+		return newCSVWriter(w), nil
+	case "ndjson":
+		return newNDJSONWriter(w), nil
+	case "xlsx":
+		return newXLSXWriter(w)
+	}
+	return nil, fmt.Errorf("unsupported export format %q", format)
+}
+
+// ContentType returns the media type of format
+func ContentType(format string) string {
+	switch format {
+	case "csv":
+		return "text/csv; charset=utf-8"
+	case "ndjson":
+		return "application/x-ndjson"
+	case "xlsx":
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "application/octet-stream"
+}
+
+// flushUnderlying flushes w when it can be flushed
+func flushUnderlying(w io.Writer) error {
+	if f, ok := w.(flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// formatTime is how times are written to text cells
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,200 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+var (
+	testColumns = []string{"name", "age", "count", "verified", "createdAt", "phone"}
+	testTime    = time.Date(2024, 5, 1, 12, 30, 0, 0, time.FixedZone("CEST", 2*60*60))
+)
+
+// testRows have a value of every supported type, and text a spreadsheet would take for a formula
+var testRows = [][]interface{}{
+	{"Ada", 36, int64(7), true, testTime, "+4915112345678"},
+	{"=HYPERLINK(\"http://evil\")", nil, int64(0), false, testTime, nil},
+	{"@SUM(A1)", 1, int64(1), true, testTime, "-1.5"},
+	{"+cmd|' /C calc'!A0", 2, int64(2), false, testTime, "-x"},
+	{"\tTab", 3, int64(3), true, testTime, "\rReturn"},
+}
+
+// write encodes the test table in format
+func write(t *testing.T, format string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(format, &buf)
+	if err != nil {
+		t.Fatalf("NewWriter(%q): %v", format, err)
+	}
+	if err := w.WriteHeader(testColumns); err != nil {
+		t.Fatalf("WriteHeader: %v", err)
+	}
+	for _, row := range testRows {
+		if err := w.WriteRow(row); err != nil {
+			t.Fatalf("WriteRow: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestCSV_RoundTrip(t *testing.T) {
+	records, err := csv.NewReader(bytes.NewReader(write(t, "csv"))).ReadAll()
+	if err != nil {
+		t.Fatalf("reading the CSV: %v", err)
+	}
+
+	want := [][]string{
+		testColumns,
+		{"Ada", "36", "7", "true", "2024-05-01T10:30:00Z", "+4915112345678"},
+		{"'=HYPERLINK(\"http://evil\")", "", "0", "false", "2024-05-01T10:30:00Z", ""},
+		{"'@SUM(A1)", "1", "1", "true", "2024-05-01T10:30:00Z", "-1.5"},
+		{"'+cmd|' /C calc'!A0", "2", "2", "false", "2024-05-01T10:30:00Z", "'-x"},
+		{"'\tTab", "3", "3", "true", "2024-05-01T10:30:00Z", "'\rReturn"},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("records = %q, want %q", records, want)
+	}
+}
+
+func TestEscapeFormula(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"plain", "plain"},
+		{"=1+1", "'=1+1"},
+		{"@cmd", "'@cmd"},
+		{"\tx", "'\tx"},
+		{"\rx", "'\rx"},
+		{"+49 151", "'+49 151"},
+		{"+4915112345678", "+4915112345678"},
+		{"-42", "-42"},
+		{"-1e3", "-1e3"},
+		{"-x", "'-x"},
+		{"a=b", "a=b"},
+	}
+	for _, tt := range tests {
+		if got := escapeFormula(tt.in); got != tt.want {
+			t.Errorf("escapeFormula(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNDJSON_RoundTrip(t *testing.T) {
+	lines := strings.Split(strings.TrimSuffix(string(write(t, "ndjson")), "\n"), "\n")
+	if len(lines) != len(testRows) {
+		t.Fatalf("got %d lines, want %d", len(lines), len(testRows))
+	}
+
+	// Keys keep the column order
+	if !strings.HasPrefix(lines[0], `{"name":"Ada","age":36,"count":7,"verified":true,"createdAt":"2024-05-01T10:30:00Z","phone":`) {
+		t.Errorf("first line = %s", lines[0])
+	}
+
+	for i, line := range lines {
+		var got map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &got); err != nil {
+			t.Fatalf("line %d: %v", i+1, err)
+		}
+		row := testRows[i]
+		// Text is written as it is, JSON has no formulas to escape
+		if got["name"] != row[0] {
+			t.Errorf("line %d: name = %q, want %q", i+1, got["name"], row[0])
+		}
+		if got["createdAt"] != "2024-05-01T10:30:00Z" {
+			t.Errorf("line %d: createdAt = %v", i+1, got["createdAt"])
+		}
+		if row[1] == nil && got["age"] != nil {
+			t.Errorf("line %d: age = %v, want null", i+1, got["age"])
+		}
+	}
+}
+
+func TestNDJSON_RowLengthMismatch(t *testing.T) {
+	w, _ := NewWriter("ndjson", &bytes.Buffer{})
+	w.WriteHeader([]string{"a", "b"})
+	if err := w.WriteRow([]interface{}{"only one"}); err == nil {
+		t.Error("WriteRow with too few values succeeded")
+	}
+}
+
+func TestXLSX_RoundTrip(t *testing.T) {
+	f, err := excelize.OpenReader(bytes.NewReader(write(t, "xlsx")))
+	if err != nil {
+		t.Fatalf("opening the workbook: %v", err)
+	}
+	defer f.Close()
+
+	rows, err := f.GetRows(xlsxSheet)
+	if err != nil {
+		t.Fatalf("GetRows: %v", err)
+	}
+	want := [][]string{
+		testColumns,
+		{"Ada", "36", "7", "TRUE", "2024-05-01T10:30:00Z", "+4915112345678"},
+		{"=HYPERLINK(\"http://evil\")", "", "0", "FALSE", "2024-05-01T10:30:00Z"},
+		{"@SUM(A1)", "1", "1", "TRUE", "2024-05-01T10:30:00Z", "-1.5"},
+		{"+cmd|' /C calc'!A0", "2", "2", "FALSE", "2024-05-01T10:30:00Z", "-x"},
+		{"\tTab", "3", "3", "TRUE", "2024-05-01T10:30:00Z", "\rReturn"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %q, want %q", rows, want)
+	}
+
+	// Text that looks like a formula is stored as text, not as a formula
+	for _, cell := range []string{"A3", "A4", "A5"} {
+		formula, err := f.GetCellFormula(xlsxSheet, cell)
+		if err != nil {
+			t.Fatalf("GetCellFormula(%s): %v", cell, err)
+		}
+		if formula != "" {
+			t.Errorf("%s has formula %q", cell, formula)
+		}
+		cellType, _ := f.GetCellType(xlsxSheet, cell)
+		if cellType != excelize.CellTypeInlineString {
+			t.Errorf("%s has type %v, want an inline string", cell, cellType)
+		}
+	}
+}
+
+func TestXLSX_CloseBeforeHeader(t *testing.T) {
+	w, err := NewWriter("xlsx", &bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err == nil {
+		t.Error("Close before WriteHeader succeeded")
+	}
+}
+
+func TestXLSX_UnsupportedValue(t *testing.T) {
+	w, err := NewWriter("xlsx", &bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteHeader([]string{"a"})
+	if err := w.WriteRow([]interface{}{1.5}); err == nil {
+		t.Fatal("WriteRow with a float succeeded")
+	}
+	// The first error sticks
+	if err := w.Close(); err == nil {
+		t.Error("Close after a failed row succeeded")
+	}
+}
+
+func TestNewWriter_UnsupportedFormat(t *testing.T) {
+	if _, err := NewWriter("pdf", &bytes.Buffer{}); err == nil {
+		t.Error("NewWriter(\"pdf\") succeeded")
+	}
+}
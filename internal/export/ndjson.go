@@ -0,0 +1,67 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ndjsonWriter writes every row as a JSON object keyed by the column names
+type ndjsonWriter struct {
+	out     io.Writer
+	buf     *bufio.Writer
+	columns []string
+}
+
+func newNDJSONWriter(w io.Writer) *ndjsonWriter {
+	return &ndjsonWriter{out: w, buf: bufio.NewWriter(w)}
+}
+
+func (n *ndjsonWriter) WriteHeader(columns []string) error {
+	n.columns = columns
+	return nil
+}
+
+func (n *ndjsonWriter) WriteRow(values []interface{}) error {
+	if len(values) != len(n.columns) {
+		return fmt.Errorf("row has %d values for %d columns", len(values), len(n.columns))
+	}
+
+	// Built by hand to keep the column order, a map would sort the keys
+	n.buf.WriteByte('{')
+	for i, value := range values {
+		if i > 0 {
+			n.buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(n.columns[i]) // strings always marshal
+		n.buf.Write(key)
+		n.buf.WriteByte(':')
+
+		if t, ok := value.(time.Time); ok {
+			value = formatTime(t)
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		n.buf.Write(data)
+	}
+	n.buf.WriteByte('}')
+	_, err := n.buf.WriteString("\n")
+	return err
+}
+
+func (n *ndjsonWriter) Flush() error {
+	if err := n.buf.Flush(); err != nil {
+		return err
+	}
+	return flushUnderlying(n.out)
+}
+
+func (n *ndjsonWriter) Close() error {
+	return n.Flush()
+}
+
+func (n *ndjsonWriter) Abort() {}
@@ -0,0 +1,115 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// MaxXLSXRows is the row limit of a spreadsheet, the header included
+const MaxXLSXRows = excelize.TotalRows
+
+// ErrTooManyRows means the table doesn't fit in one spreadsheet
+var ErrTooManyRows = errors.New("export has more rows than a spreadsheet can hold")
+
+const xlsxSheet = "Sheet1"
+
+// xlsxWriter writes the sheet with excelize's StreamWriter
+// A zip can only be written once the sheet is complete, so the workbook goes out in Close - until then
+// the stream writer holds the rows, in a temporary file once they outgrow its memory buffer.
+// Text is always written as an inline string, never as a formula, and times as text like the other formats.
+type xlsxWriter struct {
+	out    io.Writer
+	file   *excelize.File
+	stream *excelize.StreamWriter
+	rows   int
+	err    error // the first error, later calls return it
+}
+
+func newXLSXWriter(w io.Writer) (*xlsxWriter, error) {
+	file := excelize.NewFile()
+	stream, err := file.NewStreamWriter(xlsxSheet)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &xlsxWriter{out: w, file: file, stream: stream}, nil
+}
+
+func (x *xlsxWriter) WriteHeader(columns []string) error {
+	values := make([]interface{}, len(columns))
+	for i, column := range columns {
+		values[i] = column
+	}
+	return x.WriteRow(values)
+}
+
+func (x *xlsxWriter) WriteRow(values []interface{}) error {
+	if x.err != nil {
+		return x.err
+	}
+	if x.rows == MaxXLSXRows {
+		return x.fail(ErrTooManyRows)
+	}
+
+	cells := make([]interface{}, len(values))
+	for i, value := range values {
+		switch v := value.(type) {
+		case nil, string, int, int64, bool:
+			cells[i] = v
+		case time.Time:
+			cells[i] = formatTime(v)
+		default:
+			return x.fail(fmt.Errorf("unsupported value type %T", value))
+		}
+	}
+
+	x.rows++
+	cell, err := excelize.CoordinatesToCellName(1, x.rows)
+	if err != nil {
+		return x.fail(err)
+	}
+	return x.fail(x.stream.SetRow(cell, cells))
+}
+
+// Flush has nothing to push, the workbook is written by Close
+func (x *xlsxWriter) Flush() error {
+	return x.err
+}
+
+func (x *xlsxWriter) Close() error {
+	if x.err != nil {
+		return x.err
+	}
+	if x.rows == 0 {
+		return x.fail(errors.New("close before WriteHeader"))
+	}
+	if err := x.stream.Flush(); err != nil {
+		return x.fail(err)
+	}
+	if err := x.file.Write(x.out); err != nil {
+		return x.fail(err)
+	}
+	if err := x.file.Close(); err != nil {
+		return x.fail(err)
+	}
+	return x.fail(flushUnderlying(x.out))
+}
+
+// Abort removes the temporary files of the rows written so far
+func (x *xlsxWriter) Abort() {
+	x.file.Close()
+}
+
+// fail records the first error and returns it
+// The workbook can't be finished after an error, so its temporary files are removed right away.
+func (x *xlsxWriter) fail(err error) error {
+	if err != nil && x.err == nil {
+		x.err = err
+		x.file.Close()
+	}
+	return err
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"net/http"
+
+	"user-management-api/internal/models"
+	"user-management-api/internal/service"
+	"user-management-api/internal/validator"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type AuditHandler struct {
+	service   *service.AuditService
+	validator *validator.Validator
+}
+
+func NewAuditHandler(service *service.AuditService, validator *validator.Validator) *AuditHandler {
+	return &AuditHandler{
+		service:   service,
+		validator: validator,
+	}
+}
+
+// ListAuditEvents lists audit events across all users
+// @Summary List audit events
+// @Description Get a page of audit events, newest first, using cursor-based pagination
+// @Tags audit
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param limit query int false "Page size (1-100)" default(20)
+// @Param cursor query string false "nextCursor from the previous page"
+// @Param actor_id query string false "Only events caused by this caller (JWT subject)"
+// @Param from query string false "Only events at or after this time (RFC 3339)"
+// @Param to query string false "Only events before this time (RFC 3339)"
+// @Success 200 {object} models.ListAuditEventsResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 429 {object} models.Problem "Rate limit exceeded"
+// @Failure 500 {object} models.Problem
+// @Router /audit [get]
+func (h *AuditHandler) ListAuditEvents(w http.ResponseWriter, r *http.Request) {
+	h.listEvents(w, r, nil)
+}
+
+// ListUserAuditEvents lists the audit events of one user
+// @Summary List a user's audit events
+// @Description Get a page of the audit events of a user, newest first - deleted users keep their history
+// @Tags audit
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID (UUID)"
+// @Param limit query int false "Page size (1-100)" default(20)
+// @Param cursor query string false "nextCursor from the previous page"
+// @Param actor_id query string false "Only events caused by this caller (JWT subject)"
+// @Param from query string false "Only events at or after this time (RFC 3339)"
+// @Param to query string false "Only events before this time (RFC 3339)"
+// @Success 200 {object} models.ListAuditEventsResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 429 {object} models.Problem "Rate limit exceeded"
+// @Failure 500 {object} models.Problem
+// @Router /users/{id}/audit [get]
+func (h *AuditHandler) ListUserAuditEvents(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		sendError(w, r, models.NewBadRequestError(models.CodeInvalidUserID, "Invalid user ID format"))
+		return
+	}
+
+	h.listEvents(w, r, &userID)
+}
+
+// listEvents serves both audit endpoints - userID is nil for the global one
+func (h *AuditHandler) listEvents(w http.ResponseWriter, r *http.Request, userID *uuid.UUID) {
+	query, queryErrors := parseListAuditEventsQuery(r.URL.Query())
+	if queryErrors != nil {
+		sendValidationError(w, r, queryErrors)
+		return
+	}
+	query.UserID = userID
+
+	if validationErrors := h.validator.ValidateStruct(query); validationErrors != nil {
+		sendValidationError(w, r, validationErrors)
+		return
+	}
+
+	events, err := h.service.ListEvents(r.Context(), query)
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	sendJSON(w, http.StatusOK, events)
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"user-management-api/internal/models"
+	"user-management-api/internal/service"
+	"user-management-api/internal/validator"
+)
+
+type AuthHandler struct {
+	service   *service.AuthService
+	validator *validator.Validator
+}
+
+func NewAuthHandler(service *service.AuthService, validator *validator.Validator) *AuthHandler {
+	return &AuthHandler{
+		service:   service,
+		validator: validator,
+	}
+}
+
+// Login signs a user in
+// @Summary Log in
+// @Description Exchange an email and password for an access token and a refresh token
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param credentials body models.LoginRequest true "Email and password"
+// @Success 200 {object} models.TokenResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 429 {object} models.Problem "Rate limit exceeded"
+// @Failure 500 {object} models.Problem
+// @Router /auth/login [post]
+func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
+	var req models.LoginRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, r, models.NewBadRequestError(models.CodeInvalidRequestBody, "Invalid request body"))
+		return
+	}
+
+	if validationErrors := h.validator.ValidateStruct(req); validationErrors != nil {
+		sendValidationError(w, r, validationErrors)
+		return
+	}
+
+	tokens, err := h.service.Login(r.Context(), req)
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	sendJSON(w, http.StatusOK, tokens)
+}
+
+// Refresh rotates a refresh token
+// @Summary Refresh tokens
+// @Description Exchange a refresh token for a new access token and refresh token. Each refresh token can be used once; reusing one revokes the whole session.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param token body models.RefreshTokenRequest true "Refresh token"
+// @Success 200 {object} models.TokenResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 429 {object} models.Problem "Rate limit exceeded"
+// @Failure 500 {object} models.Problem
+// @Router /auth/refresh [post]
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req models.RefreshTokenRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, r, models.NewBadRequestError(models.CodeInvalidRequestBody, "Invalid request body"))
+		return
+	}
+
+	if validationErrors := h.validator.ValidateStruct(req); validationErrors != nil {
+		sendValidationError(w, r, validationErrors)
+		return
+	}
+
+	tokens, err := h.service.Refresh(r.Context(), req)
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	sendJSON(w, http.StatusOK, tokens)
+}
+
+// Logout ends a session
+// @Summary Log out
+// @Description Revoke the refresh token and every token rotated from the same login
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param token body models.RefreshTokenRequest true "Refresh token"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.Problem
+// @Failure 429 {object} models.Problem "Rate limit exceeded"
+// @Failure 500 {object} models.Problem
+// @Router /auth/logout [post]
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	var req models.RefreshTokenRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, r, models.NewBadRequestError(models.CodeInvalidRequestBody, "Invalid request body"))
+		return
+	}
+
+	if validationErrors := h.validator.ValidateStruct(req); validationErrors != nil {
+		sendValidationError(w, r, validationErrors)
+		return
+	}
+
+	if err := h.service.Logout(r.Context(), req); err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	sendJSON(w, http.StatusOK, models.SuccessResponse{
+		Message: "Logged out successfully",
+	})
+}
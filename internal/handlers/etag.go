@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// userETag is the strong ETag of a user version, for example "3"
+func userETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// parseIfMatch reads the If-Match header of a write
+// It returns nil when any version is fine (no header, or "*"), otherwise the version the client expects.
+// ok is false when the header can never match one of our ETags (weak or malformed tags, or a list) -
+// the request then has to fail with 412.
+func parseIfMatch(r *http.Request) (*int, bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return nil, true
+	}
+
+	// If-Match uses strong comparison, so W/"3" never matches
+	if !strings.HasPrefix(header, `"`) || !strings.HasSuffix(header, `"`) || len(header) < 2 {
+		return nil, false
+	}
+
+	version, err := strconv.Atoi(header[1 : len(header)-1])
+	if err != nil {
+		return nil, false
+	}
+	return &version, true
+}
+
+// ifNoneMatch reports whether the If-None-Match header matches the current ETag
+// If-None-Match uses weak comparison, so W/"3" matches "3"
+func ifNoneMatch(r *http.Request, etag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,345 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"user-management-api/internal/models"
+	"user-management-api/internal/service"
+	"user-management-api/internal/validator"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type GroupHandler struct {
+	service   *service.GroupService
+	validator *validator.Validator
+}
+
+func NewGroupHandler(service *service.GroupService, validator *validator.Validator) *GroupHandler {
+	return &GroupHandler{
+		service:   service,
+		validator: validator,
+	}
+}
+
+// CreateGroup adds a group
+// @Summary Create a group
+// @Description Add a group (team) to the organization. Names are unique within the organization.
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param group body models.CreateGroupRequest true "Group to create"
+// @Success 201 {object} models.GroupResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 409 {object} models.Problem "Group name already exists"
+// @Failure 429 {object} models.Problem "Rate limit exceeded"
+// @Failure 500 {object} models.Problem
+// @Router /groups [post]
+func (h *GroupHandler) CreateGroup(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, r, models.NewBadRequestError(models.CodeInvalidRequestBody, "Invalid request body"))
+		return
+	}
+
+	if validationErrors := h.validator.ValidateStruct(req); validationErrors != nil {
+		sendValidationError(w, r, validationErrors)
+		return
+	}
+
+	group, err := h.service.CreateGroup(r.Context(), req)
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	sendJSON(w, http.StatusCreated, group)
+}
+
+// ListGroups lists the groups
+// @Summary List groups
+// @Description Get every group of the organization, ordered by name
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.ListGroupsResponse
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 429 {object} models.Problem "Rate limit exceeded"
+// @Failure 500 {object} models.Problem
+// @Router /groups [get]
+func (h *GroupHandler) ListGroups(w http.ResponseWriter, r *http.Request) {
+	groups, err := h.service.ListGroups(r.Context())
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	sendJSON(w, http.StatusOK, groups)
+}
+
+// GetGroup retrieves a group
+// @Summary Get a group
+// @Description Get a group by ID
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Group ID (UUID)"
+// @Success 200 {object} models.GroupResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 429 {object} models.Problem "Rate limit exceeded"
+// @Failure 500 {object} models.Problem
+// @Router /groups/{id} [get]
+func (h *GroupHandler) GetGroup(w http.ResponseWriter, r *http.Request) {
+	group, err := h.service.GetGroup(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	sendJSON(w, http.StatusOK, group)
+}
+
+// UpdateGroup updates a group
+// @Summary Update a group
+// @Description Change the name or description of a group
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Group ID (UUID)"
+// @Param group body models.UpdateGroupRequest true "Fields to update"
+// @Success 200 {object} models.GroupResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 409 {object} models.Problem "Group name already exists"
+// @Failure 429 {object} models.Problem "Rate limit exceeded"
+// @Failure 500 {object} models.Problem
+// @Router /groups/{id} [patch]
+func (h *GroupHandler) UpdateGroup(w http.ResponseWriter, r *http.Request) {
+	var req models.UpdateGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, r, models.NewBadRequestError(models.CodeInvalidRequestBody, "Invalid request body"))
+		return
+	}
+
+	if validationErrors := h.validator.ValidateStruct(req); validationErrors != nil {
+		sendValidationError(w, r, validationErrors)
+		return
+	}
+
+	group, err := h.service.UpdateGroup(r.Context(), chi.URLParam(r, "id"), req)
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	sendJSON(w, http.StatusOK, group)
+}
+
+// DeleteGroup deletes a group
+// @Summary Delete a group
+// @Description Delete a group and its memberships. The members themselves are not affected.
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Group ID (UUID)"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 429 {object} models.Problem "Rate limit exceeded"
+// @Failure 500 {object} models.Problem
+// @Router /groups/{id} [delete]
+func (h *GroupHandler) DeleteGroup(w http.ResponseWriter, r *http.Request) {
+	if err := h.service.DeleteGroup(r.Context(), chi.URLParam(r, "id")); err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	sendJSON(w, http.StatusOK, models.SuccessResponse{
+		Message: "Group deleted successfully",
+	})
+}
+
+// ListMembers lists the members of a group
+// @Summary List group members
+// @Description Get a page of the members of a group in the order they joined, using cursor-based pagination
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Group ID (UUID)"
+// @Param limit query int false "Page size (1-100)" default(20)
+// @Param cursor query string false "nextCursor from the previous page"
+// @Success 200 {object} models.ListGroupMembersResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 429 {object} models.Problem "Rate limit exceeded"
+// @Failure 500 {object} models.Problem
+// @Router /groups/{id}/members [get]
+func (h *GroupHandler) ListMembers(w http.ResponseWriter, r *http.Request) {
+	query, queryErrors := parseListGroupMembersQuery(r.URL.Query())
+	if queryErrors != nil {
+		sendValidationError(w, r, queryErrors)
+		return
+	}
+
+	if validationErrors := h.validator.ValidateStruct(query); validationErrors != nil {
+		sendValidationError(w, r, validationErrors)
+		return
+	}
+
+	members, err := h.service.ListMembers(r.Context(), chi.URLParam(r, "id"), query)
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	sendJSON(w, http.StatusOK, members)
+}
+
+// AddMembers adds users to a group in bulk
+// @Summary Add group members
+// @Description Add up to 1000 users to a group, or change the role of users that are already members. Unknown and deleted users are skipped and listed in notFound. Requires groups:manage or being an owner of the group.
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Group ID (UUID)"
+// @Param members body models.AddGroupMembersRequest true "Users to add with their role (member when omitted)"
+// @Success 200 {object} models.GroupMembersChangeResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 429 {object} models.Problem "Rate limit exceeded"
+// @Failure 500 {object} models.Problem
+// @Router /groups/{id}/members [post]
+func (h *GroupHandler) AddMembers(w http.ResponseWriter, r *http.Request) {
+	var req models.AddGroupMembersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, r, models.NewBadRequestError(models.CodeInvalidRequestBody, "Invalid request body"))
+		return
+	}
+
+	if validationErrors := h.validator.ValidateStruct(req); validationErrors != nil {
+		sendValidationError(w, r, validationErrors)
+		return
+	}
+
+	result, err := h.service.AddMembers(r.Context(), chi.URLParam(r, "id"), req)
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	sendJSON(w, http.StatusOK, result)
+}
+
+// RemoveMembers removes users from a group in bulk
+// @Summary Remove group members
+// @Description Remove up to 1000 users from a group. Users that aren't members are listed in notFound. Requires groups:manage or being an owner of the group.
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Group ID (UUID)"
+// @Param members body models.RemoveGroupMembersRequest true "Users to remove"
+// @Success 200 {object} models.GroupMembersChangeResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 429 {object} models.Problem "Rate limit exceeded"
+// @Failure 500 {object} models.Problem
+// @Router /groups/{id}/members/remove [post]
+func (h *GroupHandler) RemoveMembers(w http.ResponseWriter, r *http.Request) {
+	var req models.RemoveGroupMembersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, r, models.NewBadRequestError(models.CodeInvalidRequestBody, "Invalid request body"))
+		return
+	}
+
+	if validationErrors := h.validator.ValidateStruct(req); validationErrors != nil {
+		sendValidationError(w, r, validationErrors)
+		return
+	}
+
+	result, err := h.service.RemoveMembers(r.Context(), chi.URLParam(r, "id"), req)
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	sendJSON(w, http.StatusOK, result)
+}
+
+// RemoveMember removes one user from a group
+// @Summary Remove a group member
+// @Description Remove a user from a group. Requires groups:manage or being an owner of the group.
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Group ID (UUID)"
+// @Param userId path string true "User ID (UUID)"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 404 {object} models.Problem "Group not found or user is not a member"
+// @Failure 429 {object} models.Problem "Rate limit exceeded"
+// @Failure 500 {object} models.Problem
+// @Router /groups/{id}/members/{userId} [delete]
+func (h *GroupHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	if err := h.service.RemoveMember(r.Context(), chi.URLParam(r, "id"), chi.URLParam(r, "userId")); err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	sendJSON(w, http.StatusOK, models.SuccessResponse{
+		Message: "Member removed successfully",
+	})
+}
+
+// ListUserGroups lists the groups of a user
+// @Summary List a user's groups
+// @Description Get the groups a user is a member of, with their role in each, ordered by group name
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID (UUID)"
+// @Success 200 {object} models.UserGroupsResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 429 {object} models.Problem "Rate limit exceeded"
+// @Failure 500 {object} models.Problem
+// @Router /users/{id}/groups [get]
+func (h *GroupHandler) ListUserGroups(w http.ResponseWriter, r *http.Request) {
+	groups, err := h.service.ListUserGroups(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	sendJSON(w, http.StatusOK, groups)
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net/http"
+
+	"user-management-api/internal/health"
+)
+
+// HealthHandler serves the probes - they live outside /api/v1, so they are not in the Swagger docs
+type HealthHandler struct {
+	checks *health.Registry
+}
+
+func NewHealthHandler(checks *health.Registry) *HealthHandler {
+	return &HealthHandler{checks: checks}
+}
+
+// Livez reports that the process is up and serving HTTP - it checks no dependencies,
+// so a database outage doesn't make the orchestrator restart every instance
+func (h *HealthHandler) Livez(w http.ResponseWriter, r *http.Request) {
+	sendJSON(w, http.StatusOK, map[string]string{"status": health.StatusOK})
+}
+
+// Readyz runs the readiness checks - 503 takes the instance out of the load balancer
+// until its dependencies are back, and for good once shutdown has started
+func (h *HealthHandler) Readyz(w http.ResponseWriter, r *http.Request) {
+	report := h.checks.Run(r.Context())
+
+	// Probes must always see the current state
+	w.Header().Set("Cache-Control", "no-store")
+
+	status := http.StatusOK
+	if report.Status != health.StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	sendJSON(w, status, report)
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+
+	"user-management-api/internal/models"
+	"user-management-api/internal/service"
+	"user-management-api/internal/validator"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type ImportHandler struct {
+	service     *service.ImportService
+	validator   *validator.Validator
+	maxBodySize int64
+}
+
+func NewImportHandler(service *service.ImportService, validator *validator.Validator, maxBodySize int64) *ImportHandler {
+	return &ImportHandler{
+		service:     service,
+		validator:   validator,
+		maxBodySize: maxBodySize,
+	}
+}
+
+// Content types accepted for each import format
+var importContentTypes = map[string]string{
+	"text/csv":             models.ImportFormatCSV,
+	"application/x-ndjson": models.ImportFormatNDJSON,
+	"application/ndjson":   models.ImportFormatNDJSON,
+}
+
+// ImportUsers creates or updates users from a CSV or NDJSON file
+// @Summary Import users
+// @Description Create users from a CSV file (header row, columns firstName, lastName, email, phone, age, status, password) or NDJSON (one user JSON object per line). Header names are matched ignoring case, spaces, "_" and "-"; use mapping to rename or ignore other columns. Every row is validated like POST /users and reported with its line number.
+// @Description on_conflict decides what happens to rows whose email is registered: skip them, update the user from them (empty cells keep the current value, passwords are never changed), or fail (the default), which imports nothing when any row fails.
+// @Description Imports of up to import.sync_max_rows rows run right away and return 200 with the report. Larger ones, and any with async=true, return 202 with a job to poll at the Location header.
+// @Tags users
+// @Accept text/csv
+// @Accept application/x-ndjson
+// @Produce json
+// @Security BearerAuth
+// @Param file body string true "CSV or NDJSON file"
+// @Param format query string false "File format, taken from Content-Type when missing" Enums(csv, ndjson)
+// @Param dry_run query bool false "Validate and report what would happen without writing anything"
+// @Param on_conflict query string false "What to do with rows whose email is registered" Enums(skip, update, fail) default(fail)
+// @Param mapping query string false "CSV column mapping as header:field pairs, field - ignores the column (e.g. Given Name:firstName,Notes:-)"
+// @Param async query bool false "Run the import as a job even when it is small"
+// @Success 200 {object} models.ImportReport
+// @Success 202 {object} models.ImportJobResponse
+// @Header 202 {string} Location "URL of the import job"
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 413 {object} models.Problem
+// @Failure 415 {object} models.Problem
+// @Failure 429 {object} models.Problem "Rate limit exceeded"
+// @Failure 500 {object} models.Problem
+// @Router /users/import [post]
+func (h *ImportHandler) ImportUsers(w http.ResponseWriter, r *http.Request) {
+	query, queryErrors := parseImportUsersQuery(r.URL.Query())
+	if queryErrors != nil {
+		sendValidationError(w, r, queryErrors)
+		return
+	}
+
+	if validationErrors := h.validator.ValidateStruct(query); validationErrors != nil {
+		sendValidationError(w, r, validationErrors)
+		return
+	}
+
+	if query.Format == "" {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		format, ok := importContentTypes[mediaType]
+		if !ok {
+			sendError(w, r, &models.AppError{
+				StatusCode: http.StatusUnsupportedMediaType,
+				Code:       models.CodeUnsupportedMediaType,
+				Message:    "Send text/csv or application/x-ndjson, or set the format parameter",
+			})
+			return
+		}
+		query.Format = format
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.maxBodySize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			sendError(w, r, &models.AppError{
+				StatusCode: http.StatusRequestEntityTooLarge,
+				Code:       models.CodeRequestTooLarge,
+				Message:    "Import file is too large",
+			})
+			return
+		}
+		sendError(w, r, models.NewBadRequestError(models.CodeInvalidRequestBody, "Failed to read the import file"))
+		return
+	}
+
+	report, job, err := h.service.ImportUsers(r.Context(), query, data)
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	if job != nil {
+		w.Header().Set("Location", "/api/v1/users/import/"+job.JobID.String())
+		sendJSON(w, http.StatusAccepted, job)
+		return
+	}
+	sendJSON(w, http.StatusOK, report)
+}
+
+// GetImportJob returns the progress and row report of an import job
+// @Summary Get an import job
+// @Description Get the status and counts of an import job, with its row report paged by line number. Rows appear as the job writes each batch.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param jobId path string true "Import job ID"
+// @Param limit query int false "Rows of the report per page" minimum(1) maximum(1000) default(100)
+// @Param cursor query string false "nextCursor from the previous page"
+// @Success 200 {object} models.ImportJobResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 429 {object} models.Problem "Rate limit exceeded"
+// @Failure 500 {object} models.Problem
+// @Router /users/import/{jobId} [get]
+func (h *ImportHandler) GetImportJob(w http.ResponseWriter, r *http.Request) {
+	query, queryErrors := parseGetImportJobQuery(r.URL.Query())
+	if queryErrors != nil {
+		sendValidationError(w, r, queryErrors)
+		return
+	}
+
+	if validationErrors := h.validator.ValidateStruct(query); validationErrors != nil {
+		sendValidationError(w, r, validationErrors)
+		return
+	}
+
+	job, err := h.service.GetImportJob(r.Context(), chi.URLParam(r, "jobId"), query)
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	sendJSON(w, http.StatusOK, job)
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"user-management-api/internal/models"
+	"user-management-api/internal/service"
+	"user-management-api/internal/validator"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type OrgHandler struct {
+	service   *service.OrgService
+	validator *validator.Validator
+}
+
+func NewOrgHandler(service *service.OrgService, validator *validator.Validator) *OrgHandler {
+	return &OrgHandler{
+		service:   service,
+		validator: validator,
+	}
+}
+
+// CreateOrganization adds a tenant
+// @Summary Create an organization
+// @Description Add an organization. Its users are reached with the slug as subdomain, or the slug or ID in the X-Org-ID header.
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param organization body models.CreateOrganizationRequest true "Organization to create"
+// @Success 201 {object} models.OrganizationResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 409 {object} models.Problem "Slug already exists"
+// @Failure 429 {object} models.Problem "Rate limit exceeded"
+// @Failure 500 {object} models.Problem
+// @Router /orgs [post]
+func (h *OrgHandler) CreateOrganization(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateOrganizationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, r, models.NewBadRequestError(models.CodeInvalidRequestBody, "Invalid request body"))
+		return
+	}
+
+	if validationErrors := h.validator.ValidateStruct(req); validationErrors != nil {
+		sendValidationError(w, r, validationErrors)
+		return
+	}
+
+	org, err := h.service.CreateOrganization(r.Context(), req)
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	sendJSON(w, http.StatusCreated, org)
+}
+
+// ListOrganizations lists the tenants
+// @Summary List organizations
+// @Description Get every organization, ordered by slug
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.ListOrganizationsResponse
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 429 {object} models.Problem "Rate limit exceeded"
+// @Failure 500 {object} models.Problem
+// @Router /orgs [get]
+func (h *OrgHandler) ListOrganizations(w http.ResponseWriter, r *http.Request) {
+	orgs, err := h.service.ListOrganizations(r.Context())
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	sendJSON(w, http.StatusOK, orgs)
+}
+
+// GetOrganization retrieves a tenant
+// @Summary Get an organization
+// @Description Get an organization by ID
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Organization ID (UUID)"
+// @Success 200 {object} models.OrganizationResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 429 {object} models.Problem "Rate limit exceeded"
+// @Failure 500 {object} models.Problem
+// @Router /orgs/{id} [get]
+func (h *OrgHandler) GetOrganization(w http.ResponseWriter, r *http.Request) {
+	org, err := h.service.GetOrganization(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	sendJSON(w, http.StatusOK, org)
+}
+
+// UpdateOrganization updates a tenant
+// @Summary Update an organization
+// @Description Change the slug or name of an organization. A new slug is a new subdomain, the old one stops working.
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Organization ID (UUID)"
+// @Param organization body models.UpdateOrganizationRequest true "Fields to update"
+// @Success 200 {object} models.OrganizationResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 409 {object} models.Problem "Slug already exists"
+// @Failure 429 {object} models.Problem "Rate limit exceeded"
+// @Failure 500 {object} models.Problem
+// @Router /orgs/{id} [patch]
+func (h *OrgHandler) UpdateOrganization(w http.ResponseWriter, r *http.Request) {
+	var req models.UpdateOrganizationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, r, models.NewBadRequestError(models.CodeInvalidRequestBody, "Invalid request body"))
+		return
+	}
+
+	if validationErrors := h.validator.ValidateStruct(req); validationErrors != nil {
+		sendValidationError(w, r, validationErrors)
+		return
+	}
+
+	org, err := h.service.UpdateOrganization(r.Context(), chi.URLParam(r, "id"), req)
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	sendJSON(w, http.StatusOK, org)
+}
+
+// DeleteOrganization deletes a tenant
+// @Summary Delete an organization
+// @Description Delete an organization without users. Soft deleted users count until they are purged.
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Organization ID (UUID)"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 409 {object} models.Problem "Organization still has users"
+// @Failure 429 {object} models.Problem "Rate limit exceeded"
+// @Failure 500 {object} models.Problem
+// @Router /orgs/{id} [delete]
+func (h *OrgHandler) DeleteOrganization(w http.ResponseWriter, r *http.Request) {
+	if err := h.service.DeleteOrganization(r.Context(), chi.URLParam(r, "id")); err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	sendJSON(w, http.StatusOK, models.SuccessResponse{
+		Message: "Organization deleted successfully",
+	})
+}
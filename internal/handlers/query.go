@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"user-management-api/internal/models"
+)
+
+// queryIntPtr reads an optional integer query parameter
+// A missing parameter gives nil, a malformed one is recorded in errors under its name
+func queryIntPtr(values url.Values, key string, errors map[string]string) *int {
+	raw := values.Get(key)
+	if raw == "" {
+		return nil
+	}
+
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		errors[key] = key + " must be a whole number"
+		return nil
+	}
+	return &n
+}
+
+// queryInt reads an integer query parameter, falling back to defaultValue when it is missing
+func queryInt(values url.Values, key string, defaultValue int, errors map[string]string) int {
+	if n := queryIntPtr(values, key, errors); n != nil {
+		return *n
+	}
+	return defaultValue
+}
+
+// queryBool reads a boolean query parameter ("true", "1", "false", ...), missing means false
+func queryBool(values url.Values, key string, errors map[string]string) bool {
+	raw := values.Get(key)
+	if raw == "" {
+		return false
+	}
+
+	b, err := strconv.ParseBool(raw)
+	if err != nil {
+		errors[key] = key + " must be true or false"
+		return false
+	}
+	return b
+}
+
+// queryList reads a comma separated query parameter, nil when it is missing
+func queryList(values url.Values, key string) []string {
+	var list []string
+	for _, item := range strings.Split(values.Get(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// queryTime reads an optional RFC 3339 timestamp query parameter
+func queryTime(values url.Values, key string, errors map[string]string) *time.Time {
+	raw := values.Get(key)
+	if raw == "" {
+		return nil
+	}
+
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		errors[key] = key + " must be an RFC 3339 timestamp"
+		return nil
+	}
+	return &t
+}
+
+// parseListUsersQuery reads the pagination, filter and sort parameters of GET /users
+func parseListUsersQuery(values url.Values) (models.ListUsersQuery, map[string]string) {
+	errors := make(map[string]string)
+
+	query := models.ListUsersQuery{
+		Limit:       queryInt(values, "limit", models.DefaultListLimit, errors),
+		Cursor:      values.Get("cursor"),
+		UserFilters: parseUserFilters(values, errors),
+		Sort:        values.Get("sort"),
+	}
+
+	if len(errors) > 0 {
+		return query, errors
+	}
+	return query, nil
+}
+
+// parseSearchUsersQuery reads the search and pagination parameters of GET /users/search
+func parseSearchUsersQuery(values url.Values) (models.SearchUsersQuery, map[string]string) {
+	errors := make(map[string]string)
+
+	query := models.SearchUsersQuery{
+		Q:      strings.TrimSpace(values.Get("q")),
+		Mode:   values.Get("mode"),
+		Limit:  queryInt(values, "limit", models.DefaultListLimit, errors),
+		Cursor: values.Get("cursor"),
+	}
+
+	if len(errors) > 0 {
+		return query, errors
+	}
+	return query, nil
+}
+
+// parseUserFilters reads the filter parameters shared by listing and exporting users
+func parseUserFilters(values url.Values, errors map[string]string) models.UserFilters {
+	return models.UserFilters{
+		Status:         models.UserStatus(values.Get("status")),
+		Email:          values.Get("email"),
+		Name:           values.Get("name"),
+		MinAge:         queryIntPtr(values, "min_age", errors),
+		MaxAge:         queryIntPtr(values, "max_age", errors),
+		IncludeDeleted: queryBool(values, "include_deleted", errors),
+	}
+}
+
+// parseExportUsersQuery reads the format, column, filter and sort parameters of GET /users/export
+func parseExportUsersQuery(values url.Values) (models.ExportUsersQuery, map[string]string) {
+	errors := make(map[string]string)
+
+	query := models.ExportUsersQuery{
+		Format:      values.Get("format"),
+		Columns:     queryList(values, "columns"),
+		UserFilters: parseUserFilters(values, errors),
+		Sort:        values.Get("sort"),
+	}
+	if query.Format == "" {
+		query.Format = models.ExportFormatCSV
+	}
+
+	if len(errors) > 0 {
+		return query, errors
+	}
+	return query, nil
+}
+
+// parseListAuditEventsQuery reads the pagination and filter parameters of the audit endpoints
+func parseListAuditEventsQuery(values url.Values) (models.ListAuditEventsQuery, map[string]string) {
+	errors := make(map[string]string)
+
+	query := models.ListAuditEventsQuery{
+		Limit:   queryInt(values, "limit", models.DefaultListLimit, errors),
+		Cursor:  values.Get("cursor"),
+		ActorID: values.Get("actor_id"),
+		From:    queryTime(values, "from", errors),
+		To:      queryTime(values, "to", errors),
+	}
+
+	if len(errors) > 0 {
+		return query, errors
+	}
+	return query, nil
+}
+
+// parseListWebhookDeliveriesQuery reads the pagination and filter parameters of the delivery log
+func parseListWebhookDeliveriesQuery(values url.Values) (models.ListWebhookDeliveriesQuery, map[string]string) {
+	errors := make(map[string]string)
+
+	query := models.ListWebhookDeliveriesQuery{
+		Limit:  queryInt(values, "limit", models.DefaultListLimit, errors),
+		Cursor: values.Get("cursor"),
+		Status: values.Get("status"),
+	}
+
+	if len(errors) > 0 {
+		return query, errors
+	}
+	return query, nil
+}
+
+// parseListGroupMembersQuery reads the pagination parameters of a group's member list
+func parseListGroupMembersQuery(values url.Values) (models.ListGroupMembersQuery, map[string]string) {
+	errors := make(map[string]string)
+
+	query := models.ListGroupMembersQuery{
+		Limit:  queryInt(values, "limit", models.DefaultListLimit, errors),
+		Cursor: values.Get("cursor"),
+	}
+
+	if len(errors) > 0 {
+		return query, errors
+	}
+	return query, nil
+}
+
+// parseImportUsersQuery reads the options of a user import
+func parseImportUsersQuery(values url.Values) (models.ImportUsersQuery, map[string]string) {
+	errors := make(map[string]string)
+
+	query := models.ImportUsersQuery{
+		Format:     values.Get("format"),
+		DryRun:     queryBool(values, "dry_run", errors),
+		OnConflict: values.Get("on_conflict"),
+		Mapping:    values.Get("mapping"),
+		Async:      queryBool(values, "async", errors),
+	}
+
+	if len(errors) > 0 {
+		return query, errors
+	}
+	return query, nil
+}
+
+// parseGetImportJobQuery reads the pagination parameters of an import job's row report
+func parseGetImportJobQuery(values url.Values) (models.GetImportJobQuery, map[string]string) {
+	errors := make(map[string]string)
+
+	query := models.GetImportJobQuery{
+		Limit:  queryInt(values, "limit", models.DefaultImportRowsLimit, errors),
+		Cursor: values.Get("cursor"),
+	}
+
+	if len(errors) > 0 {
+		return query, errors
+	}
+	return query, nil
+}
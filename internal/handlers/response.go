@@ -2,13 +2,20 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"sort"
+	"time"
 
+	"user-management-api/internal/logging"
 	"user-management-api/internal/models"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // sendJSON sends a JSON response (data to json)
-func (h *UserHandler) sendJSON(w http.ResponseWriter, statusCode int, data interface{}) {
+func sendJSON(w http.ResponseWriter, statusCode int, data interface{}) {
 	w.WriteHeader(statusCode)
 	// json.NewEncoder writes to w (io.Writer)
 	if err := json.NewEncoder(w).Encode(data); err != nil {
@@ -17,33 +24,109 @@ func (h *UserHandler) sendJSON(w http.ResponseWriter, statusCode int, data inter
 	}
 }
 
-// sendError sends an error response
-func (h *UserHandler) sendError(w http.ResponseWriter, appErr *models.AppError) {
-	response := models.ErrorResponse{
-		Error:   http.StatusText(appErr.StatusCode),
-		Message: appErr.Message,
+// sendError sends an error as an RFC 9457 problem
+func sendError(w http.ResponseWriter, r *http.Request, appErr *models.AppError) {
+	code := appErr.Code
+	if code == "" {
+		code = models.CodeInternal
 	}
-	h.sendJSON(w, appErr.StatusCode, response)
+	sendProblem(w, r, models.NewProblem(appErr.StatusCode, code, appErr.Message))
+}
+
+// sendValidationError sends a VALIDATION_FAILED problem listing the invalid fields
+func sendValidationError(w http.ResponseWriter, r *http.Request, errors map[string]string) {
+	problem := models.NewProblem(http.StatusBadRequest, models.CodeValidationFailed, "One or more fields failed validation")
+
+	// Sorted, so the same request always gets the same body
+	names := make([]string, 0, len(errors))
+	for name := range errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		problem.InvalidParams = append(problem.InvalidParams, models.InvalidParam{Name: name, Reason: errors[name]})
+	}
+
+	sendProblem(w, r, problem)
 }
 
-// sendValidationError sends validation error response
-func (h *UserHandler) sendValidationError(w http.ResponseWriter, errors map[string]string) {
-	response := models.ErrorResponse{
-		Error:   "Validation Failed",
-		Message: "One or more fields failed validation",
-		Details: errors,
+// sendProblem fills in the request fields of problem and sends it as application/problem+json
+func sendProblem(w http.ResponseWriter, r *http.Request, problem *models.Problem) {
+	problem.Instance = r.URL.Path
+	problem.TraceID = traceID(r)
+	problem.RequestID = chimiddleware.GetReqID(r.Context())
+
+	w.Header().Set("Content-Type", models.ProblemContentType)
+	sendJSON(w, problem.Status, problem)
+}
+
+// traceID returns the trace of the request for error responses, "" when there is none
+func traceID(r *http.Request) string {
+	if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+		return sc.TraceID().String()
 	}
-	h.sendJSON(w, http.StatusBadRequest, response)
+	return ""
 }
 
 // handleServiceError converts service errors to HTTP responses
-func (h *UserHandler) handleServiceError(w http.ResponseWriter, err error) {
-	// Type assertion to check if it's our custom error
-	if appErr, ok := err.(*models.AppError); ok {
-		h.sendError(w, appErr)
-		return
+// Server errors are logged with their cause - clients only get the generic message
+func handleServiceError(w http.ResponseWriter, r *http.Request, err error) {
+	// errors.As also finds an AppError wrapped with fmt.Errorf("...: %w", appErr)
+	var appErr *models.AppError
+	if !errors.As(err, &appErr) {
+		// Unknown error - return 500
+		appErr = models.NewInternalServerError("An unexpected error occurred", err)
+	}
+
+	if appErr.StatusCode >= http.StatusInternalServerError {
+		logging.FromContext(r.Context()).Error(appErr.Message, "error", appErr.Err)
+	}
+
+	sendError(w, r, appErr)
+}
+
+// NotFound answers requests to unknown routes with a problem instead of chi's plain text 404
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	sendError(w, r, models.NewNotFoundError(models.CodeRouteNotFound, "No such route"))
+}
+
+// MethodNotAllowed answers requests with a method the route doesn't support
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	sendError(w, r, &models.AppError{
+		StatusCode: http.StatusMethodNotAllowed,
+		Code:       models.CodeMethodNotAllowed,
+		Message:    r.Method + " is not supported on this route",
+	})
+}
+
+// streamWriteTimeout is how long each flushed part of a streamed response may take to reach the client
+// It replaces the server's write timeout, which would cut long streams short.
+const streamWriteTimeout = time.Minute
+
+// responseStream writes a streamed response body - Flush pushes the written data to the client
+type responseStream struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+func newResponseStream(w http.ResponseWriter) *responseStream {
+	s := &responseStream{w: w, rc: http.NewResponseController(w)}
+	s.extendDeadline()
+	return s
+}
+
+func (s *responseStream) Write(p []byte) (int, error) {
+	return s.w.Write(p)
+}
+
+func (s *responseStream) Flush() error {
+	if err := s.rc.Flush(); err != nil {
+		return err
 	}
+	s.extendDeadline()
+	return nil
+}
 
-	// Unknown error - return 500
-	h.sendError(w, models.NewInternalServerError("An unexpected error occurred", err))
+func (s *responseStream) extendDeadline() {
+	_ = s.rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout)) // not every connection supports deadlines
 }
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"user-management-api/internal/models"
+	"user-management-api/internal/service"
+	"user-management-api/internal/validator"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type RoleHandler struct {
+	service   *service.RoleService
+	validator *validator.Validator
+}
+
+func NewRoleHandler(service *service.RoleService, validator *validator.Validator) *RoleHandler {
+	return &RoleHandler{
+		service:   service,
+		validator: validator,
+	}
+}
+
+// ListRoles lists the available roles
+// @Summary List roles
+// @Description Get every role with the permissions it grants
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.ListRolesResponse
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 429 {object} models.Problem "Rate limit exceeded"
+// @Failure 500 {object} models.Problem
+// @Router /roles [get]
+func (h *RoleHandler) ListRoles(w http.ResponseWriter, r *http.Request) {
+	roles, err := h.service.ListRoles(r.Context())
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	sendJSON(w, http.StatusOK, roles)
+}
+
+// GetUserRoles lists the roles of a user
+// @Summary Get a user's roles
+// @Description Get the roles assigned to a user
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID (UUID)"
+// @Success 200 {object} models.UserRolesResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 429 {object} models.Problem "Rate limit exceeded"
+// @Failure 500 {object} models.Problem
+// @Router /users/{id}/roles [get]
+func (h *RoleHandler) GetUserRoles(w http.ResponseWriter, r *http.Request) {
+	userID := chi.URLParam(r, "id")
+
+	roles, err := h.service.GetUserRoles(r.Context(), userID)
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	sendJSON(w, http.StatusOK, roles)
+}
+
+// AssignRole assigns a role to a user
+// @Summary Assign a role
+// @Description Assign a role to a user
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID (UUID)"
+// @Param role body models.AssignRoleRequest true "Role to assign"
+// @Success 200 {object} models.UserRolesResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 429 {object} models.Problem "Rate limit exceeded"
+// @Failure 500 {object} models.Problem
+// @Router /users/{id}/roles [post]
+func (h *RoleHandler) AssignRole(w http.ResponseWriter, r *http.Request) {
+	userID := chi.URLParam(r, "id")
+
+	var req models.AssignRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, r, models.NewBadRequestError(models.CodeInvalidRequestBody, "Invalid request body"))
+		return
+	}
+
+	if validationErrors := h.validator.ValidateStruct(req); validationErrors != nil {
+		sendValidationError(w, r, validationErrors)
+		return
+	}
+
+	roles, err := h.service.AssignRole(r.Context(), userID, req)
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	sendJSON(w, http.StatusOK, roles)
+}
+
+// RemoveRole removes a role from a user
+// @Summary Remove a role
+// @Description Remove a role from a user. Roles granting permissions the caller lacks can't be removed, and neither can the admin role of the last admin of an organization.
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID (UUID)"
+// @Param role path string true "Role name"
+// @Success 200 {object} models.UserRolesResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 409 {object} models.Problem "Last admin of the organization"
+// @Failure 429 {object} models.Problem "Rate limit exceeded"
+// @Failure 500 {object} models.Problem
+// @Router /users/{id}/roles/{role} [delete]
+func (h *RoleHandler) RemoveRole(w http.ResponseWriter, r *http.Request) {
+	userID := chi.URLParam(r, "id")
+	role := chi.URLParam(r, "role")
+
+	roles, err := h.service.RemoveRole(r.Context(), userID, role)
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	sendJSON(w, http.StatusOK, roles)
+}
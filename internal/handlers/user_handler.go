@@ -1,9 +1,15 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"mime"
 	"net/http"
+	"time"
 
+	"user-management-api/internal/export"
+	"user-management-api/internal/logging"
 	"user-management-api/internal/models"
 	"user-management-api/internal/service"
 	"user-management-api/internal/validator"
@@ -29,36 +35,43 @@ func NewUserHandler(service *service.UserService, validator *validator.Validator
 // @Tags users
 // @Accept json
 // @Produce json
+// @Security BearerAuth
 // @Param user body models.CreateUserRequest true "User to create"
+// @Param Idempotency-Key header string false "Makes the request safe to retry - retries with the same key and body get the first response"
 // @Success 201 {object} models.UserResponse
-// @Failure 400 {object} models.ErrorResponse
-// @Failure 409 {object} models.ErrorResponse
-// @Failure 500 {object} models.ErrorResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Failure 422 {object} models.Problem "Idempotency-Key reused with a different request"
+// @Failure 429 {object} models.Problem "Rate limit exceeded"
+// @Failure 500 {object} models.Problem
 // @Router /users [post]
 func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	var req models.CreateUserRequest
 
 	// Decode JSON body into struct
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.sendError(w, models.NewBadRequestError("Invalid request body"))
+		sendError(w, r, models.NewBadRequestError(models.CodeInvalidRequestBody, "Invalid request body"))
 		return
 	}
 
 	// Validate request
 	if validationErrors := h.validator.ValidateStruct(req); validationErrors != nil {
-		h.sendValidationError(w, validationErrors)
+		sendValidationError(w, r, validationErrors)
 		return
 	}
 
 	// Call service layer
 	user, err := h.service.CreateUser(r.Context(), req)
 	if err != nil {
-		h.handleServiceError(w, err)
+		handleServiceError(w, r, err)
 		return
 	}
 
 	// Send successful response
-	h.sendJSON(w, http.StatusCreated, user)
+	w.Header().Set("ETag", userETag(user.Version))
+	sendJSON(w, http.StatusCreated, user)
 }
 
 // GetUser retrieves a user by ID
@@ -67,11 +80,18 @@ func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 // @Tags users
 // @Accept json
 // @Produce json
+// @Security BearerAuth
 // @Param id path string true "User ID (UUID)"
+// @Param If-None-Match header string false "ETag from a previous response - returns 304 if the user hasn't changed"
 // @Success 200 {object} models.UserResponse
-// @Failure 400 {object} models.ErrorResponse
-// @Failure 404 {object} models.ErrorResponse
-// @Failure 500 {object} models.ErrorResponse
+// @Header 200 {string} ETag "Current version of the user"
+// @Success 304 "User not modified"
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 429 {object} models.Problem "Rate limit exceeded"
+// @Failure 500 {object} models.Problem
 // @Router /users/{id} [get]
 func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
 
@@ -79,30 +99,171 @@ func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
 
 	user, err := h.service.GetUserByID(r.Context(), userID)
 	if err != nil {
-		h.handleServiceError(w, err)
+		handleServiceError(w, r, err)
 		return
 	}
 
-	h.sendJSON(w, http.StatusOK, user)
+	etag := userETag(user.Version)
+	w.Header().Set("ETag", etag)
+
+	// The client's copy is still current - no need to send it again
+	if ifNoneMatch(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	sendJSON(w, http.StatusOK, user)
 }
 
-// ListUsers retrieves all users
-// @Summary List all users
-// @Description Get a list of all users
+// ListUsers retrieves a page of users
+// @Summary List users
+// @Description Get a page of users with optional filters and sorting, using cursor-based pagination
 // @Tags users
 // @Accept json
 // @Produce json
+// @Security BearerAuth
+// @Param limit query int false "Page size (1-100)" default(20)
+// @Param cursor query string false "nextCursor from the previous page"
+// @Param status query string false "Filter by status" Enums(Active, Inactive)
+// @Param email query string false "Filter by email (case-insensitive, partial match)"
+// @Param name query string false "Filter by first or last name (case-insensitive, partial match)"
+// @Param min_age query int false "Minimum age"
+// @Param max_age query int false "Maximum age"
+// @Param include_deleted query bool false "Include soft deleted users (needs users:delete)"
+// @Param sort query string false "Sort field, prefix with - for descending" Enums(created_at, -created_at, updated_at, -updated_at, first_name, -first_name, last_name, -last_name, email, -email) default(-created_at)
 // @Success 200 {object} models.ListUsersResponse
-// @Failure 500 {object} models.ErrorResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 429 {object} models.Problem "Rate limit exceeded"
+// @Failure 500 {object} models.Problem
 // @Router /users [get]
 func (h *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
-	users, err := h.service.ListUsers(r.Context())
+	query, queryErrors := parseListUsersQuery(r.URL.Query())
+	if queryErrors != nil {
+		sendValidationError(w, r, queryErrors)
+		return
+	}
+
+	if validationErrors := h.validator.ValidateStruct(query); validationErrors != nil {
+		sendValidationError(w, r, validationErrors)
+		return
+	}
+
+	users, err := h.service.ListUsers(r.Context(), query)
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	sendJSON(w, http.StatusOK, users)
+}
+
+// SearchUsers finds users by name, email or phone
+// @Summary Search users
+// @Description Ranked search over first name, last name, email and phone. Users matching every word score above 1 and come first; users where a word is only similar to a field (typos such as "jon smth") follow with a score below 1.
+// @Description Highlights hold HTML escaped copies of the fields containing a search word, the word wrapped in <mark>. Use mode=prefix for autocomplete, where words match the start of words.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param q query string true "Search words" maxlength(200)
+// @Param mode query string false "full: whole words, prefix: autocomplete on word starts" Enums(full, prefix) default(full)
+// @Param limit query int false "Page size" minimum(1) maximum(100) default(20)
+// @Param cursor query string false "nextCursor from the previous page of the same search"
+// @Success 200 {object} models.SearchUsersResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 429 {object} models.Problem "Rate limit exceeded"
+// @Failure 500 {object} models.Problem
+// @Router /users/search [get]
+func (h *UserHandler) SearchUsers(w http.ResponseWriter, r *http.Request) {
+	query, queryErrors := parseSearchUsersQuery(r.URL.Query())
+	if queryErrors != nil {
+		sendValidationError(w, r, queryErrors)
+		return
+	}
+
+	if validationErrors := h.validator.ValidateStruct(query); validationErrors != nil {
+		sendValidationError(w, r, validationErrors)
+		return
+	}
+
+	results, err := h.service.SearchUsers(r.Context(), query)
 	if err != nil {
-		h.handleServiceError(w, err)
+		handleServiceError(w, r, err)
 		return
 	}
 
-	h.sendJSON(w, http.StatusOK, users)
+	sendJSON(w, http.StatusOK, results)
+}
+
+// ExportUsers streams every user matching the filters as a file
+// @Summary Export users
+// @Description Download the users matching the filters as CSV, NDJSON or XLSX. Rows are streamed from a consistent snapshot of the table, so the export is complete and consistent however large it is and however long it takes. A failure after the download started aborts the transfer instead of ending the file early.
+// @Tags users
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Security BearerAuth
+// @Param format query string false "File format" Enums(csv, ndjson, xlsx) default(csv)
+// @Param columns query string false "Comma separated columns in the order wanted, all when missing (userId, firstName, lastName, email, phone, age, status, createdAt, updatedAt, deletedAt, version, emailVerifiedAt)"
+// @Param status query string false "Filter by status" Enums(Active, Inactive)
+// @Param email query string false "Filter by email (case-insensitive, partial match)"
+// @Param name query string false "Filter by first or last name (case-insensitive, partial match)"
+// @Param min_age query int false "Minimum age"
+// @Param max_age query int false "Maximum age"
+// @Param include_deleted query bool false "Include soft deleted users (needs users:delete)"
+// @Param sort query string false "Sort field, prefix with - for descending" Enums(created_at, -created_at, updated_at, -updated_at, first_name, -first_name, last_name, -last_name, email, -email) default(-created_at)
+// @Success 200 {file} file
+// @Header 200 {string} Content-Disposition "attachment; filename=users-20060102-150405.csv"
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 429 {object} models.Problem "Rate limit exceeded"
+// @Failure 500 {object} models.Problem
+// @Router /users/export [get]
+func (h *UserHandler) ExportUsers(w http.ResponseWriter, r *http.Request) {
+	query, queryErrors := parseExportUsersQuery(r.URL.Query())
+	if queryErrors != nil {
+		sendValidationError(w, r, queryErrors)
+		return
+	}
+
+	if validationErrors := h.validator.ValidateStruct(query); validationErrors != nil {
+		sendValidationError(w, r, validationErrors)
+		return
+	}
+
+	writer, err := export.NewWriter(query.Format, newResponseStream(w))
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	userExport, err := h.service.ExportUsers(r.Context(), query)
+	if err != nil {
+		writer.Abort()
+		handleServiceError(w, r, err)
+		return
+	}
+	// Also ends the transaction when the client went away and the request context is cancelled
+	defer userExport.Close(context.WithoutCancel(r.Context()))
+
+	filename := fmt.Sprintf("users-%s.%s", time.Now().UTC().Format("20060102-150405"), query.Format)
+	w.Header().Set("Content-Type", export.ContentType(query.Format))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := userExport.WriteTo(r.Context(), writer); err != nil {
+		if r.Context().Err() == nil {
+			logging.FromContext(r.Context()).Error("user export failed", "error", err)
+		}
+		// The 200 is sent already - abort the response, so the client sees a broken download instead of a short file
+		panic(http.ErrAbortHandler)
+	}
 }
 
 // UpdateUser updates an existing user
@@ -111,60 +272,116 @@ func (h *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
 // @Tags users
 // @Accept json
 // @Produce json
+// @Security BearerAuth
 // @Param id path string true "User ID (UUID)"
 // @Param user body models.UpdateUserRequest true "User fields to update"
+// @Param If-Match header string false "ETag from a previous response - the update fails with 412 if the user changed since"
 // @Success 200 {object} models.UserResponse
-// @Failure 400 {object} models.ErrorResponse
-// @Failure 404 {object} models.ErrorResponse
-// @Failure 409 {object} models.ErrorResponse
-// @Failure 500 {object} models.ErrorResponse
+// @Header 200 {string} ETag "New version of the user"
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Failure 412 {object} models.Problem
+// @Failure 429 {object} models.Problem "Rate limit exceeded"
+// @Failure 500 {object} models.Problem
 // @Router /users/{id} [patch]
 func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	userID := chi.URLParam(r, "id")
 
 	var req models.UpdateUserRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.sendError(w, models.NewBadRequestError("Invalid request body"))
+		sendError(w, r, models.NewBadRequestError(models.CodeInvalidRequestBody, "Invalid request body"))
 		return
 	}
 
 	// Validate request
 	if validationErrors := h.validator.ValidateStruct(req); validationErrors != nil {
-		h.sendValidationError(w, validationErrors)
+		sendValidationError(w, r, validationErrors)
 		return
 	}
 
-	user, err := h.service.UpdateUser(r.Context(), userID, req)
+	expectedVersion, ok := parseIfMatch(r)
+	if !ok {
+		sendError(w, r, models.NewPreconditionFailedError(models.CodeVersionMismatch, "If-Match does not match the current version"))
+		return
+	}
+
+	user, err := h.service.UpdateUser(r.Context(), userID, req, expectedVersion)
 	if err != nil {
-		h.handleServiceError(w, err)
+		handleServiceError(w, r, err)
 		return
 	}
 
-	h.sendJSON(w, http.StatusOK, user)
+	w.Header().Set("ETag", userETag(user.Version))
+	sendJSON(w, http.StatusOK, user)
 }
 
 // DeleteUser deletes a user
 // @Summary Delete a user
-// @Description Delete a user by their ID
+// @Description Soft delete a user by their ID. The user can be restored until the retention period passes.
 // @Tags users
 // @Accept json
 // @Produce json
+// @Security BearerAuth
 // @Param id path string true "User ID (UUID)"
+// @Param If-Match header string false "ETag from a previous response - the delete fails with 412 if the user changed since"
 // @Success 200 {object} models.SuccessResponse
-// @Failure 400 {object} models.ErrorResponse
-// @Failure 404 {object} models.ErrorResponse
-// @Failure 500 {object} models.ErrorResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 412 {object} models.Problem
+// @Failure 429 {object} models.Problem "Rate limit exceeded"
+// @Failure 500 {object} models.Problem
 // @Router /users/{id} [delete]
 func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	userID := chi.URLParam(r, "id")
 
-	err := h.service.DeleteUser(r.Context(), userID)
+	expectedVersion, ok := parseIfMatch(r)
+	if !ok {
+		sendError(w, r, models.NewPreconditionFailedError(models.CodeVersionMismatch, "If-Match does not match the current version"))
+		return
+	}
+
+	err := h.service.DeleteUser(r.Context(), userID, expectedVersion)
 	if err != nil {
-		h.handleServiceError(w, err)
+		handleServiceError(w, r, err)
 		return
 	}
 
-	h.sendJSON(w, http.StatusOK, models.SuccessResponse{
+	sendJSON(w, http.StatusOK, models.SuccessResponse{
 		Message: "User deleted successfully",
 	})
 }
+
+// RestoreUser restores a deleted user
+// @Summary Restore a deleted user
+// @Description Undo the soft delete of a user, as long as the retention period hasn't passed
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID (UUID)"
+// @Success 200 {object} models.UserResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Failure 429 {object} models.Problem "Rate limit exceeded"
+// @Failure 500 {object} models.Problem
+// @Router /users/{id}/restore [post]
+func (h *UserHandler) RestoreUser(w http.ResponseWriter, r *http.Request) {
+	userID := chi.URLParam(r, "id")
+
+	user, err := h.service.RestoreUser(r.Context(), userID)
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", userETag(user.Version))
+	sendJSON(w, http.StatusOK, user)
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"user-management-api/internal/models"
+	"user-management-api/internal/service"
+	"user-management-api/internal/validator"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type VerificationHandler struct {
+	service   *service.VerificationService
+	validator *validator.Validator
+}
+
+func NewVerificationHandler(service *service.VerificationService, validator *validator.Validator) *VerificationHandler {
+	return &VerificationHandler{
+		service:   service,
+		validator: validator,
+	}
+}
+
+// ResendVerification mails a new verification link
+// @Summary Resend the verification email
+// @Description Mail a new link to confirm the user's email address. Links sent earlier stop working.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID (UUID)"
+// @Success 202 {object} models.SuccessResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 409 {object} models.Problem "Email address is already verified"
+// @Failure 429 {object} models.Problem "Rate limit exceeded"
+// @Failure 500 {object} models.Problem
+// @Router /users/{id}/verification [post]
+func (h *VerificationHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	if err := h.service.ResendVerification(r.Context(), chi.URLParam(r, "id")); err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	sendJSON(w, http.StatusAccepted, models.SuccessResponse{
+		Message: "Verification email sent",
+	})
+}
+
+// VerifyEmail confirms an email address
+// @Summary Verify an email address
+// @Description Confirm the address a verification link was mailed to. Each link works once, until it expires or a newer one is sent.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param token body models.VerifyEmailRequest true "Token from the verification link"
+// @Success 200 {object} models.VerifyEmailResponse
+// @Failure 400 {object} models.Problem "Invalid or expired token"
+// @Failure 429 {object} models.Problem "Rate limit exceeded"
+// @Failure 500 {object} models.Problem
+// @Router /auth/verify-email [post]
+func (h *VerificationHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req models.VerifyEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, r, models.NewBadRequestError(models.CodeInvalidRequestBody, "Invalid request body"))
+		return
+	}
+
+	if validationErrors := h.validator.ValidateStruct(req); validationErrors != nil {
+		sendValidationError(w, r, validationErrors)
+		return
+	}
+
+	result, err := h.service.VerifyEmail(r.Context(), req)
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	sendJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"user-management-api/internal/models"
+	"user-management-api/internal/service"
+	"user-management-api/internal/validator"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type WebhookHandler struct {
+	service   *service.WebhookService
+	validator *validator.Validator
+}
+
+func NewWebhookHandler(service *service.WebhookService, validator *validator.Validator) *WebhookHandler {
+	return &WebhookHandler{
+		service:   service,
+		validator: validator,
+	}
+}
+
+// CreateWebhook subscribes a URL to user events
+// @Summary Create a webhook
+// @Description Subscribe a URL to user events. Deliveries are signed with HMAC-SHA256 of "<X-Webhook-Timestamp>.<body>" in X-Webhook-Signature. The secret is only returned here.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param webhook body models.CreateWebhookRequest true "Webhook to create"
+// @Success 201 {object} models.CreateWebhookResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 429 {object} models.Problem "Rate limit exceeded"
+// @Failure 500 {object} models.Problem
+// @Router /webhooks [post]
+func (h *WebhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, r, models.NewBadRequestError(models.CodeInvalidRequestBody, "Invalid request body"))
+		return
+	}
+
+	if validationErrors := h.validator.ValidateStruct(req); validationErrors != nil {
+		sendValidationError(w, r, validationErrors)
+		return
+	}
+
+	webhook, err := h.service.CreateWebhook(r.Context(), req)
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	sendJSON(w, http.StatusCreated, webhook)
+}
+
+// ListWebhooks lists the webhook subscriptions
+// @Summary List webhooks
+// @Description Get every webhook subscription
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.ListWebhooksResponse
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 429 {object} models.Problem "Rate limit exceeded"
+// @Failure 500 {object} models.Problem
+// @Router /webhooks [get]
+func (h *WebhookHandler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := h.service.ListWebhooks(r.Context())
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	sendJSON(w, http.StatusOK, webhooks)
+}
+
+// GetWebhook retrieves a webhook subscription
+// @Summary Get a webhook
+// @Description Get a webhook subscription by ID
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Webhook ID (UUID)"
+// @Success 200 {object} models.WebhookResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 429 {object} models.Problem "Rate limit exceeded"
+// @Failure 500 {object} models.Problem
+// @Router /webhooks/{id} [get]
+func (h *WebhookHandler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook, err := h.service.GetWebhook(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	sendJSON(w, http.StatusOK, webhook)
+}
+
+// UpdateWebhook updates a webhook subscription
+// @Summary Update a webhook
+// @Description Change the URL, secret, event types, description or active flag of a webhook. Pending retries use the new values.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Webhook ID (UUID)"
+// @Param webhook body models.UpdateWebhookRequest true "Fields to update"
+// @Success 200 {object} models.WebhookResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 429 {object} models.Problem "Rate limit exceeded"
+// @Failure 500 {object} models.Problem
+// @Router /webhooks/{id} [patch]
+func (h *WebhookHandler) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req models.UpdateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, r, models.NewBadRequestError(models.CodeInvalidRequestBody, "Invalid request body"))
+		return
+	}
+
+	if validationErrors := h.validator.ValidateStruct(req); validationErrors != nil {
+		sendValidationError(w, r, validationErrors)
+		return
+	}
+
+	webhook, err := h.service.UpdateWebhook(r.Context(), chi.URLParam(r, "id"), req)
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	sendJSON(w, http.StatusOK, webhook)
+}
+
+// DeleteWebhook deletes a webhook subscription
+// @Summary Delete a webhook
+// @Description Delete a webhook subscription together with its delivery log
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Webhook ID (UUID)"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 429 {object} models.Problem "Rate limit exceeded"
+// @Failure 500 {object} models.Problem
+// @Router /webhooks/{id} [delete]
+func (h *WebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	if err := h.service.DeleteWebhook(r.Context(), chi.URLParam(r, "id")); err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	sendJSON(w, http.StatusOK, models.SuccessResponse{
+		Message: "Webhook deleted successfully",
+	})
+}
+
+// ListDeliveries lists the delivery log of a webhook
+// @Summary List webhook deliveries
+// @Description Get a page of the deliveries of a webhook, newest first, using cursor-based pagination
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Webhook ID (UUID)"
+// @Param limit query int false "Page size (1-100)" default(20)
+// @Param cursor query string false "nextCursor from the previous page"
+// @Param status query string false "Filter by status" Enums(pending, succeeded, dead)
+// @Success 200 {object} models.ListWebhookDeliveriesResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 429 {object} models.Problem "Rate limit exceeded"
+// @Failure 500 {object} models.Problem
+// @Router /webhooks/{id}/deliveries [get]
+func (h *WebhookHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	query, queryErrors := parseListWebhookDeliveriesQuery(r.URL.Query())
+	if queryErrors != nil {
+		sendValidationError(w, r, queryErrors)
+		return
+	}
+
+	if validationErrors := h.validator.ValidateStruct(query); validationErrors != nil {
+		sendValidationError(w, r, validationErrors)
+		return
+	}
+
+	deliveries, err := h.service.ListDeliveries(r.Context(), chi.URLParam(r, "id"), query)
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	sendJSON(w, http.StatusOK, deliveries)
+}
+
+// ReplayDelivery sends a delivery again
+// @Summary Replay a webhook delivery
+// @Description Queue a new delivery of the same event, whatever the status of the original. The original stays in the log unchanged.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Webhook ID (UUID)"
+// @Param deliveryId path string true "Delivery ID (UUID)"
+// @Success 202 {object} models.WebhookDeliveryResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 429 {object} models.Problem "Rate limit exceeded"
+// @Failure 500 {object} models.Problem
+// @Router /webhooks/{id}/deliveries/{deliveryId}/replay [post]
+func (h *WebhookHandler) ReplayDelivery(w http.ResponseWriter, r *http.Request) {
+	delivery, err := h.service.ReplayDelivery(r.Context(), chi.URLParam(r, "id"), chi.URLParam(r, "deliveryId"))
+	if err != nil {
+		handleServiceError(w, r, err)
+		return
+	}
+
+	sendJSON(w, http.StatusAccepted, delivery)
+}
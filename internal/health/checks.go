@@ -0,0 +1,42 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PingCheck checks that a database connection can be acquired and answers
+func PingCheck(pool *pgxpool.Pool) Check {
+	return func(ctx context.Context) error {
+		return pool.Ping(ctx)
+	}
+}
+
+// MigrationCheck checks that the database is migrated to at least the expected version and the last migration finished
+// A newer schema passes, so instances of the previous release keep serving while a deployment migrates ahead of them.
+// It reads the schema_migrations table of golang-migrate (see make migrateup).
+func MigrationCheck(pool *pgxpool.Pool, expected int64) Check {
+	return func(ctx context.Context) error {
+		var version int64
+		var dirty bool
+		err := pool.QueryRow(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return fmt.Errorf("no migrations applied, expected version %d", expected)
+			}
+			return err
+		}
+
+		if dirty {
+			return fmt.Errorf("migration %d failed halfway (dirty), fix it and force the version", version)
+		}
+		if version < expected {
+			return fmt.Errorf("database is at version %d, expected at least %d", version, expected)
+		}
+		return nil
+	}
+}
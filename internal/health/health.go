@@ -0,0 +1,119 @@
+package health
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Status values of checks and of the whole report
+const (
+	StatusOK       = "ok"
+	StatusFailing  = "failing"
+	StatusDraining = "draining" // shutting down, no new traffic please
+)
+
+// Check reports whether one dependency is usable - it must return once ctx is done
+type Check func(ctx context.Context) error
+
+// CheckResult is the outcome of one check
+type CheckResult struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latencyMs"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report is the outcome of all readiness checks
+type Report struct {
+	Status string        `json:"status"`
+	Checks []CheckResult `json:"checks"`
+}
+
+// Registry holds the readiness checks
+// Register checks at startup, Run them on every readiness probe.
+type Registry struct {
+	timeout time.Duration
+
+	mu     sync.RWMutex
+	checks map[string]Check
+
+	draining atomic.Bool
+}
+
+// NewRegistry creates a registry - every check gets timeout to finish
+func NewRegistry(timeout time.Duration) *Registry {
+	return &Registry{
+		timeout: timeout,
+		checks:  make(map[string]Check),
+	}
+}
+
+// Register adds a check, replacing an existing one with the same name
+func (r *Registry) Register(name string, check Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks[name] = check
+}
+
+// StartDraining makes every following Run report StatusDraining - called when shutdown begins
+func (r *Registry) StartDraining() {
+	r.draining.Store(true)
+}
+
+// Run runs all checks concurrently and reports StatusOK only if all of them passed
+// While draining, the checks are skipped - the instance is going away whatever they say.
+func (r *Registry) Run(ctx context.Context) Report {
+	if r.draining.Load() {
+		return Report{Status: StatusDraining, Checks: []CheckResult{}}
+	}
+
+	r.mu.RLock()
+	names := make([]string, 0, len(r.checks))
+	checks := make(map[string]Check, len(r.checks))
+	for name, check := range r.checks {
+		names = append(names, name)
+		checks[name] = check
+	}
+	r.mu.RUnlock()
+	sort.Strings(names)
+
+	results := make([]CheckResult, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			results[i] = r.run(ctx, name, checks[name])
+		}(i, name)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: results}
+	for _, result := range results {
+		if result.Status != StatusOK {
+			report.Status = StatusFailing
+		}
+	}
+	return report
+}
+
+func (r *Registry) run(ctx context.Context, name string, check Check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	result := CheckResult{
+		Name:      name,
+		Status:    StatusOK,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusFailing
+		result.Error = err.Error()
+	}
+	return result
+}
@@ -0,0 +1,76 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// New creates a logger writing to w - format is "json" or "text", level is "debug", "info", "warn" or "error"
+func New(w io.Writer, format, level string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q: use debug, info, warn or error", level)
+	}
+	options := &slog.HandlerOptions{Level: lvl}
+
+	switch strings.ToLower(format) {
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, options)), nil
+	case "text":
+		return slog.New(slog.NewTextHandler(w, options)), nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q: use json or text", format)
+	}
+}
+
+type loggerKey struct{}
+
+// WithLogger returns a copy of ctx carrying the logger
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the request logger (with request ID, user ID, ...), or slog.Default() outside of a request
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// With adds attributes to the logger in ctx, for everything logged further down the request
+func With(ctx context.Context, args ...interface{}) context.Context {
+	return WithLogger(ctx, FromContext(ctx).With(args...))
+}
+
+// requestFields are filled in by inner middleware and read by the request logger once the request is done
+// Inner middleware can't change the outer request's context, so the logger shares this pointer with them.
+type requestFields struct {
+	userID string
+}
+
+type requestFieldsKey struct{}
+
+// WithRequestFields prepares ctx for SetUserID - called by the request logger
+func WithRequestFields(ctx context.Context) context.Context {
+	return context.WithValue(ctx, requestFieldsKey{}, &requestFields{})
+}
+
+// SetUserID records the authenticated user for the request log line, and adds it to the logger in ctx
+func SetUserID(ctx context.Context, userID string) context.Context {
+	if fields, ok := ctx.Value(requestFieldsKey{}).(*requestFields); ok {
+		fields.userID = userID
+	}
+	return With(ctx, "user_id", userID)
+}
+
+// UserID returns the user recorded with SetUserID, "" for anonymous requests
+func UserID(ctx context.Context) string {
+	if fields, ok := ctx.Value(requestFieldsKey{}).(*requestFields); ok {
+		return fields.userID
+	}
+	return ""
+}
@@ -0,0 +1,81 @@
+package mail
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// Message is one email with a plain text and an HTML version of the same content
+type Message struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Text    string `json:"text"`
+	HTML    string `json:"html"`
+}
+
+// Mailer sends emails
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// LogMailer writes every message to the default slog logger instead of sending it - useful in development
+// The text body is logged too, it holds the links a developer needs to click.
+type LogMailer struct{}
+
+func NewLogMailer() *LogMailer {
+	return &LogMailer{}
+}
+
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	slog.InfoContext(ctx, "mail sent",
+		"to", msg.To,
+		"subject", msg.Subject,
+		"text", msg.Text,
+	)
+	return nil
+}
+
+// FileMailer appends messages to a file as newline delimited JSON, e.g. for end-to-end tests to read
+type FileMailer struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+// NewFileMailer opens (or creates) the file at path for appending
+func NewFileMailer(path string) (*FileMailer, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileMailer{
+		file: file,
+		enc:  json.NewEncoder(file),
+	}, nil
+}
+
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.enc.Encode(struct {
+		Message
+		SentAt time.Time `json:"sentAt"`
+	}{msg, time.Now().UTC()})
+}
+
+func (m *FileMailer) Close() error {
+	return m.file.Close()
+}
+
+// compile time checks that the mailers implement Mailer
+var (
+	_ Mailer = (*LogMailer)(nil)
+	_ Mailer = (*FileMailer)(nil)
+	_ Mailer = (*SMTPMailer)(nil)
+)
@@ -0,0 +1,100 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	texttemplate "text/template"
+	"time"
+)
+
+// Template names - every template is a pair of files, <name>.txt and <name>.html
+// The text template also defines the subject in a "subject" block.
+const (
+	TemplateVerifyEmail = "verify_email"
+)
+
+var templateNames = []string{TemplateVerifyEmail}
+
+// VerifyEmailData is what the verify_email templates can use
+type VerifyEmailData struct {
+	FirstName string
+	Email     string // the address to confirm
+	Link      string // opens the confirmation page with the token
+	Token     string
+	ExpiresAt time.Time
+}
+
+//go:embed templates
+var builtinTemplates embed.FS
+
+// Templates renders the messages the API sends
+type Templates struct {
+	text map[string]*texttemplate.Template
+	html map[string]*htmltemplate.Template
+}
+
+// LoadTemplates parses the templates in dir, "" uses the built-in ones
+// A directory has to hold every template, so a missing one is noticed at startup rather than at the first mail.
+func LoadTemplates(dir string) (*Templates, error) {
+	var files fs.FS
+	if dir == "" {
+		sub, err := fs.Sub(builtinTemplates, "templates")
+		if err != nil {
+			return nil, err
+		}
+		files = sub
+	} else {
+		files = os.DirFS(dir)
+	}
+
+	t := &Templates{
+		text: make(map[string]*texttemplate.Template),
+		html: make(map[string]*htmltemplate.Template),
+	}
+	for _, name := range templateNames {
+		text, err := texttemplate.ParseFS(files, name+".txt")
+		if err != nil {
+			return nil, err
+		}
+		if text.Lookup("subject") == nil {
+			return nil, fmt.Errorf("%s.txt does not define a subject", name)
+		}
+		html, err := htmltemplate.ParseFS(files, name+".html")
+		if err != nil {
+			return nil, err
+		}
+		t.text[name] = text
+		t.html[name] = html
+	}
+	return t, nil
+}
+
+// Render renders the named template into a message to one recipient
+func (t *Templates) Render(name, to string, data interface{}) (Message, error) {
+	text, ok := t.text[name]
+	if !ok {
+		return Message{}, fmt.Errorf("unknown mail template %q", name)
+	}
+
+	var subject, textBody, htmlBody bytes.Buffer
+	if err := text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, err
+	}
+	if err := text.Execute(&textBody, data); err != nil {
+		return Message{}, err
+	}
+	if err := t.html[name].Execute(&htmlBody, data); err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		To:      to,
+		Subject: subject.String(),
+		Text:    textBody.String(),
+		HTML:    htmlBody.String(),
+	}, nil
+}
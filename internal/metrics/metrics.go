@@ -0,0 +1,127 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// DefaultBuckets are latency buckets in seconds, from 5ms to 10s
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// NewRegistry creates a registry with the Go runtime and process metrics
+// A registry of its own instead of prometheus.DefaultRegisterer keeps metrics of libraries out unless added here.
+func NewRegistry() *prometheus.Registry {
+	r := prometheus.NewRegistry()
+	r.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return r
+}
+
+// Handler serves every metric of r - mount it on GET /metrics of the admin listener
+func Handler(r *prometheus.Registry) http.Handler {
+	return promhttp.HandlerFor(r, promhttp.HandlerOpts{Registry: r})
+}
+
+// HTTPMetrics counts requests and their latency by chi route pattern, method and status
+// The route pattern (/api/v1/users/{id}) is used instead of the path so IDs don't create new series.
+type HTTPMetrics struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+func NewHTTPMetrics(r prometheus.Registerer) *HTTPMetrics {
+	factory := promauto.With(r)
+	return &HTTPMetrics{
+		requests: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "HTTP requests by route pattern, method and status code.",
+		}, []string{"route", "method", "status"}),
+		duration: factory.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "HTTP request latency by route pattern, method and status code.",
+			Buckets: DefaultBuckets,
+		}, []string{"route", "method", "status"}),
+	}
+}
+
+// Observe records one finished request
+func (m *HTTPMetrics) Observe(route, method, status string, duration time.Duration) {
+	labels := prometheus.Labels{"route": route, "method": method, "status": status}
+	m.requests.With(labels).Inc()
+	m.duration.With(labels).Observe(duration.Seconds())
+}
+
+// ServiceMetrics counts service operations and their latency
+// statusClass is "ok" for successful calls, otherwise the class of the AppError status ("4xx", "5xx").
+type ServiceMetrics struct {
+	operations *prometheus.CounterVec
+	duration   *prometheus.HistogramVec
+}
+
+// NewServiceMetrics creates the metrics of one service - name prefixes the metric names ("user_service")
+func NewServiceMetrics(r prometheus.Registerer, name string) *ServiceMetrics {
+	factory := promauto.With(r)
+	return &ServiceMetrics{
+		operations: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: name + "_operations_total",
+			Help: "Service operations by operation and result status class.",
+		}, []string{"operation", "status_class"}),
+		duration: factory.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    name + "_operation_duration_seconds",
+			Help:    "Service operation latency by operation and result status class.",
+			Buckets: DefaultBuckets,
+		}, []string{"operation", "status_class"}),
+	}
+}
+
+// Observe records one finished operation - a nil ServiceMetrics records nothing
+func (m *ServiceMetrics) Observe(operation, statusClass string, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	labels := prometheus.Labels{"operation": operation, "status_class": statusClass}
+	m.operations.With(labels).Inc()
+	m.duration.With(labels).Observe(duration.Seconds())
+}
+
+// RegisterPoolStats exposes the connection pool statistics, read from pool.Stat() at scrape time
+func RegisterPoolStats(r prometheus.Registerer, pool *pgxpool.Pool) {
+	gauge := func(name, help string, fn func() float64) prometheus.Collector {
+		return prometheus.NewGaugeFunc(prometheus.GaugeOpts{Name: name, Help: help}, fn)
+	}
+	counter := func(name, help string, fn func() float64) prometheus.Collector {
+		return prometheus.NewCounterFunc(prometheus.CounterOpts{Name: name, Help: help}, fn)
+	}
+
+	r.MustRegister(
+		gauge("db_pool_acquired_conns", "Connections currently in use.", func() float64 {
+			return float64(pool.Stat().AcquiredConns())
+		}),
+		gauge("db_pool_idle_conns", "Connections currently idle in the pool.", func() float64 {
+			return float64(pool.Stat().IdleConns())
+		}),
+		gauge("db_pool_total_conns", "Connections currently open (acquired, idle and being created).", func() float64 {
+			return float64(pool.Stat().TotalConns())
+		}),
+		gauge("db_pool_max_conns", "Maximum size of the pool.", func() float64 {
+			return float64(pool.Stat().MaxConns())
+		}),
+		counter("db_pool_acquire_wait_seconds_total", "Total time spent waiting for a connection.", func() float64 {
+			return pool.Stat().AcquireDuration().Seconds()
+		}),
+		counter("db_pool_acquires_total", "Connections acquired from the pool.", func() float64 {
+			return float64(pool.Stat().AcquireCount())
+		}),
+		counter("db_pool_canceled_acquires_total", "Acquires cancelled by their context before a connection was available.", func() float64 {
+			return float64(pool.Stat().CanceledAcquireCount())
+		}),
+	)
+}
@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestHTTPMetrics_Observe(t *testing.T) {
+	registry := NewRegistry()
+	m := NewHTTPMetrics(registry)
+
+	m.Observe("/api/v1/users/{id}", "GET", "200", 20*time.Millisecond)
+	m.Observe("/api/v1/users/{id}", "GET", "200", 2*time.Second)
+	m.Observe("/api/v1/users/{id}", "GET", "404", time.Millisecond)
+
+	if got := testutil.ToFloat64(m.requests.WithLabelValues("/api/v1/users/{id}", "GET", "200")); got != 2 {
+		t.Errorf("requests with status 200 = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(m.requests.WithLabelValues("/api/v1/users/{id}", "GET", "404")); got != 1 {
+		t.Errorf("requests with status 404 = %v, want 1", got)
+	}
+	if got := testutil.CollectAndCount(m.duration); got != 2 {
+		t.Errorf("duration series = %d, want 2", got)
+	}
+}
+
+func TestServiceMetrics_Observe(t *testing.T) {
+	registry := NewRegistry()
+	m := NewServiceMetrics(registry, "user_service")
+
+	m.Observe("create_user", "ok", time.Millisecond)
+	m.Observe("create_user", "4xx", time.Millisecond)
+
+	if got := testutil.ToFloat64(m.operations.WithLabelValues("create_user", "ok")); got != 1 {
+		t.Errorf("ok operations = %v, want 1", got)
+	}
+
+	// A nil ServiceMetrics is allowed and records nothing
+	var disabled *ServiceMetrics
+	disabled.Observe("create_user", "ok", time.Millisecond)
+}
+
+func TestHandler(t *testing.T) {
+	registry := NewRegistry()
+	NewHTTPMetrics(registry).Observe("/livez", "GET", "200", time.Millisecond)
+
+	rec := httptest.NewRecorder()
+	Handler(registry).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		`http_requests_total{method="GET",route="/livez",status="200"} 1`,
+		`http_request_duration_seconds_bucket{method="GET",route="/livez",status="200",le="0.005"} 1`,
+		"go_goroutines",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body doesn't contain %q", want)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"user-management-api/internal/auth"
+	"user-management-api/internal/logging"
+	"user-management-api/internal/models"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Authenticate requires a valid "Authorization: Bearer <jwt>" header
+// The authenticated caller is put on the request context - read it with auth.PrincipalFromContext
+func Authenticate(verifier *auth.Verifier) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := r.Header.Get("Authorization")
+			scheme, token, found := strings.Cut(header, " ")
+			if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+				unauthorized(w, r, models.CodeAuthenticationRequired, "Missing or malformed bearer token")
+				return
+			}
+
+			principal, err := verifier.Verify(token)
+			if err != nil {
+				unauthorized(w, r, models.CodeInvalidToken, "Invalid or expired token")
+				return
+			}
+
+			ctx := auth.WithPrincipal(r.Context(), principal)
+			ctx = logging.SetUserID(ctx, principal.Subject)
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// unauthorized sends a 401 in the same shape as handler errors
+func unauthorized(w http.ResponseWriter, r *http.Request, code, message string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+	writeError(w, r, http.StatusUnauthorized, code, message)
+}
+
+// writeError writes a models.Problem from middleware (handlers use sendError)
+func writeError(w http.ResponseWriter, r *http.Request, statusCode int, code, message string) {
+	problem := models.NewProblem(statusCode, code, message)
+	problem.Instance = r.URL.Path
+	problem.TraceID = traceID(r)
+	problem.RequestID = chimiddleware.GetReqID(r.Context())
+
+	w.Header().Set("Content-Type", models.ProblemContentType)
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(problem)
+}
+
+// traceID returns the trace of the request for error responses, "" when there is none
+func traceID(r *http.Request) string {
+	if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+		return sc.TraceID().String()
+	}
+	return ""
+}
@@ -0,0 +1,221 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"user-management-api/internal/config"
+	"user-management-api/internal/models"
+)
+
+// corsPolicy is a config.CORSPolicy ready to match origins
+type corsPolicy struct {
+	allowAll         bool
+	origins          map[string]bool
+	wildcards        []wildcardOrigin
+	patterns         []*regexp.Regexp
+	methods          map[string]bool
+	headers          map[string]bool // lower case
+	allowMethods     string
+	allowHeaders     string
+	exposeHeaders    string
+	allowCredentials bool
+	maxAge           string // "" when not sent
+}
+
+// wildcardOrigin is https://*.example.com split around the "*"
+type wildcardOrigin struct {
+	prefix string // https://
+	suffix string // .example.com
+}
+
+func (w wildcardOrigin) matches(origin string) bool {
+	if len(origin) <= len(w.prefix)+len(w.suffix) || !strings.HasPrefix(origin, w.prefix) || !strings.HasSuffix(origin, w.suffix) {
+		return false
+	}
+	// The subdomain must not smuggle in a path, port or credentials
+	sub := origin[len(w.prefix) : len(origin)-len(w.suffix)]
+	return !strings.ContainsAny(sub, "/:@")
+}
+
+func newCORSPolicy(cfg config.CORSPolicy) (*corsPolicy, error) {
+	p := &corsPolicy{
+		origins:          make(map[string]bool),
+		methods:          make(map[string]bool),
+		headers:          make(map[string]bool),
+		allowMethods:     strings.Join(cfg.AllowedMethods, ", "),
+		allowHeaders:     strings.Join(cfg.AllowedHeaders, ", "),
+		exposeHeaders:    strings.Join(cfg.ExposedHeaders, ", "),
+		allowCredentials: cfg.AllowCredentials,
+	}
+	if cfg.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(int(cfg.MaxAge.Seconds()))
+	}
+
+	for _, origin := range cfg.AllowedOrigins {
+		origin = strings.ToLower(strings.TrimSuffix(origin, "/"))
+		switch {
+		case origin == "*":
+			p.allowAll = true
+		case strings.Contains(origin, "*"):
+			prefix, suffix, _ := strings.Cut(origin, "*")
+			p.wildcards = append(p.wildcards, wildcardOrigin{prefix: prefix, suffix: suffix})
+		default:
+			p.origins[origin] = true
+		}
+	}
+	for _, pattern := range cfg.AllowedOriginPatterns {
+		// Anchored, so "https://app\.example\.com" doesn't also allow https://app.example.com.evil.net
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid CORS origin pattern %q: %w", pattern, err)
+		}
+		p.patterns = append(p.patterns, re)
+	}
+	for _, method := range cfg.AllowedMethods {
+		p.methods[strings.ToUpper(method)] = true
+	}
+	for _, header := range cfg.AllowedHeaders {
+		p.headers[strings.ToLower(header)] = true
+	}
+
+	return p, nil
+}
+
+func (p *corsPolicy) allowsOrigin(origin string) bool {
+	if p.allowAll {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	if p.origins[origin] {
+		return true
+	}
+	for _, w := range p.wildcards {
+		if w.matches(origin) {
+			return true
+		}
+	}
+	for _, re := range p.patterns {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsRequestHeaders checks the comma separated Access-Control-Request-Headers list
+func (p *corsPolicy) allowsRequestHeaders(requested string) bool {
+	for _, header := range strings.Split(requested, ",") {
+		header = strings.ToLower(strings.TrimSpace(header))
+		if header != "" && !p.headers[header] {
+			return false
+		}
+	}
+	return true
+}
+
+// setOrigin sets the headers shared by preflight and actual responses
+func (p *corsPolicy) setOrigin(h http.Header, origin string) {
+	// "*" is only possible without credentials, browsers reject it otherwise
+	if p.allowAll && !p.allowCredentials {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+	if p.allowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// corsRoute is a policy for the paths under prefix
+type corsRoute struct {
+	prefix string
+	policy *corsPolicy
+}
+
+// CORS applies the CORS policies of cfg - the policy of the longest matching cfg.Routes prefix, cfg.Default otherwise
+// Preflight requests (OPTIONS with Access-Control-Request-Method) are answered here: 204 when the origin, method
+// and headers are allowed, 403 when not. Actual requests from an allowed origin get the CORS headers,
+// requests from other origins are served without them, so the browser withholds the response.
+// It runs before routing, so a preflight never reaches the route's handler or its authentication.
+func CORS(cfg config.CORSConfig) (func(http.Handler) http.Handler, error) {
+	defaultPolicy, err := newCORSPolicy(cfg.Default)
+	if err != nil {
+		return nil, err
+	}
+
+	var routes []corsRoute
+	for prefix, policyCfg := range cfg.Routes {
+		policy, err := newCORSPolicy(policyCfg)
+		if err != nil {
+			return nil, fmt.Errorf("CORS policy for %s: %w", prefix, err)
+		}
+		routes = append(routes, corsRoute{prefix: strings.TrimSuffix(prefix, "/"), policy: policy})
+	}
+	// Longest prefix first, so the first match is the most specific
+	sort.Slice(routes, func(i, j int) bool {
+		return len(routes[i].prefix) > len(routes[j].prefix)
+	})
+
+	policyFor := func(path string) *corsPolicy {
+		for _, route := range routes {
+			if path == route.prefix || strings.HasPrefix(path, route.prefix+"/") {
+				return route.policy
+			}
+		}
+		return defaultPolicy
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				// Not a cross-origin browser request
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			policy := policyFor(r.URL.Path)
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+			// The response depends on the origin, caches must not hand it to other origins
+			w.Header().Add("Vary", "Origin")
+
+			if preflight {
+				w.Header().Add("Vary", "Access-Control-Request-Method")
+				w.Header().Add("Vary", "Access-Control-Request-Headers")
+
+				method := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+				requestHeaders := r.Header.Get("Access-Control-Request-Headers")
+				if !policy.allowsOrigin(origin) || !policy.methods[method] || !policy.allowsRequestHeaders(requestHeaders) {
+					writeError(w, r, http.StatusForbidden, models.CodeCORSOriginNotAllowed, "Cross-origin request not allowed")
+					return
+				}
+
+				policy.setOrigin(w.Header(), origin)
+				w.Header().Set("Access-Control-Allow-Methods", policy.allowMethods)
+				if policy.allowHeaders != "" {
+					w.Header().Set("Access-Control-Allow-Headers", policy.allowHeaders)
+				}
+				if policy.maxAge != "" {
+					w.Header().Set("Access-Control-Max-Age", policy.maxAge)
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			if policy.allowsOrigin(origin) {
+				policy.setOrigin(w.Header(), origin)
+				if policy.exposeHeaders != "" {
+					w.Header().Set("Access-Control-Expose-Headers", policy.exposeHeaders)
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}, nil
+}
@@ -0,0 +1,189 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"user-management-api/internal/auth"
+	"user-management-api/internal/logging"
+	"user-management-api/internal/models"
+)
+
+// IdempotencyKeyHeader is the request header clients set to make a request safe to retry
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+const (
+	maxIdempotencyKeyLength = 255
+	maxIdempotentBodySize   = 1 << 20 // the body is read up front to hash it
+)
+
+// replayedHeaders are the response headers stored with a response - the rest depend on the request
+var replayedHeaders = []string{"Content-Type", "ETag", "Location"}
+
+// IdempotencyStore keeps the responses of requests sent with an Idempotency-Key
+type IdempotencyStore interface {
+	Claim(ctx context.Context, caller, key, requestHash string) (*models.IdempotencyRecord, bool, error)
+	Complete(ctx context.Context, caller, key string, response models.IdempotentResponse) error
+	Release(ctx context.Context, caller, key string) error
+}
+
+// Idempotency makes requests with an Idempotency-Key header safe to retry
+// The first response is stored and replayed for retries with the same key, body and caller.
+// The same key with a different request gets 422. A retry arriving while the first request is
+// still running waits up to wait for it, then gets 409. Requests without the header are not affected.
+// It must run after Authenticate, keys are per caller.
+func Idempotency(store IdempotencyStore, wait time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				writeError(w, r, http.StatusBadRequest, models.CodeIdempotencyKeyLong, "Idempotency-Key must not exceed 255 characters")
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBodySize))
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					writeError(w, r, http.StatusRequestEntityTooLarge, models.CodeRequestTooLarge, "Request body is too large")
+					return
+				}
+				writeError(w, r, http.StatusBadRequest, models.CodeInvalidRequestBody, "Invalid request body")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			caller := "anonymous"
+			if principal, ok := auth.PrincipalFromContext(r.Context()); ok {
+				caller = principal.Subject
+			}
+			hash := requestHash(r, body)
+			deadline := time.Now().Add(wait)
+
+			for {
+				record, claimed, err := store.Claim(r.Context(), caller, key, hash)
+				if err != nil {
+					logging.FromContext(r.Context()).Error("idempotency key lookup failed", "error", err)
+					writeError(w, r, http.StatusInternalServerError, models.CodeInternal, "An unexpected error occurred")
+					return
+				}
+
+				if claimed {
+					serveAndStore(next, store, w, r, caller, key)
+					return
+				}
+
+				if record != nil {
+					if record.RequestHash != hash {
+						writeError(w, r, http.StatusUnprocessableEntity, models.CodeIdempotencyKeyReused, "Idempotency-Key was already used for a different request")
+						return
+					}
+					if record.Completed {
+						replay(w, record.Response)
+						return
+					}
+				}
+
+				// The first request is still running
+				if time.Now().After(deadline) {
+					writeError(w, r, http.StatusConflict, models.CodeIdempotencyInProgress, "A request with this Idempotency-Key is still being processed")
+					return
+				}
+				select {
+				case <-r.Context().Done():
+					return
+				case <-time.After(100 * time.Millisecond):
+				}
+			}
+		})
+	}
+}
+
+// serveAndStore runs the request and stores its response for retries
+// Server errors are not stored: the key is released so a retry runs the request again.
+func serveAndStore(next http.Handler, store IdempotencyStore, w http.ResponseWriter, r *http.Request, caller, key string) {
+	// Storing must happen even if the client went away, or the key stays stuck in processing
+	ctx := context.WithoutCancel(r.Context())
+
+	recorder := &recordingWriter{ResponseWriter: w, statusCode: http.StatusOK}
+
+	logger := logging.FromContext(ctx)
+
+	defer func() {
+		if p := recover(); p != nil {
+			if err := store.Release(ctx, caller, key); err != nil {
+				logger.Error("releasing idempotency key failed", "error", err)
+			}
+			panic(p) // let Recovery answer
+		}
+	}()
+
+	next.ServeHTTP(recorder, r)
+
+	if recorder.statusCode >= http.StatusInternalServerError {
+		if err := store.Release(ctx, caller, key); err != nil {
+			logger.Error("releasing idempotency key failed", "error", err)
+		}
+		return
+	}
+
+	response := models.IdempotentResponse{
+		StatusCode: recorder.statusCode,
+		Header:     make(http.Header),
+		Body:       recorder.body.Bytes(),
+	}
+	for _, name := range replayedHeaders {
+		if value := w.Header().Get(name); value != "" {
+			response.Header.Set(name, value)
+		}
+	}
+
+	if err := store.Complete(ctx, caller, key, response); err != nil {
+		logger.Error("storing idempotent response failed", "error", err)
+	}
+}
+
+// replay sends a stored response
+func replay(w http.ResponseWriter, response models.IdempotentResponse) {
+	for name, values := range response.Header {
+		w.Header()[name] = values
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(response.StatusCode)
+	w.Write(response.Body)
+}
+
+// requestHash identifies a request, so a key can't be reused for a different one
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recordingWriter passes the response through while keeping a copy of it
+type recordingWriter struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (rw *recordingWriter) WriteHeader(code int) {
+	rw.statusCode = code
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *recordingWriter) Write(b []byte) (int, error) {
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
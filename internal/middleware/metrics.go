@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"user-management-api/internal/metrics"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Metrics records the count and latency of every request
+// Requests that match no route share the "unmatched" route, so scanners can't create new series.
+func Metrics(m *metrics.HTTPMetrics) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+
+			next.ServeHTTP(wrapped, r)
+
+			route := "unmatched"
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				route = rctx.RoutePattern()
+			}
+			m.Observe(route, r.Method, strconv.Itoa(wrapped.statusCode), time.Since(start))
+		})
+	}
+}
@@ -1,38 +1,73 @@
 package middleware
 
 import (
-	"log"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"runtime/debug"
 	"time"
-)
 
+	"user-management-api/internal/logging"
+	"user-management-api/internal/models"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel/trace"
+)
 
+// Logger logs one line per request and puts a request scoped logger on the context
+// Read it with logging.FromContext - it carries the request ID, trace ID, method, path and remote IP,
+// plus the user ID once Authenticate has run.
 func Logger(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
-		
-		// Create a custom response writer to capture status code
+
+		logger := logging.FromContext(r.Context()).With(
+			"request_id", chimiddleware.GetReqID(r.Context()),
+			"method", r.Method,
+			"path", r.URL.Path,
+			"remote_ip", r.RemoteAddr,
+		)
+		// Set by Tracing, so logs and spans of a request can be matched up
+		if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+			logger = logger.With("trace_id", sc.TraceID().String(), "span_id", sc.SpanID().String())
+		}
+		ctx := logging.WithRequestFields(logging.WithLogger(r.Context(), logger))
+
+		// Create a custom response writer to capture status code and size
 		wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
-		
+
 		// Call the next handler
-		next.ServeHTTP(wrapped, r)
-		
-		// Log after request is complete
-		duration := time.Since(start)
-		log.Printf(
-			"%s %s %d %s",
-			r.Method,
-			r.URL.Path,
-			wrapped.statusCode,
-			duration,
-		)
+		next.ServeHTTP(wrapped, r.WithContext(ctx))
+
+		// Log after request is complete - the route pattern is only known once chi has routed the request
+		route := ""
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			route = rctx.RoutePattern()
+		}
+		attrs := []interface{}{
+			"route", route,
+			"status", wrapped.statusCode,
+			"bytes", wrapped.bytes,
+			"latency_ms", float64(time.Since(start).Microseconds()) / 1000,
+		}
+		if userID := logging.UserID(ctx); userID != "" {
+			attrs = append(attrs, "user_id", userID)
+		}
+
+		level := slog.LevelInfo
+		if wrapped.statusCode >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		logger.Log(r.Context(), level, "request completed", attrs...)
 	})
 }
 
-// responseWriter wraps http.ResponseWriter to capture status code
+// responseWriter wraps http.ResponseWriter to capture status code and bytes written
 type responseWriter struct {
 	http.ResponseWriter
 	statusCode int
+	bytes      int
 }
 
 // WriteHeader captures the status code
@@ -41,21 +76,16 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
-// CORS adds CORS headers to responses
-func CORS(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Set CORS headers
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PATCH, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-		
-		next.ServeHTTP(w, r)
-	})
+// Write counts the bytes of the body
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer (for Flush and deadlines)
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
 }
 
 // Recovery recovers from panics and returns 500 error
@@ -65,21 +95,44 @@ func Recovery(next http.Handler) http.Handler {
 		defer func() {
 			// recover() catches panics
 			if err := recover(); err != nil {
-				log.Printf("Panic recovered: %v", err)
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusInternalServerError)
-				w.Write([]byte(`{"error":"Internal Server Error","message":"An unexpected error occurred"}`))
+				if err == http.ErrAbortHandler {
+					panic(err) // the server's way to abort a response, not a bug
+				}
+
+				// The request logger already carries the request ID
+				logging.FromContext(r.Context()).Error("panic recovered",
+					"panic", fmt.Sprint(err),
+					"stack", string(debug.Stack()),
+				)
+				writeError(w, r, http.StatusInternalServerError, models.CodeInternal, "An unexpected error occurred")
 			}
 		}()
-		
+
 		next.ServeHTTP(w, r)
 	})
 }
 
+// Timeout cancels the request context after timeout (chi's Timeout, answering 504 when nothing was written yet),
+// except on the streaming routes in exempt (exact paths), which run for as long as the client stays connected
+func Timeout(timeout time.Duration, exempt ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		limited := chimiddleware.Timeout(timeout)(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, path := range exempt {
+				if r.URL.Path == path {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			limited.ServeHTTP(w, r)
+		})
+	}
+}
+
 // ContentTypeJSON ensures Content-Type is application/json
 func ContentTypeJSON(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		next.ServeHTTP(w, r)
 	})
-}
\ No newline at end of file
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"user-management-api/internal/auth"
+	"user-management-api/internal/ratelimit"
+)
+
+// apiKeyHeader is sent by clients that have an API key, which nothing authenticates
+const apiKeyHeader = "X-API-Key"
+
+func TestRateLimitClient(t *testing.T) {
+	principal := &auth.Principal{Subject: "user-1"}
+
+	tests := []struct {
+		name      string
+		apiKey    string
+		principal *auth.Principal
+		want      string
+	}{
+		{name: "subject", principal: principal, want: "sub:user-1"},
+		{name: "IP", want: "ip:192.0.2.1"},
+		{name: "unknown API key falls back to the subject", apiKey: "secret", principal: principal, want: "sub:user-1"},
+		{name: "unknown API key falls back to the IP", apiKey: "secret", want: "ip:192.0.2.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = "192.0.2.1:1234"
+			if tt.apiKey != "" {
+				r.Header.Set(apiKeyHeader, tt.apiKey)
+			}
+			if tt.principal != nil {
+				r = r.WithContext(auth.WithPrincipal(r.Context(), tt.principal))
+			}
+
+			if got := rateLimitClient(r); got != tt.want {
+				t.Errorf("rateLimitClient() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimit_NewAPIKeyDoesNotResetTheLimit(t *testing.T) {
+	limit := ratelimit.Limit{Requests: 1, Period: time.Minute, Burst: 1}
+	handler := RateLimit(ratelimit.NewMemoryStore(), "auth", limit)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func(apiKey string) int {
+		r := httptest.NewRequest(http.MethodPost, "/auth/login", nil)
+		r.Header.Set(apiKeyHeader, apiKey)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		return rec.Code
+	}
+
+	if code := send("key-a"); code != http.StatusOK {
+		t.Fatalf("first request: status = %d, want 200", code)
+	}
+	// Same IP, a made up key - still the bucket of the IP
+	if code := send("key-b"); code != http.StatusTooManyRequests {
+		t.Errorf("request with another key: status = %d, want 429", code)
+	}
+}
@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"user-management-api/internal/auth"
+	"user-management-api/internal/logging"
+	"user-management-api/internal/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// PermissionLoader looks up the permissions granted to an authenticated subject
+type PermissionLoader interface {
+	UserPermissions(ctx context.Context, subject string) ([]string, error)
+}
+
+// LoadPermissions fills in Principal.Permissions - it must run after Authenticate
+func LoadPermissions(loader PermissionLoader) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := auth.PrincipalFromContext(r.Context())
+			if !ok {
+				unauthorized(w, r, models.CodeAuthenticationRequired, "Authentication required")
+				return
+			}
+
+			permissions, err := loader.UserPermissions(r.Context(), principal.Subject)
+			if err != nil {
+				logging.FromContext(r.Context()).Error("loading permissions failed", "error", err)
+				writeError(w, r, http.StatusInternalServerError, models.CodeInternal, "An unexpected error occurred")
+				return
+			}
+			principal.Permissions = permissions
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequirePermission only lets callers with the permission through
+func RequirePermission(permission string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := auth.PrincipalFromContext(r.Context())
+			if !ok {
+				unauthorized(w, r, models.CodeAuthenticationRequired, "Authentication required")
+				return
+			}
+
+			if !principal.HasPermission(permission) {
+				forbidden(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequirePermissionOrSelf lets callers through when they have the permission,
+// or when the {param} URL parameter is their own user ID
+func RequirePermissionOrSelf(permission, param string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := auth.PrincipalFromContext(r.Context())
+			if !ok {
+				unauthorized(w, r, models.CodeAuthenticationRequired, "Authentication required")
+				return
+			}
+
+			if !principal.HasPermission(permission) && !principal.IsUser(chi.URLParam(r, param)) {
+				forbidden(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func forbidden(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, http.StatusForbidden, models.CodePermissionDenied, "You do not have permission to perform this action")
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+
+	"user-management-api/internal/audit"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+// RequestInfo puts the request ID, client IP and user agent on the context for the audit log
+// It must run after chi's RequestID middleware
+func RequestInfo(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr // no port
+		}
+
+		info := audit.RequestInfo{
+			RequestID: chimiddleware.GetReqID(r.Context()),
+			IP:        ip,
+			UserAgent: r.UserAgent(),
+		}
+
+		next.ServeHTTP(w, r.WithContext(audit.WithRequestInfo(r.Context(), info)))
+	})
+}
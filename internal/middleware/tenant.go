@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"user-management-api/internal/auth"
+	"user-management-api/internal/config"
+	"user-management-api/internal/logging"
+	"user-management-api/internal/models"
+	"user-management-api/internal/tenant"
+
+	"github.com/google/uuid"
+)
+
+// OrgResolver looks up an organization by ID or slug - found is false when there is none
+type OrgResolver interface {
+	ResolveOrg(ctx context.Context, ref string) (orgID uuid.UUID, found bool, err error)
+}
+
+// Tenant puts the organization of the request on its context - read it with tenant.OrgIDFromContext
+// Behind Authenticate the org_id claim of the token decides, and a header or subdomain naming another
+// organization is refused, so a token can't be used in someone else's organization. Requests without
+// the claim (login, tokens of other issuers) use the header, then the subdomain, then cfg.DefaultOrg.
+func Tenant(resolver OrgResolver, cfg config.TenancyConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var orgID uuid.UUID
+
+			claimed := false
+			if principal, ok := auth.PrincipalFromContext(r.Context()); ok && principal.OrgID != "" {
+				id, err := uuid.Parse(principal.OrgID)
+				if err != nil {
+					unauthorized(w, r, models.CodeInvalidToken, "Invalid or expired token")
+					return
+				}
+				orgID, claimed = id, true
+			}
+
+			ref := requestedOrg(r, cfg)
+			if ref == "" && !claimed {
+				ref = cfg.DefaultOrg
+			}
+			if ref != "" {
+				id, found, err := resolver.ResolveOrg(r.Context(), ref)
+				if err != nil {
+					logging.FromContext(r.Context()).Error("resolving organization failed", "error", err)
+					writeError(w, r, http.StatusInternalServerError, models.CodeInternal, "An unexpected error occurred")
+					return
+				}
+				if !found {
+					writeError(w, r, http.StatusNotFound, models.CodeOrgNotFound, "Organization not found")
+					return
+				}
+				if claimed && id != orgID {
+					writeError(w, r, http.StatusForbidden, models.CodeOrgMismatch, "The token belongs to another organization")
+					return
+				}
+				orgID = id
+			}
+
+			if orgID == uuid.Nil {
+				writeError(w, r, http.StatusBadRequest, models.CodeInvalidOrgID, "No organization given")
+				return
+			}
+
+			ctx := tenant.WithOrgID(r.Context(), orgID)
+			ctx = logging.With(ctx, "org_id", orgID)
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// requestedOrg returns the organization named by the header or the subdomain, "" when neither names one
+func requestedOrg(r *http.Request, cfg config.TenancyConfig) string {
+	if cfg.Header != "" {
+		if ref := strings.TrimSpace(r.Header.Get(cfg.Header)); ref != "" {
+			return ref
+		}
+	}
+
+	if cfg.BaseDomain != "" {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+		// Only a single label in front of the base domain is a slug - a.b.users.example.com is not
+		slug, ok := strings.CutSuffix(host, "."+strings.ToLower(cfg.BaseDomain))
+		if ok && slug != "" && !strings.Contains(slug, ".") {
+			return slug
+		}
+	}
+
+	return ""
+}
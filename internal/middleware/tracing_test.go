@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"user-management-api/internal/tracing"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newTracedRouter(t *testing.T) (http.Handler, *tracetest.InMemoryExporter) {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	tracing.SetDefault(provider)
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+
+	r := chi.NewRouter()
+	r.Use(Tracing)
+	r.Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		// Handlers see the span of the request
+		if !trace.SpanContextFromContext(r.Context()).IsValid() {
+			t.Error("handler context has no span")
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	r.Get("/fail", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	r.Get("/missing", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	return r, exporter
+}
+
+func attributeValue(span tracetest.SpanStub, key attribute.Key) (attribute.Value, bool) {
+	for _, attr := range span.Attributes {
+		if attr.Key == key {
+			return attr.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestTracing_NamesSpanAfterRoute(t *testing.T) {
+	handler, exporter := newTracedRouter(t)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/42", nil))
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("exported %d spans, want 1", len(spans))
+	}
+	span := spans[0]
+	if span.Name != "GET /users/{id}" {
+		t.Errorf("span name is %q, want %q", span.Name, "GET /users/{id}")
+	}
+	if span.SpanKind != trace.SpanKindServer {
+		t.Errorf("span kind is %v, want server", span.SpanKind)
+	}
+	if route, _ := attributeValue(span, "http.route"); route.AsString() != "/users/{id}" {
+		t.Errorf("http.route is %q, want /users/{id}", route.AsString())
+	}
+	if status, _ := attributeValue(span, "http.response.status_code"); status.AsInt64() != http.StatusOK {
+		t.Errorf("http.response.status_code is %d, want 200", status.AsInt64())
+	}
+	if span.Status.Code == codes.Error {
+		t.Error("span of a successful request failed")
+	}
+
+	// The response names the trace of the request
+	traceparent := rec.Header().Get("traceparent")
+	if !strings.Contains(traceparent, span.SpanContext.TraceID().String()) {
+		t.Errorf("traceparent response header %q doesn't name trace %s", traceparent, span.SpanContext.TraceID())
+	}
+}
+
+func TestTracing_JoinsIncomingTrace(t *testing.T) {
+	handler, exporter := newTracedRouter(t)
+
+	const traceID, parentID = "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"
+	req := httptest.NewRequest(http.MethodGet, "/users/42", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-"+parentID+"-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("exported %d spans, want 1", len(spans))
+	}
+	if got := spans[0].SpanContext.TraceID().String(); got != traceID {
+		t.Errorf("trace ID is %s, want %s", got, traceID)
+	}
+	if got := spans[0].Parent.SpanID().String(); got != parentID {
+		t.Errorf("parent span ID is %s, want %s", got, parentID)
+	}
+	if !spans[0].Parent.IsRemote() {
+		t.Error("parent is not marked remote")
+	}
+}
+
+func TestTracing_ServerErrorsFailTheSpan(t *testing.T) {
+	handler, exporter := newTracedRouter(t)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fail", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("exported %d spans, want 2", len(spans))
+	}
+	if spans[0].Status.Code != codes.Error {
+		t.Errorf("span of a 500 has status %v, want error", spans[0].Status.Code)
+	}
+	// Client errors are the client's problem
+	if spans[1].Status.Code == codes.Error {
+		t.Error("span of a 404 failed")
+	}
+}
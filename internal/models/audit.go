@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"user-management-api/internal/audit"
+
+	"github.com/google/uuid"
+)
+
+// ListAuditEventsQuery holds the query string options of the audit endpoints
+// json tags are the query parameter names, so validation errors point at the right parameter
+type ListAuditEventsQuery struct {
+	Limit   int        `json:"limit" validate:"min=1,max=100"`
+	Cursor  string     `json:"cursor"`
+	UserID  *uuid.UUID `json:"-"` // from the path of /users/{id}/audit
+	ActorID string     `json:"actor_id" validate:"omitempty,max=255"`
+	From    *time.Time `json:"from"`
+	To      *time.Time `json:"to"`
+}
+
+// Responses
+type AuditEventResponse struct {
+	EventID      uuid.UUID               `json:"eventId"`
+	OccurredAt   time.Time               `json:"occurredAt"`
+	ActorID      *string                 `json:"actorId,omitempty"` // missing for changes made by the system
+	Action       string                  `json:"action"`
+	ResourceType string                  `json:"resourceType"`
+	ResourceID   uuid.UUID               `json:"resourceId"`
+	RequestID    *string                 `json:"requestId,omitempty"`
+	IPAddress    *string                 `json:"ipAddress,omitempty"`
+	UserAgent    *string                 `json:"userAgent,omitempty"`
+	Changes      map[string]audit.Change `json:"changes"`
+}
+
+type ListAuditEventsResponse struct {
+	Events     []AuditEventResponse `json:"events"`
+	NextCursor string               `json:"nextCursor,omitempty"` // pass as ?cursor= to get the next page
+	HasMore    bool                 `json:"hasMore"`
+}
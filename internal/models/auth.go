@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Requests
+type LoginRequest struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required,max=72"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required,max=255"` // from the link in the verification mail
+}
+
+// Responses
+type TokenResponse struct {
+	AccessToken  string `json:"accessToken"`
+	TokenType    string `json:"tokenType"` // always "Bearer"
+	ExpiresIn    int    `json:"expiresIn"` // access token lifetime in seconds
+	RefreshToken string `json:"refreshToken"`
+}
+
+type VerifyEmailResponse struct {
+	UserID          uuid.UUID `json:"userId"`
+	Email           string    `json:"email"`
+	EmailVerifiedAt time.Time `json:"emailVerifiedAt"`
+}
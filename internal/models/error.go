@@ -3,12 +3,79 @@ package models
 import (
 	"fmt"
 	"net/http"
+	"strings"
+)
+
+// Error codes - the machine-readable "code" of problem responses
+// Clients branch on these, so never change the value of an existing code.
+const (
+	// 400
+	CodeValidationFailed         = "VALIDATION_FAILED"
+	CodeInvalidRequestBody       = "INVALID_REQUEST_BODY"
+	CodeInvalidUserID            = "INVALID_USER_ID"
+	CodeInvalidWebhookID         = "INVALID_WEBHOOK_ID"
+	CodeInvalidDeliveryID        = "INVALID_DELIVERY_ID"
+	CodeInvalidCursor            = "INVALID_CURSOR"
+	CodeInvalidAgeRange          = "INVALID_AGE_RANGE"
+	CodeRoleUnknown              = "ROLE_UNKNOWN"
+	CodeIdempotencyKeyLong       = "IDEMPOTENCY_KEY_TOO_LONG"
+	CodeInvalidImportFile        = "INVALID_IMPORT_FILE"
+	CodeInvalidImportJobID       = "INVALID_IMPORT_JOB_ID"
+	CodeInvalidSearchQuery       = "INVALID_SEARCH_QUERY"
+	CodeInvalidOrgID             = "INVALID_ORG_ID"
+	CodeInvalidGroupID           = "INVALID_GROUP_ID"
+	CodeInvalidVerificationToken = "INVALID_VERIFICATION_TOKEN"
+
+	// 401
+	CodeAuthenticationRequired = "AUTHENTICATION_REQUIRED"
+	CodeInvalidToken           = "INVALID_TOKEN"
+	CodeInvalidCredentials     = "INVALID_CREDENTIALS"
+	CodeInvalidRefreshToken    = "INVALID_REFRESH_TOKEN"
+	CodeRefreshTokenReused     = "REFRESH_TOKEN_REUSED"
+
+	// 403
+	CodePermissionDenied     = "PERMISSION_DENIED"
+	CodeUserInactive         = "USER_INACTIVE"
+	CodeCORSOriginNotAllowed = "CORS_ORIGIN_NOT_ALLOWED"
+	CodeOrgMismatch          = "ORG_MISMATCH"
+
+	// 404
+	CodeUserNotFound        = "USER_NOT_FOUND"
+	CodeRoleNotAssigned     = "ROLE_NOT_ASSIGNED"
+	CodeWebhookNotFound     = "WEBHOOK_NOT_FOUND"
+	CodeDeliveryNotFound    = "DELIVERY_NOT_FOUND"
+	CodeImportJobNotFound   = "IMPORT_JOB_NOT_FOUND"
+	CodeOrgNotFound         = "ORG_NOT_FOUND"
+	CodeGroupNotFound       = "GROUP_NOT_FOUND"
+	CodeGroupMemberNotFound = "GROUP_MEMBER_NOT_FOUND"
+	CodeRouteNotFound       = "ROUTE_NOT_FOUND"
+
+	// 405
+	CodeMethodNotAllowed = "METHOD_NOT_ALLOWED"
+
+	// 409, 412, 413, 415, 422, 429
+	CodeUserEmailTaken        = "USER_EMAIL_TAKEN"
+	CodeIdempotencyInProgress = "IDEMPOTENCY_REQUEST_IN_PROGRESS"
+	CodeVersionMismatch       = "VERSION_MISMATCH"
+	CodeRequestTooLarge       = "REQUEST_TOO_LARGE"
+	CodeUnsupportedMediaType  = "UNSUPPORTED_MEDIA_TYPE"
+	CodeIdempotencyKeyReused  = "IDEMPOTENCY_KEY_REUSED"
+	CodeRateLimited           = "RATE_LIMITED"
+	CodeOrgSlugTaken          = "ORG_SLUG_TAKEN"
+	CodeOrgNotEmpty           = "ORG_NOT_EMPTY"
+	CodeGroupNameTaken        = "GROUP_NAME_TAKEN"
+	CodeEmailAlreadyVerified  = "EMAIL_ALREADY_VERIFIED"
+	CodeLastAdmin             = "LAST_ADMIN"
+
+	// 500
+	CodeInternal = "INTERNAL_ERROR"
 )
 
 type AppError struct {
 	StatusCode int    `json:"status_code"`
+	Code       string `json:"code"` // one of the Code constants, stable for clients to branch on
 	Message    string `json:"message"`
-	Err        error  `json:"-"`  // internal error, not exposed to clients in json responses
+	Err        error  `json:"-"` // internal error, not exposed to clients in json responses
 }
 
 func (e *AppError) Error() string {
@@ -18,45 +85,104 @@ func (e *AppError) Error() string {
 	return e.Message
 }
 
-func NewBadRequestError(message string) *AppError {
+// Unwrap exposes the internal error to errors.Is / errors.As
+func (e *AppError) Unwrap() error {
+	return e.Err
+}
+
+func NewBadRequestError(code, message string) *AppError {
 	return &AppError{
 		StatusCode: http.StatusBadRequest,
+		Code:       code,
 		Message:    message,
 	}
 }
 
-
-func NewNotFoundError(message string) *AppError {
+func NewNotFoundError(code, message string) *AppError {
 	return &AppError{
-		StatusCode:    http.StatusNotFound,
-		Message: message,
+		StatusCode: http.StatusNotFound,
+		Code:       code,
+		Message:    message,
 	}
 }
 
+// NewInternalServerError - the code is always CodeInternal, clients can't act on the cause
 func NewInternalServerError(message string, err error) *AppError {
 	return &AppError{
-		StatusCode:    http.StatusInternalServerError,
-		Message: message,
-		Err:     err,
+		StatusCode: http.StatusInternalServerError,
+		Code:       CodeInternal,
+		Message:    message,
+		Err:        err,
+	}
+}
+
+func NewConflictError(code, message string) *AppError {
+	return &AppError{
+		StatusCode: http.StatusConflict,
+		Code:       code,
+		Message:    message,
+	}
+}
+
+func NewUnauthorizedError(code, message string) *AppError {
+	return &AppError{
+		StatusCode: http.StatusUnauthorized,
+		Code:       code,
+		Message:    message,
+	}
+}
+
+func NewForbiddenError(code, message string) *AppError {
+	return &AppError{
+		StatusCode: http.StatusForbidden,
+		Code:       code,
+		Message:    message,
 	}
 }
 
-func NewConflictError(message string) *AppError {
+func NewPreconditionFailedError(code, message string) *AppError {
 	return &AppError{
-		StatusCode:    http.StatusConflict,
-		Message: message,
+		StatusCode: http.StatusPreconditionFailed,
+		Code:       code,
+		Message:    message,
 	}
 }
 
-// ErrorResponse is the JSON structure sent to clients
-type ErrorResponse struct {
-	Error   string            `json:"error"`
-	Message string            `json:"message"`
-	Details map[string]string `json:"details,omitempty"` // omitempty = exclude if empty
+// ProblemContentType is the media type of Problem responses
+const ProblemContentType = "application/problem+json"
+
+// Problem is the RFC 9457 problem details body of every error response
+type Problem struct {
+	Type          string         `json:"type" example:"/problems/user-email-taken"` // identifies the kind of problem, one per code
+	Title         string         `json:"title" example:"Conflict"`
+	Status        int            `json:"status" example:"409"`
+	Detail        string         `json:"detail,omitempty" example:"Email already exists"`
+	Instance      string         `json:"instance,omitempty" example:"/api/v1/users"` // the request path
+	Code          string         `json:"code" example:"USER_EMAIL_TAKEN"`
+	TraceID       string         `json:"traceId,omitempty"` // quote it when reporting a problem, it finds the request's logs and spans
+	RequestID     string         `json:"requestId,omitempty"`
+	InvalidParams []InvalidParam `json:"invalidParams,omitempty"` // only for VALIDATION_FAILED
+}
+
+// InvalidParam is one field that failed validation
+type InvalidParam struct {
+	Name   string `json:"name" example:"email"`
+	Reason string `json:"reason" example:"must be a valid email address"`
 }
 
-// ValidationError represents validation failures
-type ValidationError struct {
-	Field   string `json:"field"`
-	Message string `json:"message"`
-}
\ No newline at end of file
+// NewProblem creates the problem for status and code, the caller fills in the request fields
+func NewProblem(status int, code, detail string) *Problem {
+	return &Problem{
+		Type:   ProblemType(code),
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// ProblemType is the type URI of code - USER_EMAIL_TAKEN becomes /problems/user-email-taken
+// A relative reference, so it resolves against whatever host serves the API.
+func ProblemType(code string) string {
+	return "/problems/" + strings.ToLower(strings.ReplaceAll(code, "_", "-"))
+}
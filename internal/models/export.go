@@ -0,0 +1,25 @@
+package models
+
+// Export file formats
+const (
+	ExportFormatCSV    = "csv"
+	ExportFormatNDJSON = "ndjson" // one user JSON object per line
+	ExportFormatXLSX   = "xlsx"
+)
+
+// ExportColumns are the columns a user export can have, in their default order
+// The names are the JSON field names of UserResponse.
+var ExportColumns = []string{
+	"userId", "firstName", "lastName", "email", "phone", "age", "status", "createdAt", "updatedAt", "deletedAt", "version",
+	"emailVerifiedAt",
+}
+
+// ExportUsersQuery holds the query string options of GET /users/export
+type ExportUsersQuery struct {
+	// csv when missing
+	Format string `json:"format" validate:"oneof=csv ndjson xlsx"`
+	// All of ExportColumns when empty
+	Columns []string `json:"columns" validate:"omitempty,unique,dive,oneof=userId firstName lastName email phone age status createdAt updatedAt deletedAt version emailVerifiedAt"`
+	UserFilters
+	Sort string `json:"sort" validate:"omitempty,oneof=created_at -created_at updated_at -updated_at first_name -first_name last_name -last_name email -email"`
+}
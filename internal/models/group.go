@@ -0,0 +1,94 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Member roles - owners can manage the members of their group without the groups:manage permission
+const (
+	GroupRoleOwner  = "owner"
+	GroupRoleMember = "member"
+)
+
+// Requests
+type CreateGroupRequest struct {
+	Name        string  `json:"name" validate:"required,min=1,max=100"`
+	Description *string `json:"description,omitempty" validate:"omitempty,max=255"`
+}
+
+type UpdateGroupRequest struct {
+	Name        *string `json:"name,omitempty" validate:"omitempty,min=1,max=100"`
+	Description *string `json:"description,omitempty" validate:"omitempty,max=255"`
+}
+
+type GroupMemberInput struct {
+	UserID uuid.UUID `json:"userId" validate:"required"`
+	Role   string    `json:"role,omitempty" validate:"omitempty,oneof=owner member"` // member when empty
+}
+
+// AddGroupMembersRequest adds users to a group - users that are already members get the given role
+type AddGroupMembersRequest struct {
+	Members []GroupMemberInput `json:"members" validate:"required,min=1,max=1000,dive"`
+}
+
+type RemoveGroupMembersRequest struct {
+	UserIDs []uuid.UUID `json:"userIds" validate:"required,min=1,max=1000"`
+}
+
+// ListGroupMembersQuery holds the query string options of the member list
+type ListGroupMembersQuery struct {
+	Limit  int    `json:"limit" validate:"min=1,max=100"`
+	Cursor string `json:"cursor"`
+}
+
+// Responses
+type GroupResponse struct {
+	ID          uuid.UUID `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+type ListGroupsResponse struct {
+	Groups []GroupResponse `json:"groups"`
+}
+
+type GroupMemberResponse struct {
+	UserID    uuid.UUID `json:"userId"`
+	FirstName string    `json:"firstName,omitempty"`
+	LastName  string    `json:"lastName,omitempty"`
+	Email     string    `json:"email,omitempty"`
+	Role      string    `json:"role"`
+	JoinedAt  time.Time `json:"joinedAt"`
+}
+
+type ListGroupMembersResponse struct {
+	Members    []GroupMemberResponse `json:"members"`
+	NextCursor string                `json:"nextCursor,omitempty"` // pass as ?cursor= to get the next page
+	HasMore    bool                  `json:"hasMore"`
+}
+
+// GroupMembersChangeResponse is the outcome of a bulk add or remove
+type GroupMembersChangeResponse struct {
+	GroupID uuid.UUID             `json:"groupId"`
+	Added   []GroupMemberResponse `json:"added,omitempty"` // members added or given a new role
+	Removed []uuid.UUID           `json:"removed,omitempty"`
+	// NotFound lists the users that were skipped - unknown or deleted users when adding, non-members when removing
+	NotFound []uuid.UUID `json:"notFound"`
+}
+
+type UserGroupResponse struct {
+	GroupID     uuid.UUID `json:"groupId"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Role        string    `json:"role"`
+	JoinedAt    time.Time `json:"joinedAt"`
+}
+
+type UserGroupsResponse struct {
+	UserID uuid.UUID           `json:"userId"`
+	Groups []UserGroupResponse `json:"groups"`
+}
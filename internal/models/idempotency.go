@@ -0,0 +1,17 @@
+package models
+
+import "net/http"
+
+// IdempotentResponse is a stored response, replayed to retries with the same Idempotency-Key
+type IdempotentResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// IdempotencyRecord is what is stored for an Idempotency-Key
+type IdempotencyRecord struct {
+	RequestHash string
+	Completed   bool               // false while the first request is still running
+	Response    IdempotentResponse // set once Completed
+}
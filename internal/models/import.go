@@ -0,0 +1,106 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Import file formats
+const (
+	ImportFormatCSV    = "csv"
+	ImportFormatNDJSON = "ndjson" // one CreateUserRequest JSON object per line
+)
+
+// What an import does with rows whose email is already registered
+const (
+	OnConflictSkip   = "skip"   // leave the existing user alone
+	OnConflictUpdate = "update" // update the existing user from the row
+	OnConflictFail   = "fail"   // import nothing at all (the default)
+)
+
+// Row statuses of an import report - a dry run reports what would happen
+const (
+	ImportRowCreated     = "created"
+	ImportRowUpdated     = "updated"
+	ImportRowSkipped     = "skipped"
+	ImportRowFailed      = "failed"
+	ImportRowNotImported = "not_imported" // fine, but on_conflict=fail stopped the import because of other rows
+)
+
+// Import job statuses
+const (
+	ImportJobPending   = "pending"
+	ImportJobRunning   = "running"
+	ImportJobCompleted = "completed"
+	ImportJobFailed    = "failed" // nothing more will be imported, see error
+)
+
+// Pagination limits for the row report of an import job
+const (
+	DefaultImportRowsLimit = 100
+	MaxImportRowsLimit     = 1000
+)
+
+// ImportUsersQuery holds the query string options of POST /users/import
+type ImportUsersQuery struct {
+	Format     string `json:"format" validate:"omitempty,oneof=csv ndjson"` // taken from Content-Type when empty
+	DryRun     bool   `json:"dry_run"`
+	OnConflict string `json:"on_conflict" validate:"omitempty,oneof=skip update fail"`
+	Mapping    string `json:"mapping" validate:"omitempty,max=2000"` // "CSV header:field,..." e.g. "Given Name:firstName,Surname:lastName"
+	Async      bool   `json:"async"`                                 // queue the import even when it is small
+}
+
+// GetImportJobQuery holds the query string options of GET /users/import/{jobId}
+type GetImportJobQuery struct {
+	Limit  int    `json:"limit" validate:"min=1,max=1000"`
+	Cursor string `json:"cursor"`
+}
+
+// Responses
+
+// ImportRowResult is the outcome of one row, Line is its line in the file
+type ImportRowResult struct {
+	Line          int            `json:"line"`
+	Email         string         `json:"email,omitempty"`
+	Status        string         `json:"status" example:"created"`
+	UserID        *uuid.UUID     `json:"userId,omitempty"`
+	Message       string         `json:"message,omitempty"`
+	InvalidParams []InvalidParam `json:"invalidParams,omitempty"` // the fields that failed validation
+}
+
+// ImportReport is the outcome of an import that ran during the request
+type ImportReport struct {
+	Status     string            `json:"status" example:"completed"` // "completed" or "failed"
+	DryRun     bool              `json:"dryRun"`                     // nothing was written, the rows say what would happen
+	OnConflict string            `json:"onConflict"`
+	Total      int               `json:"total"`
+	Created    int               `json:"created"`
+	Updated    int               `json:"updated"`
+	Skipped    int               `json:"skipped"`
+	Failed     int               `json:"failed"`
+	Error      string            `json:"error,omitempty"`
+	Rows       []ImportRowResult `json:"rows"`
+}
+
+// ImportJobResponse is a queued import - Rows holds one page of the row report, written as the job runs
+type ImportJobResponse struct {
+	JobID      uuid.UUID         `json:"jobId"`
+	Status     string            `json:"status" example:"running"`
+	Format     string            `json:"format"`
+	DryRun     bool              `json:"dryRun"`
+	OnConflict string            `json:"onConflict"`
+	Total      int               `json:"total"`
+	Processed  int               `json:"processed"`
+	Created    int               `json:"created"`
+	Updated    int               `json:"updated"`
+	Skipped    int               `json:"skipped"`
+	Failed     int               `json:"failed"`
+	Error      string            `json:"error,omitempty"`
+	CreatedAt  time.Time         `json:"createdAt"`
+	StartedAt  *time.Time        `json:"startedAt,omitempty"`
+	FinishedAt *time.Time        `json:"finishedAt,omitempty"`
+	Rows       []ImportRowResult `json:"rows"`
+	NextCursor string            `json:"nextCursor,omitempty"` // pass as ?cursor= to get the next page of rows
+	HasMore    bool              `json:"hasMore"`
+}
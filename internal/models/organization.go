@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Requests
+type CreateOrganizationRequest struct {
+	Slug string `json:"slug" validate:"required,slug"` // also the subdomain of the organization
+	Name string `json:"name" validate:"required,min=1,max=100"`
+}
+
+type UpdateOrganizationRequest struct {
+	Slug *string `json:"slug,omitempty" validate:"omitempty,slug"`
+	Name *string `json:"name,omitempty" validate:"omitempty,min=1,max=100"`
+}
+
+// Responses
+type OrganizationResponse struct {
+	ID        uuid.UUID `json:"id"`
+	Slug      string    `json:"slug"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type ListOrganizationsResponse struct {
+	Organizations []OrganizationResponse `json:"organizations"`
+}
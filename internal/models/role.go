@@ -0,0 +1,24 @@
+package models
+
+import "github.com/google/uuid"
+
+// Requests
+type AssignRoleRequest struct {
+	Role string `json:"role" validate:"required,max=50"`
+}
+
+// Responses
+type RoleResponse struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Permissions []string `json:"permissions"`
+}
+
+type ListRolesResponse struct {
+	Roles []RoleResponse `json:"roles"`
+}
+
+type UserRolesResponse struct {
+	UserID uuid.UUID `json:"userId"`
+	Roles  []string  `json:"roles"`
+}
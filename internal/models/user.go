@@ -21,6 +21,7 @@ type CreateUserRequest struct {
 	Phone     *string    `json:"phone,omitempty" validate:"omitempty,e164"` // Pointer = optional field
 	Age       *int       `json:"age,omitempty" validate:"omitempty,gt=0"`
 	Status    UserStatus `json:"status,omitempty" validate:"omitempty,oneof=Active Inactive"`
+	Password  string     `json:"password,omitempty" validate:"omitempty,password"` // optional - users without one can't log in
 }
 
 type UpdateUserRequest struct {
@@ -34,24 +35,84 @@ type UpdateUserRequest struct {
 
 // Responses
 type UserResponse struct {
-	UserID    uuid.UUID  `json:"userId"`
-	FirstName string     `json:"firstName"`
-	LastName  string     `json:"lastName"`
-	Email     string     `json:"email"`
-	Phone     *string    `json:"phone,omitempty"`
-	Age       *int       `json:"age,omitempty"`
-	Status    UserStatus `json:"status"`
-	CreatedAt time.Time  `json:"createdAt"`
-	UpdatedAt time.Time  `json:"updatedAt"`
+	UserID          uuid.UUID  `json:"userId"`
+	OrgID           uuid.UUID  `json:"orgId"` // the organization the user belongs to
+	FirstName       string     `json:"firstName"`
+	LastName        string     `json:"lastName"`
+	Email           string     `json:"email"`
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt,omitempty"` // unset until the user confirms the address, and again after a change
+	Phone           *string    `json:"phone,omitempty"`
+	Age             *int       `json:"age,omitempty"`
+	Status          UserStatus `json:"status"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	UpdatedAt       time.Time  `json:"updatedAt"`
+	DeletedAt       *time.Time `json:"deletedAt,omitempty"` // only set for soft deleted users
+	Version         int        `json:"version"`             // bumped on every change, also sent as the ETag header
+}
+
+// Pagination limits for listing users
+const (
+	DefaultListLimit = 20
+	MaxListLimit     = 100
+)
+
+// ListUsersQuery holds the query string options of GET /users
+// json tags are the query parameter names, so validation errors point at the right parameter
+type ListUsersQuery struct {
+	Limit  int    `json:"limit" validate:"min=1,max=100"`
+	Cursor string `json:"cursor"`
+	UserFilters
+	Sort string `json:"sort" validate:"omitempty,oneof=created_at -created_at updated_at -updated_at first_name -first_name last_name -last_name email -email"`
+}
+
+// UserFilters are the filters shared by listing and exporting users
+type UserFilters struct {
+	Status         UserStatus `json:"status" validate:"omitempty,oneof=Active Inactive"`
+	Email          string     `json:"email" validate:"omitempty,max=255"`
+	Name           string     `json:"name" validate:"omitempty,max=100"`
+	MinAge         *int       `json:"min_age" validate:"omitempty,gt=0"`
+	MaxAge         *int       `json:"max_age" validate:"omitempty,gt=0"`
+	IncludeDeleted bool       `json:"include_deleted"` // admins only
 }
 
 type ListUsersResponse struct {
-	Users []UserResponse `json:"users"`
-	Total int            `json:"total"`
+	Users      []UserResponse `json:"users"`
+	Total      int            `json:"total"` // number of users matching the filters, not just this page
+	Limit      int            `json:"limit"`
+	NextCursor string         `json:"nextCursor,omitempty"` // pass as ?cursor= to get the next page
+	HasMore    bool           `json:"hasMore"`
+}
+
+// Search modes
+const (
+	SearchModeFull   = "full"   // every word must match a whole word, or be similar to a field
+	SearchModePrefix = "prefix" // autocomplete - words match the start of words, "jo smi" finds John Smith
+)
+
+// SearchUsersQuery holds the query string options of GET /users/search
+type SearchUsersQuery struct {
+	Q      string `json:"q" validate:"required,max=200"`
+	Mode   string `json:"mode" validate:"omitempty,oneof=full prefix"`
+	Limit  int    `json:"limit" validate:"min=1,max=100"`
+	Cursor string `json:"cursor"`
 }
 
+// UserSearchResult is a user found by a search
+type UserSearchResult struct {
+	UserResponse
+	Score float64 `json:"score" example:"1.06"` // above 1 for full-text matches, below 1 for matches that are only similar
+	// HTML escaped copies of the fields that contain a search word, with the words in <mark> tags
+	Highlights map[string]string `json:"highlights,omitempty"`
+}
+
+type SearchUsersResponse struct {
+	Results    []UserSearchResult `json:"results"` // best match first
+	Limit      int                `json:"limit"`
+	NextCursor string             `json:"nextCursor,omitempty"` // pass as ?cursor= with the same q and mode to get the next page
+	HasMore    bool               `json:"hasMore"`
+}
 
 type SuccessResponse struct {
 	Message string      `json:"message"`
-	Data    interface{} `json:"data,omitempty"` // interface{} = any type 
-}
\ No newline at end of file
+	Data    interface{} `json:"data,omitempty"` // interface{} = any type
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Delivery statuses
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryDead      = "dead" // gave up after the maximum number of attempts
+)
+
+// Requests
+type CreateWebhookRequest struct {
+	URL         string   `json:"url" validate:"required,http_url,max=2048"`
+	Secret      string   `json:"secret,omitempty" validate:"omitempty,min=16,max=255"` // generated when empty
+	EventTypes  []string `json:"eventTypes,omitempty" validate:"omitempty,dive,oneof=UserCreated UserUpdated UserStatusChanged UserDeleted UserRestored"`
+	Description *string  `json:"description,omitempty" validate:"omitempty,max=255"`
+	Active      *bool    `json:"active,omitempty"` // defaults to true
+}
+
+type UpdateWebhookRequest struct {
+	URL         *string  `json:"url,omitempty" validate:"omitempty,http_url,max=2048"`
+	Secret      *string  `json:"secret,omitempty" validate:"omitempty,min=16,max=255"`
+	EventTypes  []string `json:"eventTypes,omitempty" validate:"omitempty,dive,oneof=UserCreated UserUpdated UserStatusChanged UserDeleted UserRestored"`
+	Description *string  `json:"description,omitempty" validate:"omitempty,max=255"`
+	Active      *bool    `json:"active,omitempty"`
+}
+
+// ListWebhookDeliveriesQuery holds the query string options of the delivery log
+type ListWebhookDeliveriesQuery struct {
+	Limit  int    `json:"limit" validate:"min=1,max=100"`
+	Cursor string `json:"cursor"`
+	Status string `json:"status" validate:"omitempty,oneof=pending succeeded dead"`
+}
+
+// Responses
+type WebhookResponse struct {
+	ID          uuid.UUID `json:"id"`
+	URL         string    `json:"url"`
+	EventTypes  []string  `json:"eventTypes"` // empty means every event type
+	Description *string   `json:"description,omitempty"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// CreateWebhookResponse is the only response that includes the secret
+type CreateWebhookResponse struct {
+	WebhookResponse
+	Secret string `json:"secret"`
+}
+
+type ListWebhooksResponse struct {
+	Webhooks []WebhookResponse `json:"webhooks"`
+}
+
+type WebhookDeliveryResponse struct {
+	ID             uuid.UUID  `json:"id"`
+	WebhookID      uuid.UUID  `json:"webhookId"`
+	EventID        uuid.UUID  `json:"eventId"`
+	EventType      string     `json:"eventType"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  *time.Time `json:"nextAttemptAt,omitempty"` // only for pending deliveries
+	LastAttemptAt  *time.Time `json:"lastAttemptAt,omitempty"`
+	LastStatusCode *int       `json:"lastStatusCode,omitempty"`
+	LastError      *string    `json:"lastError,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
+
+type ListWebhookDeliveriesResponse struct {
+	Deliveries []WebhookDeliveryResponse `json:"deliveries"`
+	NextCursor string                    `json:"nextCursor,omitempty"` // pass as ?cursor= to get the next page
+	HasMore    bool                      `json:"hasMore"`
+}
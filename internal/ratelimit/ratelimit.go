@@ -0,0 +1,98 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit allows Requests per Period on average, with bursts of up to Burst requests
+type Limit struct {
+	Requests int
+	Period   time.Duration
+	Burst    int
+}
+
+// ParseLimit reads a limit written as "<requests>/<period>[:<burst>]", e.g. "100/1m" or "100/1m:20"
+// The burst defaults to the number of requests.
+func ParseLimit(s string) (Limit, error) {
+	rate, burst, hasBurst := strings.Cut(s, ":")
+	requests, period, found := strings.Cut(rate, "/")
+	if !found {
+		return Limit{}, fmt.Errorf("%q is not <requests>/<period>[:<burst>]", s)
+	}
+
+	var l Limit
+	var err error
+	if l.Requests, err = strconv.Atoi(requests); err != nil || l.Requests < 1 {
+		return Limit{}, fmt.Errorf("%q: requests must be a whole number of at least 1", s)
+	}
+	if l.Period, err = time.ParseDuration(period); err != nil || l.Period <= 0 {
+		return Limit{}, fmt.Errorf("%q: period must be a positive duration such as 1m", s)
+	}
+	l.Burst = l.Requests
+	if hasBurst {
+		if l.Burst, err = strconv.Atoi(burst); err != nil || l.Burst < 1 {
+			return Limit{}, fmt.Errorf("%q: burst must be a whole number of at least 1", s)
+		}
+	}
+
+	return l, nil
+}
+
+func (l Limit) String() string {
+	return fmt.Sprintf("%d/%s:%d", l.Requests, l.Period, l.Burst)
+}
+
+// interval is the time one request "costs"
+func (l Limit) interval() time.Duration {
+	return l.Period / time.Duration(l.Requests)
+}
+
+// Result is the outcome of one request
+type Result struct {
+	Allowed    bool
+	Limit      Limit
+	Remaining  int           // requests that would still be allowed right now
+	ResetAfter time.Duration // until the full burst is available again
+	RetryAfter time.Duration // until the next request is allowed, 0 when allowed
+}
+
+// Store keeps the limiter state of every key
+type Store interface {
+	// Allow counts one request against key and reports whether it fits the limit
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// GCRA is the generic cell rate algorithm: the whole state of a key is its "theoretical arrival time" (TAT),
+// the time at which the key would be back to a full burst. Every allowed request pushes it one interval further.
+// It returns the new TAT to store - unchanged when the request is denied.
+func GCRA(tat, now time.Time, limit Limit) (time.Time, Result) {
+	interval := limit.interval()
+	tolerance := interval * time.Duration(limit.Burst)
+
+	if tat.Before(now) {
+		tat = now
+	}
+	newTAT := tat.Add(interval)
+	allowAt := newTAT.Add(-tolerance)
+
+	if now.Before(allowAt) {
+		return tat, Result{
+			Allowed:    false,
+			Limit:      limit,
+			Remaining:  0,
+			ResetAfter: tat.Sub(now),
+			RetryAfter: allowAt.Sub(now),
+		}
+	}
+
+	return newTAT, Result{
+		Allowed:    true,
+		Limit:      limit,
+		Remaining:  int(now.Sub(allowAt) / interval),
+		ResetAfter: newTAT.Sub(now),
+	}
+}
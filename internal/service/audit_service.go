@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	database "user-management-api/db/sqlc"
+	"user-management-api/internal/audit"
+	"user-management-api/internal/auth"
+	"user-management-api/internal/models"
+	"user-management-api/internal/utils"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type AuditService struct {
+	queries database.Querier
+}
+
+func NewAuditService(queries database.Querier) *AuditService {
+	return &AuditService{
+		queries: queries,
+	}
+}
+
+// ListEvents returns a page of audit events, newest first
+func (s *AuditService) ListEvents(ctx context.Context, query models.ListAuditEventsQuery) (*models.ListAuditEventsResponse, error) {
+	orgID, err := requestOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	params := database.ListAuditEventsParams{
+		OrgID:     orgID,
+		ActorID:   utils.ConvertStringPtrToText(nonEmpty(query.ActorID)),
+		FromTime:  utils.ConvertTimePtrToTimestamptz(query.From),
+		ToTime:    utils.ConvertTimePtrToTimestamptz(query.To),
+		PageLimit: int32(query.Limit + 1), // one extra row tells us whether there is a next page
+	}
+
+	if query.UserID != nil {
+		params.ResourceID = pgtype.UUID{Bytes: *query.UserID, Valid: true}
+	}
+
+	if query.Cursor != "" {
+		cursor, err := decodeCursor(query.Cursor)
+		if err != nil || cursor.Sort != auditCursorSort {
+			return nil, models.NewBadRequestError(models.CodeInvalidCursor, "Invalid cursor")
+		}
+		occurredAt, err := time.Parse(time.RFC3339Nano, cursor.Value)
+		if err != nil {
+			return nil, models.NewBadRequestError(models.CodeInvalidCursor, "Invalid cursor")
+		}
+		params.CursorOccurredAt = pgtype.Timestamptz{Time: occurredAt, Valid: true}
+		params.CursorEventID = pgtype.UUID{Bytes: cursor.ID, Valid: true}
+	}
+
+	events, err := s.queries.ListAuditEvents(ctx, params)
+	if err != nil {
+		return nil, models.NewInternalServerError("Failed to list audit events", err)
+	}
+
+	hasMore := len(events) > query.Limit
+	if hasMore {
+		events = events[:query.Limit]
+	}
+
+	response := &models.ListAuditEventsResponse{
+		Events:  make([]models.AuditEventResponse, len(events)),
+		HasMore: hasMore,
+	}
+	for i, event := range events {
+		response.Events[i] = convertAuditEvent(event)
+	}
+	if hasMore {
+		last := events[len(events)-1]
+		response.NextCursor = encodeCursor(listCursor{
+			Sort:  auditCursorSort,
+			Value: last.OccurredAt.Time.Format(time.RFC3339Nano),
+			ID:    last.EventID,
+		})
+	}
+
+	return response, nil
+}
+
+// auditCursorSort marks audit cursors so they can't be mixed up with user list cursors
+const auditCursorSort = "audit"
+
+// recordUserEvent writes an audit event for a user change - pass the transaction's queries
+// so the event is only kept if the change is
+func recordUserEvent(ctx context.Context, q database.Querier, action string, userID uuid.UUID, before, after *database.User) error {
+	// The event belongs to the organization of the user - at least one of before and after is set
+	var orgID uuid.UUID
+	var oldUser, newUser *models.UserResponse
+	if before != nil {
+		orgID = before.OrgID
+		oldUser = utils.ConvertToUserResponse(*before)
+	}
+	if after != nil {
+		orgID = after.OrgID
+		newUser = utils.ConvertToUserResponse(*after)
+	}
+
+	// updatedAt and version change on every write, so they say nothing about what changed
+	changes, err := audit.Diff(oldUser, newUser, "updatedAt", "version")
+	if err != nil {
+		return models.NewInternalServerError("Failed to record audit event", err)
+	}
+	changesJSON, err := json.Marshal(changes)
+	if err != nil {
+		return models.NewInternalServerError("Failed to record audit event", err)
+	}
+
+	var actorID *string
+	if caller, ok := auth.PrincipalFromContext(ctx); ok {
+		actorID = &caller.Subject
+	}
+	info := audit.RequestInfoFromContext(ctx)
+
+	err = q.CreateAuditEvent(ctx, database.CreateAuditEventParams{
+		OrgID:        orgID,
+		ActorID:      utils.ConvertStringPtrToText(actorID),
+		Action:       action,
+		ResourceType: audit.ResourceUser,
+		ResourceID:   userID,
+		RequestID:    utils.ConvertStringPtrToText(nonEmpty(info.RequestID)),
+		IpAddress:    utils.ConvertStringPtrToText(nonEmpty(info.IP)),
+		UserAgent:    utils.ConvertStringPtrToText(nonEmpty(info.UserAgent)),
+		Changes:      changesJSON,
+	})
+	if err != nil {
+		return models.NewInternalServerError("Failed to record audit event", err)
+	}
+
+	return nil
+}
+
+func convertAuditEvent(event database.AuditEvent) models.AuditEventResponse {
+	changes := make(map[string]audit.Change)
+	// Changes are written by recordUserEvent, so they always unmarshal - an empty map is the fallback
+	_ = json.Unmarshal(event.Changes, &changes)
+
+	return models.AuditEventResponse{
+		EventID:      event.EventID,
+		OccurredAt:   event.OccurredAt.Time,
+		ActorID:      utils.ConvertTextToStringPtr(event.ActorID),
+		Action:       event.Action,
+		ResourceType: event.ResourceType,
+		ResourceID:   event.ResourceID,
+		RequestID:    utils.ConvertTextToStringPtr(event.RequestID),
+		IPAddress:    utils.ConvertTextToStringPtr(event.IpAddress),
+		UserAgent:    utils.ConvertTextToStringPtr(event.UserAgent),
+		Changes:      changes,
+	}
+}
+
+// nonEmpty turns "" into nil, for optional columns
+func nonEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	database "user-management-api/db/sqlc"
+	"user-management-api/internal/auth"
+	"user-management-api/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type AuthService struct {
+	pool            *pgxpool.Pool
+	queries         TxQuerier
+	txOptions       TxOptions
+	issuer          *auth.Issuer
+	refreshTokenTTL time.Duration
+}
+
+func NewAuthService(pool *pgxpool.Pool, queries TxQuerier, txOptions TxOptions, issuer *auth.Issuer, refreshTokenTTL time.Duration) *AuthService {
+	return &AuthService{
+		pool:            pool,
+		queries:         queries,
+		txOptions:       txOptions,
+		issuer:          issuer,
+		refreshTokenTTL: refreshTokenTTL,
+	}
+}
+
+// errRefreshTokenReused aborts the rotation transaction when another request already used the token
+var errRefreshTokenReused = errors.New("refresh token reused")
+
+// Login checks the email and password and starts a new refresh token family
+// The email is looked up in the organization of the request, the same email may exist in others.
+func (s *AuthService) Login(ctx context.Context, req models.LoginRequest) (*models.TokenResponse, error) {
+	orgID, err := requestOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Same error for unknown email and wrong password, so emails can't be probed
+	invalidCredentials := models.NewUnauthorizedError(models.CodeInvalidCredentials, "Invalid email or password")
+
+	user, err := s.queries.GetUserByEmail(ctx, database.GetUserByEmailParams{OrgID: orgID, Email: req.Email})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			auth.BurnPasswordCheck(req.Password)
+			return nil, invalidCredentials
+		}
+		return nil, models.NewInternalServerError("Failed to get user", err)
+	}
+
+	if !user.PasswordHash.Valid {
+		auth.BurnPasswordCheck(req.Password)
+		return nil, invalidCredentials
+	}
+	if !auth.CheckPassword(user.PasswordHash.String, req.Password) {
+		return nil, invalidCredentials
+	}
+
+	if models.UserStatus(user.Status) != models.UserStatusActive {
+		return nil, models.NewForbiddenError(models.CodeUserInactive, "User account is inactive")
+	}
+
+	return s.issueTokens(ctx, s.queries, user, uuid.New())
+}
+
+// Refresh exchanges a refresh token for a new access token and refresh token
+// Every refresh token works once. Presenting a used one means it was stolen (or the client is buggy),
+// so the whole family is revoked and the user has to log in again.
+// Like Login it runs in the organization of the request, tokens of users of another organization are invalid.
+func (s *AuthService) Refresh(ctx context.Context, req models.RefreshTokenRequest) (*models.TokenResponse, error) {
+	orgID, err := requestOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	invalidToken := models.NewUnauthorizedError(models.CodeInvalidRefreshToken, "Invalid or expired refresh token")
+
+	stored, err := s.queries.GetRefreshTokenByHash(ctx, auth.HashRefreshToken(req.RefreshToken))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, invalidToken
+		}
+		return nil, models.NewInternalServerError("Failed to get refresh token", err)
+	}
+
+	if stored.RevokedAt.Valid {
+		return nil, invalidToken
+	}
+	if stored.UsedAt.Valid {
+		return nil, s.revokeReusedFamily(ctx, stored.FamilyID)
+	}
+	if stored.ExpiresAt.Time.Before(time.Now()) {
+		return nil, invalidToken
+	}
+
+	var tokens *models.TokenResponse
+	err = WithTx(ctx, s.pool, s.queries, s.txOptions, func(q database.Querier) error {
+		// Marking is conditional, so of two concurrent refreshes with the same token only one wins
+		marked, err := q.MarkRefreshTokenUsed(ctx, stored.TokenID)
+		if err != nil {
+			return models.NewInternalServerError("Failed to rotate refresh token", err)
+		}
+		if marked == 0 {
+			return errRefreshTokenReused
+		}
+
+		user, err := q.GetUserByID(ctx, database.GetUserByIDParams{OrgID: orgID, UserID: stored.UserID})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return invalidToken
+			}
+			return models.NewInternalServerError("Failed to get user", err)
+		}
+		if models.UserStatus(user.Status) != models.UserStatusActive {
+			return models.NewForbiddenError(models.CodeUserInactive, "User account is inactive")
+		}
+
+		tokens, err = s.issueTokens(ctx, q, user, stored.FamilyID)
+		return err
+	})
+	if errors.Is(err, errRefreshTokenReused) {
+		// Revoked outside the rolled back transaction, so it sticks
+		return nil, s.revokeReusedFamily(ctx, stored.FamilyID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// Logout revokes the refresh token family, ending that session
+// Unknown tokens are ignored so logout is always safe to retry
+func (s *AuthService) Logout(ctx context.Context, req models.RefreshTokenRequest) error {
+	stored, err := s.queries.GetRefreshTokenByHash(ctx, auth.HashRefreshToken(req.RefreshToken))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		return models.NewInternalServerError("Failed to get refresh token", err)
+	}
+
+	if err := s.queries.RevokeRefreshTokenFamily(ctx, stored.FamilyID); err != nil {
+		return models.NewInternalServerError("Failed to revoke refresh token", err)
+	}
+
+	return nil
+}
+
+// revokeReusedFamily handles refresh token reuse by revoking every token of the family
+func (s *AuthService) revokeReusedFamily(ctx context.Context, familyID uuid.UUID) error {
+	if err := s.queries.RevokeRefreshTokenFamily(ctx, familyID); err != nil {
+		return models.NewInternalServerError("Failed to revoke refresh tokens", err)
+	}
+	return models.NewUnauthorizedError(models.CodeRefreshTokenReused, "Refresh token has already been used")
+}
+
+// issueTokens signs an access token and stores a new refresh token in the given family
+func (s *AuthService) issueTokens(ctx context.Context, q database.Querier, user database.User, familyID uuid.UUID) (*models.TokenResponse, error) {
+	// Roles in the token are informational - permissions are always read from the database
+	roles, err := q.GetUserRoles(ctx, user.UserID)
+	if err != nil {
+		return nil, models.NewInternalServerError("Failed to get user roles", err)
+	}
+
+	accessToken, err := s.issuer.IssueAccessToken(user.UserID, user.OrgID, user.Email, roles)
+	if err != nil {
+		return nil, models.NewInternalServerError("Failed to sign access token", err)
+	}
+
+	refreshToken, refreshHash, err := auth.NewRefreshToken()
+	if err != nil {
+		return nil, models.NewInternalServerError("Failed to generate refresh token", err)
+	}
+
+	_, err = q.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		UserID:    user.UserID,
+		FamilyID:  familyID,
+		TokenHash: refreshHash,
+		ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(s.refreshTokenTTL), Valid: true},
+	})
+	if err != nil {
+		return nil, models.NewInternalServerError("Failed to store refresh token", err)
+	}
+
+	return &models.TokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.issuer.TTL().Seconds()),
+		RefreshToken: refreshToken,
+	}, nil
+}
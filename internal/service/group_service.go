@@ -0,0 +1,464 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	database "user-management-api/db/sqlc"
+	"user-management-api/internal/auth"
+	"user-management-api/internal/logging"
+	"user-management-api/internal/models"
+	"user-management-api/internal/utils"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// memberCursorSort marks member list cursors so they can't be mixed up with other cursors
+const memberCursorSort = "group_members"
+
+// GroupService manages the groups (teams) of an organization and their members
+type GroupService struct {
+	pool      *pgxpool.Pool
+	queries   TxQuerier
+	txOptions TxOptions
+}
+
+func NewGroupService(pool *pgxpool.Pool, queries TxQuerier, txOptions TxOptions) *GroupService {
+	return &GroupService{
+		pool:      pool,
+		queries:   queries,
+		txOptions: txOptions,
+	}
+}
+
+func (s *GroupService) withTx(ctx context.Context, fn func(q database.Querier) error) error {
+	return WithTx(ctx, s.pool, s.queries, s.txOptions, fn)
+}
+
+func (s *GroupService) CreateGroup(ctx context.Context, req models.CreateGroupRequest) (*models.GroupResponse, error) {
+	orgID, err := requestOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	group, err := s.queries.CreateGroup(ctx, database.CreateGroupParams{
+		OrgID:       orgID,
+		Name:        req.Name,
+		Description: utils.ConvertStringPtrToText(req.Description),
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, models.NewConflictError(models.CodeGroupNameTaken, "Group name already exists")
+		}
+		return nil, models.NewInternalServerError("Failed to create group", err)
+	}
+
+	response := convertGroup(group)
+	return &response, nil
+}
+
+// ListGroups returns every group of the organization, ordered by name
+func (s *GroupService) ListGroups(ctx context.Context) (*models.ListGroupsResponse, error) {
+	orgID, err := requestOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := s.queries.ListGroups(ctx, orgID)
+	if err != nil {
+		return nil, models.NewInternalServerError("Failed to list groups", err)
+	}
+
+	response := &models.ListGroupsResponse{
+		Groups: make([]models.GroupResponse, len(groups)),
+	}
+	for i, group := range groups {
+		response.Groups[i] = convertGroup(group)
+	}
+
+	return response, nil
+}
+
+func (s *GroupService) GetGroup(ctx context.Context, groupID string) (*models.GroupResponse, error) {
+	orgID, id, err := parseGroupID(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	group, err := getGroup(ctx, s.queries, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	response := convertGroup(group)
+	return &response, nil
+}
+
+func (s *GroupService) UpdateGroup(ctx context.Context, groupID string, req models.UpdateGroupRequest) (*models.GroupResponse, error) {
+	orgID, id, err := parseGroupID(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	group, err := s.queries.UpdateGroup(ctx, database.UpdateGroupParams{
+		Name:        utils.ConvertStringPtrToText(req.Name),
+		Description: utils.ConvertStringPtrToText(req.Description),
+		OrgID:       orgID,
+		GroupID:     id,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, models.NewNotFoundError(models.CodeGroupNotFound, "Group not found")
+		}
+		if isUniqueViolation(err) {
+			return nil, models.NewConflictError(models.CodeGroupNameTaken, "Group name already exists")
+		}
+		return nil, models.NewInternalServerError("Failed to update group", err)
+	}
+
+	response := convertGroup(group)
+	return &response, nil
+}
+
+// DeleteGroup deletes a group and its memberships - the users themselves are not touched
+func (s *GroupService) DeleteGroup(ctx context.Context, groupID string) error {
+	orgID, id, err := parseGroupID(ctx, groupID)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := s.queries.DeleteGroup(ctx, database.DeleteGroupParams{OrgID: orgID, GroupID: id})
+	if err != nil {
+		return models.NewInternalServerError("Failed to delete group", err)
+	}
+	if deleted == 0 {
+		return models.NewNotFoundError(models.CodeGroupNotFound, "Group not found")
+	}
+
+	return nil
+}
+
+// ListMembers returns a page of the members of a group in the order they joined
+func (s *GroupService) ListMembers(ctx context.Context, groupID string, query models.ListGroupMembersQuery) (*models.ListGroupMembersResponse, error) {
+	orgID, id, err := parseGroupID(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	// An empty group and an unknown one look the same to the query, so check first
+	if _, err := getGroup(ctx, s.queries, orgID, id); err != nil {
+		return nil, err
+	}
+
+	params := database.ListGroupMembersParams{
+		GroupID:   id,
+		PageLimit: int32(query.Limit + 1), // one extra row tells us whether there is a next page
+	}
+
+	if query.Cursor != "" {
+		cursor, err := decodeCursor(query.Cursor)
+		if err != nil || cursor.Sort != memberCursorSort {
+			return nil, models.NewBadRequestError(models.CodeInvalidCursor, "Invalid cursor")
+		}
+		joinedAt, err := time.Parse(time.RFC3339Nano, cursor.Value)
+		if err != nil {
+			return nil, models.NewBadRequestError(models.CodeInvalidCursor, "Invalid cursor")
+		}
+		params.CursorJoinedAt = pgtype.Timestamptz{Time: joinedAt, Valid: true}
+		params.CursorUserID = pgtype.UUID{Bytes: cursor.ID, Valid: true}
+	}
+
+	members, err := s.queries.ListGroupMembers(ctx, params)
+	if err != nil {
+		return nil, models.NewInternalServerError("Failed to list group members", err)
+	}
+
+	hasMore := len(members) > query.Limit
+	if hasMore {
+		members = members[:query.Limit]
+	}
+
+	response := &models.ListGroupMembersResponse{
+		Members: make([]models.GroupMemberResponse, len(members)),
+		HasMore: hasMore,
+	}
+	for i, member := range members {
+		response.Members[i] = models.GroupMemberResponse{
+			UserID:    member.UserID,
+			FirstName: member.FirstName,
+			LastName:  member.LastName,
+			Email:     member.Email,
+			Role:      member.Role,
+			JoinedAt:  member.JoinedAt.Time,
+		}
+	}
+	if hasMore {
+		last := members[len(members)-1]
+		response.NextCursor = encodeCursor(listCursor{
+			Sort:  memberCursorSort,
+			Value: last.JoinedAt.Time.Format(time.RFC3339Nano),
+			ID:    last.UserID,
+		})
+	}
+
+	return response, nil
+}
+
+// AddMembers adds users to a group in one statement, or changes the role of users that are already members
+// Users that don't exist, are deleted or belong to another organization are reported in NotFound. When a user
+// is listed twice the last role wins.
+func (s *GroupService) AddMembers(ctx context.Context, groupID string, req models.AddGroupMembersRequest) (*models.GroupMembersChangeResponse, error) {
+	orgID, id, err := parseGroupID(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	// ON CONFLICT can't update the same membership twice in one statement, so merge duplicates first
+	index := make(map[uuid.UUID]int, len(req.Members))
+	var userIDs []uuid.UUID
+	var roles []string
+	for _, member := range req.Members {
+		role := member.Role
+		if role == "" {
+			role = models.GroupRoleMember
+		}
+		if i, ok := index[member.UserID]; ok {
+			roles[i] = role
+			continue
+		}
+		index[member.UserID] = len(userIDs)
+		userIDs = append(userIDs, member.UserID)
+		roles = append(roles, role)
+	}
+
+	var added []database.GroupMembership
+	err = s.withTx(ctx, func(q database.Querier) error {
+		if err := checkGroupManager(ctx, q, orgID, id); err != nil {
+			return err
+		}
+
+		var err error
+		added, err = q.AddGroupMembers(ctx, database.AddGroupMembersParams{
+			GroupID: id,
+			UserIds: userIDs,
+			Roles:   roles,
+			OrgID:   orgID,
+		})
+		if err != nil {
+			return models.NewInternalServerError("Failed to add group members", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.GroupMembersChangeResponse{
+		GroupID:  id,
+		Added:    make([]models.GroupMemberResponse, len(added)),
+		NotFound: []uuid.UUID{},
+	}
+	found := make(map[uuid.UUID]bool, len(added))
+	for i, membership := range added {
+		found[membership.UserID] = true
+		response.Added[i] = models.GroupMemberResponse{
+			UserID:   membership.UserID,
+			Role:     membership.Role,
+			JoinedAt: membership.JoinedAt.Time,
+		}
+	}
+	for _, userID := range userIDs {
+		if !found[userID] {
+			response.NotFound = append(response.NotFound, userID)
+		}
+	}
+	logging.FromContext(ctx).Info("group members added", "group_id", id, "added", len(added), "not_found", len(response.NotFound))
+
+	return response, nil
+}
+
+// RemoveMembers removes users from a group - users that aren't members are reported in NotFound
+func (s *GroupService) RemoveMembers(ctx context.Context, groupID string, req models.RemoveGroupMembersRequest) (*models.GroupMembersChangeResponse, error) {
+	orgID, id, err := parseGroupID(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	removed, err := s.removeMembers(ctx, orgID, id, req.UserIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.GroupMembersChangeResponse{
+		GroupID:  id,
+		Removed:  removed,
+		NotFound: []uuid.UUID{},
+	}
+	found := make(map[uuid.UUID]bool, len(removed))
+	for _, userID := range removed {
+		found[userID] = true
+	}
+	for _, userID := range req.UserIDs {
+		if !found[userID] {
+			found[userID] = true // report duplicates once
+			response.NotFound = append(response.NotFound, userID)
+		}
+	}
+	logging.FromContext(ctx).Info("group members removed", "group_id", id, "removed", len(removed), "not_found", len(response.NotFound))
+
+	return response, nil
+}
+
+// RemoveMember removes one user from a group, failing with 404 when they aren't a member
+func (s *GroupService) RemoveMember(ctx context.Context, groupID, userID string) error {
+	orgID, id, err := parseGroupID(ctx, groupID)
+	if err != nil {
+		return err
+	}
+	member, err := uuid.Parse(userID)
+	if err != nil {
+		return models.NewBadRequestError(models.CodeInvalidUserID, "Invalid user ID format")
+	}
+
+	removed, err := s.removeMembers(ctx, orgID, id, []uuid.UUID{member})
+	if err != nil {
+		return err
+	}
+	if len(removed) == 0 {
+		return models.NewNotFoundError(models.CodeGroupMemberNotFound, "User is not a member of this group")
+	}
+	logging.FromContext(ctx).Info("group member removed", "group_id", id, "target_user_id", member)
+
+	return nil
+}
+
+func (s *GroupService) removeMembers(ctx context.Context, orgID, groupID uuid.UUID, userIDs []uuid.UUID) ([]uuid.UUID, error) {
+	var removed []uuid.UUID
+	err := s.withTx(ctx, func(q database.Querier) error {
+		if err := checkGroupManager(ctx, q, orgID, groupID); err != nil {
+			return err
+		}
+
+		var err error
+		removed, err = q.RemoveGroupMembers(ctx, database.RemoveGroupMembersParams{
+			GroupID: groupID,
+			UserIds: userIDs,
+		})
+		if err != nil {
+			return models.NewInternalServerError("Failed to remove group members", err)
+		}
+		return nil
+	})
+	return removed, err
+}
+
+// ListUserGroups returns the groups a user is a member of, with their role in each
+func (s *GroupService) ListUserGroups(ctx context.Context, userID string) (*models.UserGroupsResponse, error) {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, models.NewBadRequestError(models.CodeInvalidUserID, "Invalid user ID format")
+	}
+	orgID, err := requestOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// A user without groups and an unknown user look the same to the query, so check first
+	exists, err := s.queries.UserExists(ctx, database.UserExistsParams{OrgID: orgID, UserID: id})
+	if err != nil {
+		return nil, models.NewInternalServerError("Failed to check user", err)
+	}
+	if !exists {
+		return nil, models.NewNotFoundError(models.CodeUserNotFound, "User not found")
+	}
+
+	groups, err := s.queries.ListUserGroups(ctx, database.ListUserGroupsParams{OrgID: orgID, UserID: id})
+	if err != nil {
+		return nil, models.NewInternalServerError("Failed to list user groups", err)
+	}
+
+	response := &models.UserGroupsResponse{
+		UserID: id,
+		Groups: make([]models.UserGroupResponse, len(groups)),
+	}
+	for i, group := range groups {
+		response.Groups[i] = models.UserGroupResponse{
+			GroupID:     group.GroupID,
+			Name:        group.Name,
+			Description: group.Description.String,
+			Role:        group.Role,
+			JoinedAt:    group.JoinedAt.Time,
+		}
+	}
+
+	return response, nil
+}
+
+// checkGroupManager checks the group exists in the organization and that the caller may change its members -
+// callers with groups:manage may change any group, owners only their own
+func checkGroupManager(ctx context.Context, q database.Querier, orgID, groupID uuid.UUID) error {
+	if _, err := getGroup(ctx, q, orgID, groupID); err != nil {
+		return err
+	}
+
+	caller, ok := auth.PrincipalFromContext(ctx)
+	if !ok || caller.HasPermission(auth.PermGroupsManage) {
+		return nil
+	}
+
+	denied := models.NewForbiddenError(models.CodePermissionDenied, "Only group owners can manage the members of this group")
+	subject, err := uuid.Parse(caller.Subject)
+	if err != nil {
+		return denied
+	}
+	role, err := q.GetGroupMemberRole(ctx, database.GetGroupMemberRoleParams{GroupID: groupID, UserID: subject})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return denied
+		}
+		return models.NewInternalServerError("Failed to check group role", err)
+	}
+	if role != models.GroupRoleOwner {
+		return denied
+	}
+	return nil
+}
+
+// getGroup fetches a group of the organization, failing with 404 for groups of other organizations
+func getGroup(ctx context.Context, q database.Querier, orgID, groupID uuid.UUID) (database.Group, error) {
+	group, err := q.GetGroup(ctx, database.GetGroupParams{OrgID: orgID, GroupID: groupID})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return group, models.NewNotFoundError(models.CodeGroupNotFound, "Group not found")
+		}
+		return group, models.NewInternalServerError("Failed to get group", err)
+	}
+	return group, nil
+}
+
+// parseGroupID parses a group ID and returns it with the organization of the request
+func parseGroupID(ctx context.Context, groupID string) (orgID, id uuid.UUID, err error) {
+	id, err = uuid.Parse(groupID)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, models.NewBadRequestError(models.CodeInvalidGroupID, "Invalid group ID format")
+	}
+	orgID, err = requestOrgID(ctx)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+	return orgID, id, nil
+}
+
+func convertGroup(group database.Group) models.GroupResponse {
+	return models.GroupResponse{
+		ID:          group.GroupID,
+		Name:        group.Name,
+		Description: group.Description.String,
+		CreatedAt:   group.CreatedAt.Time,
+		UpdatedAt:   group.UpdatedAt.Time,
+	}
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	database "user-management-api/db/sqlc"
+	"user-management-api/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// IdempotencyService stores Idempotency-Key responses - it implements middleware.IdempotencyStore
+type IdempotencyService struct {
+	queries    database.Querier
+	ttl        time.Duration // how long a key is remembered
+	staleAfter time.Duration // a key still processing after this is taken over by the next request
+}
+
+func NewIdempotencyService(queries database.Querier, ttl, staleAfter time.Duration) *IdempotencyService {
+	return &IdempotencyService{
+		queries:    queries,
+		ttl:        ttl,
+		staleAfter: staleAfter,
+	}
+}
+
+// Claim starts processing a key, claimed is true when the caller should run the request
+// Otherwise record is what is stored for the key (nil if it vanished in between - just claim again).
+func (s *IdempotencyService) Claim(ctx context.Context, caller, key, requestHash string) (*models.IdempotencyRecord, bool, error) {
+	now := time.Now()
+
+	_, err := s.queries.ClaimIdempotencyKey(ctx, database.ClaimIdempotencyKeyParams{
+		Caller:         caller,
+		IdempotencyKey: key,
+		RequestHash:    requestHash,
+		ExpiresAt:      pgtype.Timestamptz{Time: now.Add(s.ttl), Valid: true},
+		StaleBefore:    pgtype.Timestamptz{Time: now.Add(-s.staleAfter), Valid: true},
+	})
+	if err == nil {
+		return nil, true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, err
+	}
+
+	// Someone else holds the key
+	existing, err := s.queries.GetIdempotencyKey(ctx, database.GetIdempotencyKeyParams{
+		Caller:         caller,
+		IdempotencyKey: key,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	record := &models.IdempotencyRecord{
+		RequestHash: existing.RequestHash,
+		Completed:   existing.Status == "completed",
+	}
+	if record.Completed {
+		record.Response = models.IdempotentResponse{
+			StatusCode: int(existing.ResponseStatus.Int32),
+			Body:       existing.ResponseBody,
+		}
+		if err := json.Unmarshal(existing.ResponseHeaders, &record.Response.Header); err != nil {
+			return nil, false, err
+		}
+	}
+
+	return record, false, nil
+}
+
+// Complete stores the response of a claimed key
+func (s *IdempotencyService) Complete(ctx context.Context, caller, key string, response models.IdempotentResponse) error {
+	header, err := json.Marshal(response.Header)
+	if err != nil {
+		return err
+	}
+
+	return s.queries.CompleteIdempotencyKey(ctx, database.CompleteIdempotencyKeyParams{
+		Caller:          caller,
+		IdempotencyKey:  key,
+		ResponseStatus:  pgtype.Int4{Int32: int32(response.StatusCode), Valid: true},
+		ResponseHeaders: header,
+		ResponseBody:    response.Body,
+	})
+}
+
+// Release forgets a claimed key, so the next request with it runs again
+func (s *IdempotencyService) Release(ctx context.Context, caller, key string) error {
+	return s.queries.DeleteIdempotencyKey(ctx, database.DeleteIdempotencyKeyParams{
+		Caller:         caller,
+		IdempotencyKey: key,
+	})
+}
+
+// RunCleanup deletes expired keys every interval until ctx is cancelled
+// Run it in its own goroutine
+func (s *IdempotencyService) RunCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := s.queries.DeleteExpiredIdempotencyKeys(ctx)
+			if err != nil {
+				slog.Error("cleanup of idempotency keys failed", "error", err)
+				continue
+			}
+			if deleted > 0 {
+				slog.Info("deleted expired idempotency keys", "count", deleted)
+			}
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"user-management-api/internal/models"
+)
+
+// importRow is one user of an import file and what became of it
+type importRow struct {
+	req          models.CreateUserRequest
+	passwordHash string                 // set just before the row is written
+	result       models.ImportRowResult // result.Status stays "" until the row is decided
+}
+
+// pending reports whether nothing has been decided about the row yet
+func (r *importRow) pending() bool {
+	return r.result.Status == ""
+}
+
+// fail decides the row as failed
+func (r *importRow) fail(message string, invalidParams ...models.InvalidParam) {
+	r.result.Status = models.ImportRowFailed
+	r.result.Message = message
+	r.result.InvalidParams = invalidParams
+}
+
+// CSV columns are matched to these fields by normalized header name (see normalizeHeader)
+var importFields = map[string]string{
+	"firstname": "firstName",
+	"lastname":  "lastName",
+	"email":     "email",
+	"phone":     "phone",
+	"age":       "age",
+	"status":    "status",
+	"password":  "password",
+}
+
+// A CSV file must have a column for each of these
+var requiredImportFields = []string{"firstName", "lastName", "email"}
+
+// ignoreColumn maps a CSV column to nothing, so it is skipped instead of rejected as unknown
+const ignoreColumn = "-"
+
+// normalizeHeader makes "First Name", "first_name" and "firstName" the same
+func normalizeHeader(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	return strings.NewReplacer(" ", "", "_", "", "-", "").Replace(name)
+}
+
+// parseImportMapping parses "CSV header:field,..." into normalized header -> field
+// The field is one of importFields, or "-" to ignore the column.
+func parseImportMapping(raw string) (map[string]string, error) {
+	mapping := make(map[string]string)
+	if strings.TrimSpace(raw) == "" {
+		return mapping, nil
+	}
+
+	for _, pair := range strings.Split(raw, ",") {
+		header, field, found := strings.Cut(pair, ":")
+		if !found || strings.TrimSpace(header) == "" {
+			return nil, fmt.Errorf("%q is not a header:field pair", pair)
+		}
+
+		field = strings.TrimSpace(field)
+		if field != ignoreColumn {
+			known, ok := importFields[normalizeHeader(field)]
+			if !ok {
+				return nil, fmt.Errorf("%q is not a user field", field)
+			}
+			field = known
+		}
+		mapping[normalizeHeader(header)] = field
+	}
+	return mapping, nil
+}
+
+// parseImportFile reads the rows of an import file
+// Problems with the file as a whole (no header, broken quoting, unknown columns) are returned as errors,
+// problems with a single row fail just that row.
+func parseImportFile(format string, data []byte, mapping map[string]string, maxRows int) ([]*importRow, error) {
+	var rows []*importRow
+	var err error
+	switch format {
+	case models.ImportFormatCSV:
+		rows, err = parseImportCSV(data, mapping)
+	case models.ImportFormatNDJSON:
+		rows, err = parseImportNDJSON(data)
+	default:
+		err = fmt.Errorf("unsupported format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) == 0 {
+		return nil, errors.New("the file has no rows")
+	}
+	if len(rows) > maxRows {
+		return nil, fmt.Errorf("the file has %d rows, at most %d can be imported at once", len(rows), maxRows)
+	}
+	return rows, nil
+}
+
+func parseImportCSV(data []byte, mapping map[string]string) ([]*importRow, error) {
+	// Excel starts CSV files with a byte order mark
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\ufeff"))))
+	reader.FieldsPerRecord = -1 // checked per row, so one short row doesn't end the import
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("the file is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Find the field of every column
+	columns := make([]string, len(header))
+	seen := make(map[string]bool)
+	var unknown []string
+	for i, name := range header {
+		key := normalizeHeader(name)
+		field, ok := mapping[key]
+		if !ok {
+			field, ok = importFields[key]
+		}
+		switch {
+		case !ok:
+			unknown = append(unknown, name)
+		case field == ignoreColumn:
+		case seen[field]:
+			return nil, fmt.Errorf("more than one column maps to %s", field)
+		default:
+			columns[i] = field
+			seen[field] = true
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("unknown columns %s - map them to a field or to %q with the mapping parameter",
+			strings.Join(unknown, ", "), ignoreColumn)
+	}
+	for _, field := range requiredImportFields {
+		if !seen[field] {
+			return nil, fmt.Errorf("no column for %s", field)
+		}
+	}
+
+	var rows []*importRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		row := &importRow{result: models.ImportRowResult{Line: line}}
+		rows = append(rows, row)
+
+		if len(record) != len(header) {
+			row.fail(fmt.Sprintf("Expected %d columns, got %d", len(header), len(record)))
+			continue
+		}
+
+		var invalid []models.InvalidParam
+		for i, value := range record {
+			value = strings.TrimSpace(value)
+			switch columns[i] {
+			case "firstName":
+				row.req.FirstName = value
+			case "lastName":
+				row.req.LastName = value
+			case "email":
+				row.req.Email = value
+				row.result.Email = value
+			case "phone":
+				if value != "" {
+					row.req.Phone = &value
+				}
+			case "age":
+				if value != "" {
+					age, err := strconv.Atoi(value)
+					if err != nil {
+						invalid = append(invalid, models.InvalidParam{Name: "age", Reason: "age must be a whole number"})
+						continue
+					}
+					row.req.Age = &age
+				}
+			case "status":
+				row.req.Status = models.UserStatus(value)
+			case "password":
+				row.req.Password = value
+			}
+		}
+		if len(invalid) > 0 {
+			row.fail("One or more fields failed validation", invalid...)
+		}
+	}
+
+	return rows, nil
+}
+
+func parseImportNDJSON(data []byte) ([]*importRow, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var rows []*importRow
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		row := &importRow{result: models.ImportRowResult{Line: line}}
+		rows = append(rows, row)
+
+		decoder := json.NewDecoder(bytes.NewReader(text))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&row.req); err != nil {
+			row.fail("Invalid JSON: " + err.Error())
+			continue
+		}
+		row.result.Email = row.req.Email
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("line %d: %w", line+1, err)
+	}
+
+	return rows, nil
+}
+
+// invalidParams turns validator errors into a sorted list
+func invalidParams(errors map[string]string) []models.InvalidParam {
+	params := make([]models.InvalidParam, 0, len(errors))
+	for name, reason := range errors {
+		params = append(params, models.InvalidParam{Name: name, Reason: reason})
+	}
+	sort.Slice(params, func(i, j int) bool {
+		return params[i].Name < params[j].Name
+	})
+	return params
+}
@@ -0,0 +1,769 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	database "user-management-api/db/sqlc"
+	"user-management-api/internal/audit"
+	"user-management-api/internal/auth"
+	"user-management-api/internal/events"
+	"user-management-api/internal/logging"
+	"user-management-api/internal/models"
+	"user-management-api/internal/tenant"
+	"user-management-api/internal/utils"
+	"user-management-api/internal/validator"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ImportOptions controls bulk imports
+type ImportOptions struct {
+	BatchSize     int           // rows written per transaction
+	SyncMaxRows   int           // larger imports are queued for the worker
+	MaxRows       int           // larger files are rejected
+	LeaseDuration time.Duration // a worker owns a running job this long after its last batch
+	Retention     time.Duration // finished jobs are deleted after this, 0 keeps them
+}
+
+// ImportService imports users in bulk from CSV and NDJSON files
+type ImportService struct {
+	pool         *pgxpool.Pool
+	queries      TxQuerier
+	txOptions    TxOptions
+	validator    *validator.Validator
+	verification *VerificationService
+	options      ImportOptions
+}
+
+func NewImportService(pool *pgxpool.Pool, queries TxQuerier, txOptions TxOptions, validator *validator.Validator, verification *VerificationService, options ImportOptions) *ImportService {
+	return &ImportService{
+		pool:         pool,
+		queries:      queries,
+		txOptions:    txOptions,
+		validator:    validator,
+		verification: verification,
+		options:      options,
+	}
+}
+
+// importSettings are the options of one import
+type importSettings struct {
+	dryRun     bool
+	onConflict string
+}
+
+// importCursorSort marks row report cursors so they can't be mixed up with other cursors
+const importCursorSort = "import"
+
+// ImportUsers imports the users in data - exactly one of the report and the job is returned
+// Imports of up to SyncMaxRows rows run right away and return their report. Larger ones, and any with
+// query.Async, are queued for the worker and return the job to poll.
+func (s *ImportService) ImportUsers(ctx context.Context, query models.ImportUsersQuery, data []byte) (*models.ImportReport, *models.ImportJobResponse, error) {
+	orgID, err := requestOrgID(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if query.OnConflict == "" {
+		query.OnConflict = models.OnConflictFail
+	}
+
+	mapping, err := parseImportMapping(query.Mapping)
+	if err != nil {
+		return nil, nil, models.NewBadRequestError(models.CodeInvalidImportFile, "Invalid mapping: "+err.Error())
+	}
+
+	// A broken file is rejected right away, even when it is queued
+	rows, err := parseImportFile(query.Format, data, mapping, s.options.MaxRows)
+	if err != nil {
+		return nil, nil, models.NewBadRequestError(models.CodeInvalidImportFile, "Invalid import file: "+err.Error())
+	}
+
+	if query.Async || len(rows) > s.options.SyncMaxRows {
+		job, err := s.queueJob(ctx, orgID, query, mapping, data, len(rows))
+		return nil, job, err
+	}
+
+	settings := importSettings{dryRun: query.DryRun, onConflict: query.OnConflict}
+	s.validateRows(rows)
+	aborted, err := s.run(ctx, rows, settings, nil)
+
+	report := &models.ImportReport{
+		Status:     models.ImportJobCompleted,
+		DryRun:     settings.dryRun,
+		OnConflict: settings.onConflict,
+		Total:      len(rows),
+	}
+	if err != nil {
+		logging.FromContext(ctx).Error("user import failed", "error", err)
+		report.Status = models.ImportJobFailed
+		report.Error = importStoppedMessage(rows)
+	}
+	report.Rows = currentResults(rows)
+	report.Created, report.Updated, report.Skipped, report.Failed = countImportRows(report.Rows)
+	if aborted {
+		report.Status = models.ImportJobFailed
+		report.Error = fmt.Sprintf("%d rows failed and on_conflict is fail, nothing was imported", report.Failed)
+	}
+	if !settings.dryRun {
+		logging.FromContext(ctx).Info("users imported", "created", report.Created, "updated", report.Updated, "failed", report.Failed)
+	}
+
+	return report, nil, nil
+}
+
+// queueJob stores an import for the worker, which runs it in the organization of the caller
+func (s *ImportService) queueJob(ctx context.Context, orgID uuid.UUID, query models.ImportUsersQuery, mapping map[string]string, data []byte, total int) (*models.ImportJobResponse, error) {
+	mappingJSON, err := json.Marshal(mapping)
+	if err != nil {
+		return nil, models.NewInternalServerError("Failed to queue import", err)
+	}
+
+	// The worker writes the audit events in the name of the caller
+	var createdBy *string
+	if caller, ok := auth.PrincipalFromContext(ctx); ok {
+		createdBy = &caller.Subject
+	}
+
+	job, err := s.queries.CreateImportJob(ctx, database.CreateImportJobParams{
+		OrgID:         orgID,
+		Format:        query.Format,
+		DryRun:        query.DryRun,
+		OnConflict:    query.OnConflict,
+		ColumnMapping: mappingJSON,
+		Payload:       data,
+		TotalRows:     int32(total),
+		CreatedBy:     utils.ConvertStringPtrToText(createdBy),
+		RequestID:     utils.ConvertStringPtrToText(nonEmpty(audit.RequestInfoFromContext(ctx).RequestID)),
+	})
+	if err != nil {
+		return nil, models.NewInternalServerError("Failed to queue import", err)
+	}
+	logging.FromContext(ctx).Info("user import queued", "import_job_id", job.JobID, "rows", total)
+
+	response := convertImportJob(job)
+	return &response, nil
+}
+
+// validateRows fails the rows that don't pass validation, and rows repeating the email of an earlier row
+func (s *ImportService) validateRows(rows []*importRow) {
+	firstLine := make(map[string]int) // email -> line of its first row
+	for _, row := range rows {
+		if !row.pending() {
+			continue
+		}
+		if errs := s.validator.ValidateStruct(row.req); errs != nil {
+			row.fail("One or more fields failed validation", invalidParams(errs)...)
+			continue
+		}
+		if line, ok := firstLine[row.req.Email]; ok {
+			row.fail(fmt.Sprintf("Email already used on line %d", line))
+			continue
+		}
+		firstLine[row.req.Email] = row.result.Line
+	}
+}
+
+// run decides and writes the rows in batches, one transaction each
+// With a job, the row reports and the progress are stored in the batch's transaction, so a job that is
+// picked up again resumes after the last committed batch. aborted is true when on_conflict=fail stopped the import.
+// On an error, the rows from the failed batch on are left undecided.
+func (s *ImportService) run(ctx context.Context, rows []*importRow, settings importSettings, job *database.ImportJob) (aborted bool, err error) {
+	start := 0
+	if job != nil {
+		start = int(job.ProcessedRows)
+	}
+
+	// on_conflict=fail imports all or nothing, so every row is checked before anything is written
+	if settings.onConflict == models.OnConflictFail && start == 0 {
+		if err := s.failTakenEmails(ctx, rows); err != nil {
+			return false, err
+		}
+		for _, row := range rows {
+			if row.result.Status == models.ImportRowFailed {
+				aborted = true
+			}
+		}
+		if aborted {
+			for _, row := range rows {
+				if row.pending() {
+					row.result.Status = models.ImportRowNotImported
+				}
+			}
+		}
+	}
+
+	if settings.dryRun && !aborted {
+		if err := s.planDryRun(ctx, rows, settings.onConflict); err != nil {
+			return false, err
+		}
+	}
+
+	// Without writes every row is decided already - a job stores all of them in one go,
+	// so a job picked up again can't find half of a dry run or an aborted import
+	write := !settings.dryRun && !aborted
+	batchSize := s.options.BatchSize
+	if !write {
+		if job == nil {
+			return aborted, nil
+		}
+		batchSize = len(rows)
+	}
+
+	for from := start; from < len(rows); from += batchSize {
+		to := min(from+batchSize, len(rows))
+		batch := rows[from:to]
+
+		// Hashing is slow, so it happens before the transaction takes its locks
+		if write {
+			if err := hashImportPasswords(batch); err != nil {
+				return false, err
+			}
+		}
+
+		var results []models.ImportRowResult
+		err := s.withTx(ctx, func(q database.Querier) error {
+			var err error
+			if write {
+				results, err = s.importBatch(ctx, q, batch, settings.onConflict)
+			} else {
+				results = currentResults(batch)
+			}
+			if err != nil {
+				return err
+			}
+			if job != nil {
+				return s.recordProgress(ctx, q, job.JobID, to, results)
+			}
+			return nil
+		})
+		if err != nil {
+			return false, fmt.Errorf("import batch from line %d: %w", batch[0].result.Line, err)
+		}
+
+		for i, row := range batch {
+			row.result = results[i]
+		}
+	}
+
+	return aborted, nil
+}
+
+func (s *ImportService) withTx(ctx context.Context, fn func(q database.Querier) error) error {
+	return WithTx(ctx, s.pool, s.queries, s.txOptions, fn)
+}
+
+// failTakenEmails fails the undecided rows whose email is already registered
+func (s *ImportService) failTakenEmails(ctx context.Context, rows []*importRow) error {
+	taken, err := s.takenEmails(ctx, rows)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if row.pending() && taken[row.req.Email] {
+			row.fail("Email already exists")
+		}
+	}
+	return nil
+}
+
+// planDryRun decides the undecided rows the way importBatch would, without writing anything
+// Updates can't be told from updates that change nothing here, so both are reported as updated.
+func (s *ImportService) planDryRun(ctx context.Context, rows []*importRow, onConflict string) error {
+	taken, err := s.takenEmails(ctx, rows)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		switch {
+		case !row.pending():
+		case !taken[row.req.Email]:
+			row.result.Status = models.ImportRowCreated
+		case onConflict == models.OnConflictSkip:
+			row.result.Status = models.ImportRowSkipped
+			row.result.Message = "Email already exists"
+		case onConflict == models.OnConflictUpdate:
+			row.result.Status = models.ImportRowUpdated
+		default:
+			row.fail("Email already exists")
+		}
+	}
+	return nil
+}
+
+// takenEmails returns which emails of the undecided rows are registered
+func (s *ImportService) takenEmails(ctx context.Context, rows []*importRow) (map[string]bool, error) {
+	var emails []string
+	for _, row := range rows {
+		if row.pending() {
+			emails = append(emails, row.req.Email)
+		}
+	}
+
+	taken := make(map[string]bool)
+	if len(emails) == 0 {
+		return taken, nil
+	}
+	orgID, err := requestOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	registered, err := s.queries.ListActiveUserEmails(ctx, database.ListActiveUserEmailsParams{OrgID: orgID, Emails: emails})
+	if err != nil {
+		return nil, models.NewInternalServerError("Failed to check emails", err)
+	}
+	for _, email := range registered {
+		taken[email] = true
+	}
+	return taken, nil
+}
+
+// hashImportPasswords hashes the passwords of the undecided rows of a batch
+func hashImportPasswords(batch []*importRow) error {
+	for _, row := range batch {
+		if row.pending() && row.req.Password != "" && row.passwordHash == "" {
+			hash, err := auth.HashPassword(row.req.Password)
+			if err != nil {
+				return models.NewInternalServerError("Failed to hash password", err)
+			}
+			row.passwordHash = hash
+		}
+	}
+	return nil
+}
+
+// importBatch writes the undecided rows of a batch and returns the results of all its rows
+// It doesn't touch the rows, so it can run again when the transaction is retried.
+// Passwords only apply to new users, updates leave the password alone. New users get their verification
+// mail through the mail outbox, like users created one by one.
+func (s *ImportService) importBatch(ctx context.Context, q database.Querier, batch []*importRow, onConflict string) ([]models.ImportRowResult, error) {
+	results := currentResults(batch)
+
+	var emails []string
+	for _, row := range batch {
+		if row.pending() {
+			emails = append(emails, row.req.Email)
+		}
+	}
+	if len(emails) == 0 {
+		return results, nil
+	}
+	orgID, err := requestOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Locked, so the audit events see exactly what an update changed
+	locked, err := q.LockActiveUsersByEmail(ctx, database.LockActiveUsersByEmailParams{OrgID: orgID, Emails: emails})
+	if err != nil {
+		return nil, models.NewInternalServerError("Failed to get users", err)
+	}
+	existing := make(map[string]database.User, len(locked))
+	for _, user := range locked {
+		existing[user.Email] = user
+	}
+
+	create := database.InsertImportedUsersParams{OrgID: orgID}
+	update := database.UpdateImportedUsersParams{OrgID: orgID}
+	var createRows, updateRows []int
+	for i, row := range batch {
+		if !row.pending() {
+			continue
+		}
+
+		user, taken := existing[row.req.Email]
+		switch {
+		case !taken:
+			createRows = append(createRows, i)
+			create.FirstNames = append(create.FirstNames, row.req.FirstName)
+			create.LastNames = append(create.LastNames, row.req.LastName)
+			create.Emails = append(create.Emails, row.req.Email)
+			create.Phones = append(create.Phones, importPhone(row.req.Phone))
+			create.Ages = append(create.Ages, importAge(row.req.Age))
+			create.Statuses = append(create.Statuses, string(row.req.Status))
+			create.PasswordHashes = append(create.PasswordHashes, row.passwordHash)
+		case onConflict == models.OnConflictSkip:
+			results[i] = skippedResult(results[i], user.UserID, "Email already exists")
+		case onConflict == models.OnConflictUpdate:
+			updateRows = append(updateRows, i)
+			update.Emails = append(update.Emails, row.req.Email)
+			update.FirstNames = append(update.FirstNames, row.req.FirstName)
+			update.LastNames = append(update.LastNames, row.req.LastName)
+			update.Phones = append(update.Phones, importPhone(row.req.Phone))
+			update.Ages = append(update.Ages, importAge(row.req.Age))
+			update.Statuses = append(update.Statuses, string(row.req.Status))
+		default:
+			// Registered after the up-front check of on_conflict=fail
+			results[i].Status = models.ImportRowFailed
+			results[i].Message = "Email already exists"
+		}
+	}
+
+	if len(createRows) > 0 {
+		users, err := q.InsertImportedUsers(ctx, create)
+		if err != nil {
+			return nil, models.NewInternalServerError("Failed to create users", err)
+		}
+		created := make(map[string]database.User, len(users))
+		userIDs := make([]uuid.UUID, 0, len(users))
+		for _, user := range users {
+			created[user.Email] = user
+			userIDs = append(userIDs, user.UserID)
+		}
+
+		// Every user starts as a member - admins can grant more roles later
+		err = q.AssignRoleToUsers(ctx, database.AssignRoleToUsersParams{
+			UserIds:  userIDs,
+			RoleName: auth.DefaultRole,
+		})
+		if err != nil {
+			return nil, models.NewInternalServerError("Failed to assign default role", err)
+		}
+
+		for _, i := range createRows {
+			user, ok := created[batch[i].req.Email]
+			if !ok {
+				// Registered by someone else since the rows were locked
+				if onConflict == models.OnConflictSkip {
+					results[i] = skippedResult(results[i], uuid.Nil, "Email already exists")
+				} else {
+					results[i].Status = models.ImportRowFailed
+					results[i].Message = "Email already exists"
+				}
+				continue
+			}
+
+			if err := s.verification.queueVerification(ctx, q, user); err != nil {
+				return nil, err
+			}
+			if err := recordUserEvent(ctx, q, audit.ActionUserCreated, user.UserID, nil, &user); err != nil {
+				return nil, err
+			}
+			err := enqueueUserEvent(ctx, q, events.TypeUserCreated, user.OrgID, user.UserID, events.UserCreated{User: eventUser(user)})
+			if err != nil {
+				return nil, err
+			}
+			results[i].Status = models.ImportRowCreated
+			results[i].UserID = &user.UserID
+		}
+	}
+
+	if len(updateRows) > 0 {
+		users, err := q.UpdateImportedUsers(ctx, update)
+		if err != nil {
+			return nil, models.NewInternalServerError("Failed to update users", err)
+		}
+		updated := make(map[string]database.User, len(users))
+		for _, user := range users {
+			updated[user.Email] = user
+		}
+
+		for _, i := range updateRows {
+			before := existing[batch[i].req.Email]
+			after, ok := updated[batch[i].req.Email]
+			if !ok {
+				results[i] = skippedResult(results[i], before.UserID, "Already up to date")
+				continue
+			}
+
+			if err := recordUserEvent(ctx, q, audit.ActionUserUpdated, after.UserID, &before, &after); err != nil {
+				return nil, err
+			}
+			if err := enqueueUserUpdatedEvent(ctx, q, before, after); err != nil {
+				return nil, err
+			}
+			results[i].Status = models.ImportRowUpdated
+			results[i].UserID = &after.UserID
+		}
+	}
+
+	return results, nil
+}
+
+// recordProgress stores the results of a batch of a job - rows before processed are done
+func (s *ImportService) recordProgress(ctx context.Context, q database.Querier, jobID uuid.UUID, processed int, results []models.ImportRowResult) error {
+	rowsJSON, err := json.Marshal(results)
+	if err != nil {
+		return models.NewInternalServerError("Failed to record import progress", err)
+	}
+	if err := q.CreateImportJobRows(ctx, database.CreateImportJobRowsParams{JobID: jobID, Rows: rowsJSON}); err != nil {
+		return models.NewInternalServerError("Failed to record import progress", err)
+	}
+
+	created, updated, skipped, failed := countImportRows(results)
+	err = q.RecordImportJobProgress(ctx, database.RecordImportJobProgressParams{
+		ProcessedRows: int32(processed),
+		Created:       int32(created),
+		Updated:       int32(updated),
+		Skipped:       int32(skipped),
+		Failed:        int32(failed),
+		LeaseUntil:    s.leaseUntil(),
+		JobID:         jobID,
+	})
+	if err != nil {
+		return models.NewInternalServerError("Failed to record import progress", err)
+	}
+	return nil
+}
+
+func (s *ImportService) leaseUntil() pgtype.Timestamptz {
+	return pgtype.Timestamptz{Time: time.Now().Add(s.options.LeaseDuration), Valid: true}
+}
+
+// ProcessNextJob runs the oldest queued import to the end - false when there was none
+func (s *ImportService) ProcessNextJob(ctx context.Context) (bool, error) {
+	job, err := s.queries.ClaimImportJob(ctx, s.leaseUntil())
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("claim import job: %w", err)
+	}
+	ctx = logging.With(ctx, "import_job_id", job.JobID)
+	ctx = tenant.WithOrgID(ctx, job.OrgID)
+
+	// The audit events of the job name the caller who uploaded the file
+	if job.CreatedBy.Valid {
+		ctx = auth.WithPrincipal(ctx, &auth.Principal{Subject: job.CreatedBy.String})
+	}
+	ctx = audit.WithRequestInfo(ctx, audit.RequestInfo{RequestID: job.RequestID.String})
+
+	var mapping map[string]string
+	if err := json.Unmarshal(job.ColumnMapping, &mapping); err != nil {
+		return true, s.finishJob(ctx, job.JobID, models.ImportJobFailed, "Invalid column mapping")
+	}
+	rows, err := parseImportFile(job.Format, job.Payload, mapping, int(job.TotalRows))
+	if err != nil {
+		return true, s.finishJob(ctx, job.JobID, models.ImportJobFailed, "Invalid import file: "+err.Error())
+	}
+
+	s.validateRows(rows)
+	aborted, err := s.run(ctx, rows, importSettings{dryRun: job.DryRun, onConflict: job.OnConflict}, &job)
+	switch {
+	case ctx.Err() != nil:
+		// Shutting down - the job resumes after the last committed batch once its lease runs out
+		return true, ctx.Err()
+	case err != nil:
+		logging.FromContext(ctx).Error("import job failed", "error", err)
+		return true, s.finishJob(ctx, job.JobID, models.ImportJobFailed, importStoppedMessage(rows))
+	case aborted:
+		return true, s.finishJob(ctx, job.JobID, models.ImportJobFailed, "Some rows failed and on_conflict is fail, nothing was imported")
+	}
+	return true, s.finishJob(ctx, job.JobID, models.ImportJobCompleted, "")
+}
+
+func (s *ImportService) finishJob(ctx context.Context, jobID uuid.UUID, status, message string) error {
+	err := s.queries.FinishImportJob(ctx, database.FinishImportJobParams{
+		Status: status,
+		Error:  utils.ConvertStringPtrToText(nonEmpty(message)),
+		JobID:  jobID,
+	})
+	if err != nil {
+		return fmt.Errorf("finish import job: %w", err)
+	}
+	logging.FromContext(ctx).Info("import job finished", "status", status)
+	return nil
+}
+
+// RunWorker runs queued imports every interval until ctx is cancelled, and deletes old finished jobs
+// Run it in its own goroutine
+func (s *ImportService) RunWorker(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastCleanup time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// Keep going while there are jobs, so a queue drains without waiting for the ticker
+			for {
+				processed, err := s.ProcessNextJob(ctx)
+				if err != nil && ctx.Err() == nil {
+					slog.Error("import job processing failed", "error", err)
+					break
+				}
+				if !processed || ctx.Err() != nil {
+					break
+				}
+			}
+
+			if s.options.Retention > 0 && time.Since(lastCleanup) > time.Hour {
+				lastCleanup = time.Now()
+				cutoff := pgtype.Timestamptz{Time: time.Now().Add(-s.options.Retention), Valid: true}
+				if _, err := s.queries.DeleteFinishedImportJobs(ctx, cutoff); err != nil {
+					slog.Error("deleting finished import jobs failed", "error", err)
+				}
+			}
+		}
+	}
+}
+
+// GetImportJob returns a queued import with one page of its row report
+func (s *ImportService) GetImportJob(ctx context.Context, jobID string, query models.GetImportJobQuery) (*models.ImportJobResponse, error) {
+	id, err := uuid.Parse(jobID)
+	if err != nil {
+		return nil, models.NewBadRequestError(models.CodeInvalidImportJobID, "Invalid import job ID format")
+	}
+	orgID, err := requestOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	job, err := s.queries.GetImportJob(ctx, database.GetImportJobParams{OrgID: orgID, JobID: id})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, models.NewNotFoundError(models.CodeImportJobNotFound, "Import job not found")
+		}
+		return nil, models.NewInternalServerError("Failed to get import job", err)
+	}
+
+	params := database.ListImportJobRowsParams{
+		JobID:     id,
+		PageLimit: int32(query.Limit + 1), // one extra row tells us whether there is a next page
+	}
+	if query.Cursor != "" {
+		cursor, err := decodeCursor(query.Cursor)
+		if err != nil || cursor.Sort != importCursorSort {
+			return nil, models.NewBadRequestError(models.CodeInvalidCursor, "Invalid cursor")
+		}
+		line, err := strconv.Atoi(cursor.Value)
+		if err != nil {
+			return nil, models.NewBadRequestError(models.CodeInvalidCursor, "Invalid cursor")
+		}
+		params.AfterLine = int32(line)
+	}
+
+	rows, err := s.queries.ListImportJobRows(ctx, params)
+	if err != nil {
+		return nil, models.NewInternalServerError("Failed to list import rows", err)
+	}
+
+	response := convertImportJob(job)
+	response.HasMore = len(rows) > query.Limit
+	if response.HasMore {
+		rows = rows[:query.Limit]
+		response.NextCursor = encodeCursor(listCursor{
+			Sort:  importCursorSort,
+			Value: strconv.Itoa(int(rows[len(rows)-1].Line)),
+		})
+	}
+	for _, row := range rows {
+		response.Rows = append(response.Rows, convertImportJobRow(row))
+	}
+
+	return &response, nil
+}
+
+// currentResults copies the results of the rows
+func currentResults(batch []*importRow) []models.ImportRowResult {
+	results := make([]models.ImportRowResult, len(batch))
+	for i, row := range batch {
+		results[i] = row.result
+	}
+	return results
+}
+
+func skippedResult(result models.ImportRowResult, userID uuid.UUID, message string) models.ImportRowResult {
+	result.Status = models.ImportRowSkipped
+	result.Message = message
+	if userID != uuid.Nil {
+		result.UserID = &userID
+	}
+	return result
+}
+
+// countImportRows counts the results by status
+func countImportRows(results []models.ImportRowResult) (created, updated, skipped, failed int) {
+	for _, result := range results {
+		switch result.Status {
+		case models.ImportRowCreated:
+			created++
+		case models.ImportRowUpdated:
+			updated++
+		case models.ImportRowSkipped:
+			skipped++
+		case models.ImportRowFailed:
+			failed++
+		}
+	}
+	return created, updated, skipped, failed
+}
+
+// importStoppedMessage marks the rows a failed batch left undecided and says where the import stopped
+func importStoppedMessage(rows []*importRow) string {
+	for _, row := range rows {
+		if row.pending() {
+			line := row.result.Line
+			for _, rest := range rows {
+				if rest.pending() {
+					rest.result.Status = models.ImportRowNotImported
+				}
+			}
+			return fmt.Sprintf("The import stopped at line %d because of an internal error, the rows before it were imported", line)
+		}
+	}
+	return "The import stopped because of an internal error"
+}
+
+// The import arrays can't hold NULLs - an empty phone and a zero age mean "not given"
+func importPhone(phone *string) string {
+	if phone == nil {
+		return ""
+	}
+	return *phone
+}
+
+func importAge(age *int) int32 {
+	if age == nil {
+		return 0
+	}
+	return int32(*age)
+}
+
+func convertImportJob(job database.ImportJob) models.ImportJobResponse {
+	return models.ImportJobResponse{
+		JobID:      job.JobID,
+		Status:     job.Status,
+		Format:     job.Format,
+		DryRun:     job.DryRun,
+		OnConflict: job.OnConflict,
+		Total:      int(job.TotalRows),
+		Processed:  int(job.ProcessedRows),
+		Created:    int(job.CreatedCount),
+		Updated:    int(job.UpdatedCount),
+		Skipped:    int(job.SkippedCount),
+		Failed:     int(job.FailedCount),
+		Error:      job.Error.String,
+		CreatedAt:  job.CreatedAt.Time,
+		StartedAt:  utils.ConvertTimestamptzToTimePtr(job.StartedAt),
+		FinishedAt: utils.ConvertTimestamptzToTimePtr(job.FinishedAt),
+		Rows:       []models.ImportRowResult{},
+	}
+}
+
+func convertImportJobRow(row database.ImportJobRow) models.ImportRowResult {
+	result := models.ImportRowResult{
+		Line:    int(row.Line),
+		Email:   row.Email.String,
+		Status:  row.Status,
+		Message: row.Message.String,
+	}
+	if row.UserID.Valid {
+		id := uuid.UUID(row.UserID.Bytes)
+		result.UserID = &id
+	}
+	if len(row.InvalidParams) > 0 {
+		_ = json.Unmarshal(row.InvalidParams, &result.InvalidParams) // written by recordProgress, so it parses
+	}
+	return result
+}
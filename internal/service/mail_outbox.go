@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	database "user-management-api/db/sqlc"
+	"user-management-api/internal/mail"
+	"user-management-api/internal/webhooks"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// MailRelayOptions controls the sending of queued mails
+type MailRelayOptions struct {
+	BatchSize   int           // mails sent per run
+	MaxAttempts int           // a mail is dropped after this many failed attempts, the user can ask for a new link
+	BackoffBase time.Duration // wait before the first retry, doubled for every following one
+	BackoffMax  time.Duration
+}
+
+// MailRelay sends the mails written to the mail outbox
+type MailRelay struct {
+	pool    *pgxpool.Pool
+	queries TxQuerier
+	mailer  mail.Mailer
+	options MailRelayOptions
+}
+
+func NewMailRelay(pool *pgxpool.Pool, queries TxQuerier, mailer mail.Mailer, options MailRelayOptions) *MailRelay {
+	return &MailRelay{
+		pool:    pool,
+		queries: queries,
+		mailer:  mailer,
+		options: options,
+	}
+}
+
+// SendPending sends up to one batch of due mails and returns how many were sent
+// Mails stay locked while they are sent, so a second relay skips them. A mail is deleted only after Send
+// returned, so a crash in between sends it again (at-least-once).
+func (r *MailRelay) SendPending(ctx context.Context) (int, error) {
+	var sent int
+
+	err := WithTx(ctx, r.pool, r.queries, TxOptions{}, func(q database.Querier) error {
+		sent = 0
+
+		due, err := q.ListDueOutboxMails(ctx, int32(r.options.BatchSize))
+		if err != nil {
+			return err
+		}
+
+		for _, row := range due {
+			err := r.mailer.Send(ctx, mail.Message{
+				To:      row.Recipient,
+				Subject: row.Subject,
+				Text:    row.TextBody,
+				HTML:    row.HtmlBody,
+			})
+			if err == nil {
+				if err := q.DeleteOutboxMail(ctx, row.MailID); err != nil {
+					return err
+				}
+				slog.Info("verification email sent", "org_id", row.OrgID, "target_user_id", row.UserID)
+				sent++
+				continue
+			}
+
+			attempts := int(row.Attempts) + 1
+			if attempts >= r.options.MaxAttempts {
+				slog.Error("sending mail failed, giving up", "mail_id", row.MailID, "target_user_id", row.UserID, "attempts", attempts, "error", err)
+				if err := q.DeleteOutboxMail(ctx, row.MailID); err != nil {
+					return err
+				}
+				continue
+			}
+
+			slog.Warn("sending mail failed, retrying later", "mail_id", row.MailID, "target_user_id", row.UserID, "attempts", attempts, "error", err)
+			err = q.RecordOutboxMailFailure(ctx, database.RecordOutboxMailFailureParams{
+				MailID:        row.MailID,
+				LastError:     pgtype.Text{String: err.Error(), Valid: true},
+				NextAttemptAt: pgtype.Timestamptz{Time: time.Now().Add(webhooks.Backoff(attempts, r.options.BackoffBase, r.options.BackoffMax)), Valid: true},
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return sent, nil
+}
+
+// Run sends due mails every interval until ctx is cancelled
+// Run it in its own goroutine
+func (r *MailRelay) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// Keep going while batches come back full, so a backlog such as an import drains without waiting for the ticker
+			for {
+				sent, err := r.SendPending(ctx)
+				if err != nil {
+					if ctx.Err() == nil {
+						slog.Error("mail relay failed", "error", err)
+					}
+					break
+				}
+				if sent < r.options.BatchSize {
+					break
+				}
+			}
+		}
+	}
+}
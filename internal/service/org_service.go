@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	database "user-management-api/db/sqlc"
+	"user-management-api/internal/models"
+	"user-management-api/internal/tenant"
+	"user-management-api/internal/utils"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// OrgService manages the organizations (tenants) - unlike the other services it is not scoped to one
+type OrgService struct {
+	queries database.Querier
+}
+
+func NewOrgService(queries database.Querier) *OrgService {
+	return &OrgService{
+		queries: queries,
+	}
+}
+
+// ResolveOrg implements middleware.OrgResolver - ref is an organization ID or slug
+// found is false when no organization matches.
+func (s *OrgService) ResolveOrg(ctx context.Context, ref string) (_ uuid.UUID, found bool, _ error) {
+	var org database.Organization
+	var err error
+	if id, parseErr := uuid.Parse(ref); parseErr == nil {
+		org, err = s.queries.GetOrganization(ctx, id)
+	} else {
+		org, err = s.queries.GetOrganizationBySlug(ctx, ref)
+	}
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return uuid.Nil, false, nil
+		}
+		return uuid.Nil, false, err
+	}
+	return org.OrgID, true, nil
+}
+
+func (s *OrgService) CreateOrganization(ctx context.Context, req models.CreateOrganizationRequest) (*models.OrganizationResponse, error) {
+	org, err := s.queries.CreateOrganization(ctx, database.CreateOrganizationParams{
+		Slug: req.Slug,
+		Name: req.Name,
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, models.NewConflictError(models.CodeOrgSlugTaken, "Slug already exists")
+		}
+		return nil, models.NewInternalServerError("Failed to create organization", err)
+	}
+
+	response := convertOrganization(org)
+	return &response, nil
+}
+
+func (s *OrgService) ListOrganizations(ctx context.Context) (*models.ListOrganizationsResponse, error) {
+	orgs, err := s.queries.ListOrganizations(ctx)
+	if err != nil {
+		return nil, models.NewInternalServerError("Failed to list organizations", err)
+	}
+
+	response := &models.ListOrganizationsResponse{
+		Organizations: make([]models.OrganizationResponse, len(orgs)),
+	}
+	for i, org := range orgs {
+		response.Organizations[i] = convertOrganization(org)
+	}
+
+	return response, nil
+}
+
+func (s *OrgService) GetOrganization(ctx context.Context, orgID string) (*models.OrganizationResponse, error) {
+	id, err := parseOrgID(orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	org, err := s.queries.GetOrganization(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, models.NewNotFoundError(models.CodeOrgNotFound, "Organization not found")
+		}
+		return nil, models.NewInternalServerError("Failed to get organization", err)
+	}
+
+	response := convertOrganization(org)
+	return &response, nil
+}
+
+// UpdateOrganization renames an organization - changing the slug changes its subdomain
+func (s *OrgService) UpdateOrganization(ctx context.Context, orgID string, req models.UpdateOrganizationRequest) (*models.OrganizationResponse, error) {
+	id, err := parseOrgID(orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	org, err := s.queries.UpdateOrganization(ctx, database.UpdateOrganizationParams{
+		OrgID: id,
+		Slug:  utils.ConvertStringPtrToText(req.Slug),
+		Name:  utils.ConvertStringPtrToText(req.Name),
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, models.NewNotFoundError(models.CodeOrgNotFound, "Organization not found")
+		}
+		if isUniqueViolation(err) {
+			return nil, models.NewConflictError(models.CodeOrgSlugTaken, "Slug already exists")
+		}
+		return nil, models.NewInternalServerError("Failed to update organization", err)
+	}
+
+	response := convertOrganization(org)
+	return &response, nil
+}
+
+// DeleteOrganization removes an organization that has no users left
+// Soft deleted users count until the purge job removed them.
+func (s *OrgService) DeleteOrganization(ctx context.Context, orgID string) error {
+	id, err := parseOrgID(orgID)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := s.queries.DeleteOrganization(ctx, id)
+	if err != nil {
+		if pgErrorCode(err) == pgForeignKeyViolation {
+			return models.NewConflictError(models.CodeOrgNotEmpty, "Organization still has users")
+		}
+		return models.NewInternalServerError("Failed to delete organization", err)
+	}
+	if deleted == 0 {
+		return models.NewNotFoundError(models.CodeOrgNotFound, "Organization not found")
+	}
+
+	return nil
+}
+
+// requestOrgID returns the organization the request acts in
+// Every user query is scoped to it, so a request without one is a wiring bug (middleware.Tenant is missing).
+func requestOrgID(ctx context.Context) (uuid.UUID, error) {
+	orgID, ok := tenant.OrgIDFromContext(ctx)
+	if !ok {
+		return uuid.Nil, models.NewInternalServerError("No organization for the request", errors.New("no organization in the request context"))
+	}
+	return orgID, nil
+}
+
+func parseOrgID(orgID string) (uuid.UUID, error) {
+	id, err := uuid.Parse(orgID)
+	if err != nil {
+		return id, models.NewBadRequestError(models.CodeInvalidOrgID, "Invalid organization ID format")
+	}
+	return id, nil
+}
+
+func convertOrganization(org database.Organization) models.OrganizationResponse {
+	return models.OrganizationResponse{
+		ID:        org.OrgID,
+		Slug:      org.Slug,
+		Name:      org.Name,
+		CreatedAt: org.CreatedAt.Time,
+		UpdatedAt: org.UpdatedAt.Time,
+	}
+}
@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sort"
+	"time"
+
+	database "user-management-api/db/sqlc"
+	"user-management-api/internal/audit"
+	"user-management-api/internal/events"
+	"user-management-api/internal/models"
+	"user-management-api/internal/utils"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// enqueueUserEvent adds a user event to the outbox - pass the transaction's queries
+// so the event is only published if the change is committed
+func enqueueUserEvent(ctx context.Context, q database.Querier, eventType string, orgID, userID uuid.UUID, data interface{}) error {
+	event, err := events.New(eventType, orgID, events.AggregateUser, userID, data)
+	if err != nil {
+		return models.NewInternalServerError("Failed to create event", err)
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return models.NewInternalServerError("Failed to create event", err)
+	}
+
+	err = q.CreateOutboxEvent(ctx, database.CreateOutboxEventParams{
+		EventID:       event.ID,
+		OrgID:         event.OrgID,
+		AggregateType: event.AggregateType,
+		AggregateID:   event.AggregateID,
+		EventType:     event.Type,
+		SchemaVersion: int32(event.SchemaVersion),
+		Payload:       payload,
+		OccurredAt:    pgtype.Timestamptz{Time: event.OccurredAt, Valid: true},
+	})
+	if err != nil {
+		return models.NewInternalServerError("Failed to create event", err)
+	}
+
+	// Webhook subscribers of the organization get the same event, queued in the same transaction
+	return enqueueWebhookDeliveries(ctx, q, event.OrgID, event.ID, event.Type, payload)
+}
+
+// enqueueUserUpdatedEvent adds exactly one event for an update: UserStatusChanged when the status changed,
+// UserUpdated otherwise. Nothing is added when the update didn't change anything.
+func enqueueUserUpdatedEvent(ctx context.Context, q database.Querier, before, after database.User) error {
+	changes, err := audit.Diff(eventUser(before), eventUser(after), "updatedAt")
+	if err != nil {
+		return models.NewInternalServerError("Failed to create event", err)
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	changedFields := make([]string, 0, len(changes))
+	for field := range changes {
+		changedFields = append(changedFields, field)
+	}
+	sort.Strings(changedFields)
+
+	// The status change carries the whole user, so other fields changed with it aren't lost to its consumers
+	if before.Status != after.Status {
+		return enqueueUserEvent(ctx, q, events.TypeUserStatusChanged, after.OrgID, after.UserID, events.UserStatusChanged{
+			UserID:        after.UserID,
+			OldStatus:     string(before.Status),
+			NewStatus:     string(after.Status),
+			User:          eventUser(after),
+			ChangedFields: changedFields,
+		})
+	}
+
+	return enqueueUserEvent(ctx, q, events.TypeUserUpdated, after.OrgID, after.UserID, events.UserUpdated{
+		User:          eventUser(after),
+		ChangedFields: changedFields,
+	})
+}
+
+// eventUser converts a database user to its event form
+func eventUser(user database.User) events.User {
+	return events.User{
+		UserID:          user.UserID,
+		FirstName:       user.FirstName,
+		LastName:        user.LastName,
+		Email:           user.Email,
+		EmailVerifiedAt: utils.ConvertTimestamptzToTimePtr(user.EmailVerifiedAt),
+		Phone:           utils.ConvertTextToStringPtr(user.Phone),
+		Age:             utils.ConvertInt4ToIntPtr(user.Age),
+		Status:          string(user.Status),
+		CreatedAt:       user.CreatedAt.Time,
+		UpdatedAt:       user.UpdatedAt.Time,
+		DeletedAt:       utils.ConvertTimestamptzToTimePtr(user.DeletedAt),
+	}
+}
+
+// OutboxRelay publishes the events written to the outbox
+type OutboxRelay struct {
+	pool      *pgxpool.Pool
+	queries   TxQuerier
+	publisher events.Publisher
+	batchSize int
+}
+
+func NewOutboxRelay(pool *pgxpool.Pool, queries TxQuerier, publisher events.Publisher, batchSize int) *OutboxRelay {
+	return &OutboxRelay{
+		pool:      pool,
+		queries:   queries,
+		publisher: publisher,
+		batchSize: batchSize,
+	}
+}
+
+// PublishPending publishes up to one batch of pending events and returns how many were published
+// Events stay locked while they are published, so a second relay can't publish them out of order.
+// An event is marked as published only after Publish returned, so a crash in between publishes it again (at-least-once).
+func (r *OutboxRelay) PublishPending(ctx context.Context) (int, error) {
+	var published int
+
+	err := WithTx(ctx, r.pool, r.queries, TxOptions{}, func(q database.Querier) error {
+		published = 0
+
+		pending, err := q.ListPendingOutboxEvents(ctx, int32(r.batchSize))
+		if err != nil {
+			return err
+		}
+
+		// Once an event of a user fails, later events of that user wait for the next run to keep them in order
+		failed := make(map[uuid.UUID]bool)
+
+		for _, row := range pending {
+			if failed[row.AggregateID] {
+				continue
+			}
+
+			var event events.Event
+			err := json.Unmarshal(row.Payload, &event)
+			if err == nil {
+				err = r.publisher.Publish(ctx, event)
+			}
+			if err != nil {
+				failed[row.AggregateID] = true
+				slog.Error("publishing event failed", "event_id", row.EventID, "event_type", row.EventType, "error", err)
+
+				err = q.RecordOutboxEventFailure(ctx, database.RecordOutboxEventFailureParams{
+					EventID:   row.EventID,
+					LastError: pgtype.Text{String: err.Error(), Valid: true},
+				})
+				if err != nil {
+					return err
+				}
+				continue
+			}
+
+			if err := q.MarkOutboxEventPublished(ctx, row.EventID); err != nil {
+				return err
+			}
+			published++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return published, nil
+}
+
+// Run publishes pending events every interval until ctx is cancelled
+// Published events older than retention are deleted once an hour (0 keeps them forever).
+// Run it in its own goroutine
+func (r *OutboxRelay) Run(ctx context.Context, interval, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	cleanup := time.NewTicker(time.Hour)
+	defer cleanup.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// Keep going while batches come back full, so a backlog drains without waiting for the ticker
+			for {
+				published, err := r.PublishPending(ctx)
+				if err != nil {
+					slog.Error("outbox relay failed", "error", err)
+					break
+				}
+				if published < r.batchSize {
+					break
+				}
+			}
+		case <-cleanup.C:
+			if retention <= 0 {
+				continue
+			}
+			cutoff := pgtype.Timestamptz{Time: time.Now().Add(-retention), Valid: true}
+			deleted, err := r.queries.DeletePublishedOutboxEvents(ctx, cutoff)
+			if err != nil {
+				slog.Error("outbox cleanup failed", "error", err)
+				continue
+			}
+			if deleted > 0 {
+				slog.Info("deleted published outbox events", "count", deleted)
+			}
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	database "user-management-api/db/sqlc"
+	"user-management-api/internal/ratelimit"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// RateLimitService is a ratelimit.Store backed by Postgres, so every instance shares the same counts
+type RateLimitService struct {
+	pool    *pgxpool.Pool
+	queries TxQuerier
+}
+
+func NewRateLimitService(pool *pgxpool.Pool, queries TxQuerier) *RateLimitService {
+	return &RateLimitService{
+		pool:    pool,
+		queries: queries,
+	}
+}
+
+func (s *RateLimitService) Allow(ctx context.Context, key string, limit ratelimit.Limit) (ratelimit.Result, error) {
+	var result ratelimit.Result
+
+	err := WithTx(ctx, s.pool, s.queries, TxOptions{}, func(q database.Querier) error {
+		// Instances share TATs, so their clocks must agree - NTP is more than enough
+		now := time.Now()
+
+		err := q.CreateRateLimit(ctx, database.CreateRateLimitParams{
+			LimitKey: key,
+			Tat:      pgtype.Timestamptz{Time: now, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+
+		tat, err := q.GetRateLimitForUpdate(ctx, key)
+		if err != nil {
+			return err
+		}
+
+		var newTAT time.Time
+		newTAT, result = ratelimit.GCRA(tat.Time, now, limit)
+		if !result.Allowed {
+			return nil
+		}
+
+		return q.UpdateRateLimit(ctx, database.UpdateRateLimitParams{
+			LimitKey: key,
+			Tat:      pgtype.Timestamptz{Time: newTAT, Valid: true},
+		})
+	})
+	if err != nil {
+		return ratelimit.Result{}, err
+	}
+
+	return result, nil
+}
+
+// RunCleanup deletes the state of clients that are back to a full burst, every interval until ctx is cancelled
+// Run it in its own goroutine
+func (s *RateLimitService) RunCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := s.queries.DeleteExpiredRateLimits(ctx, pgtype.Timestamptz{Time: time.Now(), Valid: true})
+			if err != nil {
+				slog.Error("cleanup of rate limits failed", "error", err)
+				continue
+			}
+			if deleted > 0 {
+				slog.Info("deleted expired rate limits", "count", deleted)
+			}
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+
+	database "user-management-api/db/sqlc"
+	"user-management-api/internal/auth"
+	"user-management-api/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// adminRole manages an organization, every organization keeps at least one user with it
+const adminRole = "admin"
+
+type RoleService struct {
+	pool      *pgxpool.Pool
+	queries   TxQuerier
+	txOptions TxOptions
+}
+
+func NewRoleService(pool *pgxpool.Pool, queries TxQuerier, txOptions TxOptions) *RoleService {
+	return &RoleService{
+		pool:      pool,
+		queries:   queries,
+		txOptions: txOptions,
+	}
+}
+
+// UserPermissions implements middleware.PermissionLoader
+// Subjects that aren't one of our user IDs (tokens from another issuer) get no permissions,
+// and neither do users of another organization than the one of the request
+func (s *RoleService) UserPermissions(ctx context.Context, subject string) ([]string, error) {
+	id, err := uuid.Parse(subject)
+	if err != nil {
+		return nil, nil
+	}
+	orgID, err := requestOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.queries.GetUserPermissions(ctx, database.GetUserPermissionsParams{UserID: id, OrgID: orgID})
+}
+
+// ListRoles returns every role with the permissions it grants
+func (s *RoleService) ListRoles(ctx context.Context) (*models.ListRolesResponse, error) {
+	roles, err := s.queries.ListRoles(ctx)
+	if err != nil {
+		return nil, models.NewInternalServerError("Failed to list roles", err)
+	}
+
+	rolePermissions, err := s.queries.ListRolePermissions(ctx)
+	if err != nil {
+		return nil, models.NewInternalServerError("Failed to list role permissions", err)
+	}
+
+	// Group permissions by role
+	permissionsByRole := make(map[string][]string)
+	for _, rp := range rolePermissions {
+		permissionsByRole[rp.RoleName] = append(permissionsByRole[rp.RoleName], rp.PermissionName)
+	}
+
+	response := &models.ListRolesResponse{
+		Roles: make([]models.RoleResponse, len(roles)),
+	}
+	for i, role := range roles {
+		permissions := permissionsByRole[role.RoleName]
+		if permissions == nil {
+			permissions = []string{}
+		}
+		response.Roles[i] = models.RoleResponse{
+			Name:        role.RoleName,
+			Description: role.Description.String,
+			Permissions: permissions,
+		}
+	}
+
+	return response, nil
+}
+
+// GetUserRoles returns the roles assigned to a user
+func (s *RoleService) GetUserRoles(ctx context.Context, userID string) (*models.UserRolesResponse, error) {
+	id, err := s.parseExistingUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.userRoles(ctx, id)
+}
+
+// AssignRole gives a user a role - assigning a role the user already has is not an error
+func (s *RoleService) AssignRole(ctx context.Context, userID string, req models.AssignRoleRequest) (*models.UserRolesResponse, error) {
+	id, err := s.parseExistingUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := s.queries.RoleExists(ctx, req.Role)
+	if err != nil {
+		return nil, models.NewInternalServerError("Failed to check role", err)
+	}
+	if !exists {
+		return nil, models.NewBadRequestError(models.CodeRoleUnknown, "Unknown role")
+	}
+	if err := s.checkGrantable(ctx, req.Role); err != nil {
+		return nil, err
+	}
+
+	err = s.queries.AssignUserRole(ctx, database.AssignUserRoleParams{
+		UserID:   id,
+		RoleName: req.Role,
+	})
+	if err != nil {
+		return nil, models.NewInternalServerError("Failed to assign role", err)
+	}
+
+	return s.userRoles(ctx, id)
+}
+
+// RemoveRole takes a role away from a user
+// The same roles can be removed as assigned, and the last admin of an organization keeps the admin role
+func (s *RoleService) RemoveRole(ctx context.Context, userID, role string) (*models.UserRolesResponse, error) {
+	id, err := s.parseExistingUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	orgID, err := requestOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkGrantable(ctx, role); err != nil {
+		return nil, err
+	}
+
+	err = WithTx(ctx, s.pool, s.queries, s.txOptions, func(q database.Querier) error {
+		if role == adminRole {
+			// The lock makes concurrent removals of the last two admins wait for each other
+			holders, err := q.LockRoleHolders(ctx, database.LockRoleHoldersParams{RoleName: role, OrgID: orgID})
+			if err != nil {
+				return models.NewInternalServerError("Failed to check role", err)
+			}
+			if len(holders) == 1 && holders[0] == id {
+				return models.NewConflictError(models.CodeLastAdmin, "The last admin of an organization can't lose the admin role")
+			}
+		}
+
+		removed, err := q.RemoveUserRole(ctx, database.RemoveUserRoleParams{
+			UserID:   id,
+			RoleName: role,
+		})
+		if err != nil {
+			return models.NewInternalServerError("Failed to remove role", err)
+		}
+		if removed == 0 {
+			return models.NewNotFoundError(models.CodeRoleNotAssigned, "User does not have this role")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.userRoles(ctx, id)
+}
+
+// checkGrantable stops callers from assigning or removing a role that grants a permission they don't have
+// themselves - otherwise the admin of one organization could make themselves platform_admin and reach every
+// organization, or take platform_admin away from the people running the service
+func (s *RoleService) checkGrantable(ctx context.Context, role string) error {
+	caller, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	rolePermissions, err := s.queries.ListRolePermissions(ctx)
+	if err != nil {
+		return models.NewInternalServerError("Failed to list role permissions", err)
+	}
+	for _, rp := range rolePermissions {
+		if rp.RoleName == role && !caller.HasPermission(rp.PermissionName) {
+			return models.NewForbiddenError(models.CodePermissionDenied, "You can't assign or remove a role with permissions you don't have")
+		}
+	}
+	return nil
+}
+
+// parseExistingUserID parses a user ID and checks the user exists in the organization of the request
+func (s *RoleService) parseExistingUserID(ctx context.Context, userID string) (uuid.UUID, error) {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return uuid.Nil, models.NewBadRequestError(models.CodeInvalidUserID, "Invalid user ID format")
+	}
+	orgID, err := requestOrgID(ctx)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	exists, err := s.queries.UserExists(ctx, database.UserExistsParams{OrgID: orgID, UserID: id})
+	if err != nil {
+		return uuid.Nil, models.NewInternalServerError("Failed to check user", err)
+	}
+	if !exists {
+		return uuid.Nil, models.NewNotFoundError(models.CodeUserNotFound, "User not found")
+	}
+
+	return id, nil
+}
+
+func (s *RoleService) userRoles(ctx context.Context, id uuid.UUID) (*models.UserRolesResponse, error) {
+	roles, err := s.queries.GetUserRoles(ctx, id)
+	if err != nil {
+		return nil, models.NewInternalServerError("Failed to get user roles", err)
+	}
+
+	return &models.UserRolesResponse{
+		UserID: id,
+		Roles:  roles,
+	}, nil
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	database "user-management-api/db/sqlc"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Postgres error codes we react to - https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	pgUniqueViolation      = "23505"
+	pgForeignKeyViolation  = "23503"
+	pgSerializationFailure = "40001"
+	pgDeadlockDetected     = "40P01"
+)
+
+// TxQuerier is a Querier that can be bound to a transaction - *database.Queries implements it
+type TxQuerier interface {
+	database.Querier
+	WithTx(tx pgx.Tx) *database.Queries
+}
+
+// TxOptions controls how WithTx runs transactions
+type TxOptions struct {
+	IsoLevel   pgx.TxIsoLevel // empty means the server default (read committed)
+	MaxRetries int            // extra attempts after a serialization failure or deadlock
+}
+
+// WithTx runs fn in a transaction, passing it queries bound to that transaction
+// The transaction commits when fn returns nil and rolls back otherwise.
+// Serialization failures and deadlocks are retried up to opts.MaxRetries times, so fn must be safe to run again.
+func WithTx(ctx context.Context, pool *pgxpool.Pool, queries TxQuerier, opts TxOptions, fn func(q database.Querier) error) error {
+	var err error
+
+	for attempt := 0; attempt <= opts.MaxRetries; attempt++ {
+		if attempt > 0 {
+			// Short growing pause so the competing transaction can finish
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Duration(attempt) * 10 * time.Millisecond):
+			}
+		}
+
+		err = runTx(ctx, pool, queries, opts, fn)
+		if !isRetryable(err) {
+			return err
+		}
+	}
+
+	return err
+}
+
+func runTx(ctx context.Context, pool *pgxpool.Pool, queries TxQuerier, opts TxOptions, fn func(q database.Querier) error) error {
+	tx, err := pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: opts.IsoLevel})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx) // no-op after Commit
+
+	if err := fn(queries.WithTx(tx)); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// pgErrorCode returns the SQLSTATE of a Postgres error anywhere in the chain, or ""
+func pgErrorCode(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code
+	}
+	return ""
+}
+
+// isUniqueViolation reports whether err comes from a unique constraint
+func isUniqueViolation(err error) bool {
+	return pgErrorCode(err) == pgUniqueViolation
+}
+
+// isRetryable reports whether a transaction failed only because of concurrent transactions
+func isRetryable(err error) bool {
+	code := pgErrorCode(err)
+	return code == pgSerializationFailure || code == pgDeadlockDetected
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"user-management-api/internal/export"
+	"user-management-api/internal/logging"
+	"user-management-api/internal/models"
+	"user-management-api/internal/utils"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// exportFetchSize is how many users each FETCH from the export cursor returns - also the flush interval
+const exportFetchSize = 1000
+
+// UserExport is an export whose query is checked and whose snapshot is taken, ready to be written
+// It holds a read only transaction, always Close it.
+type UserExport struct {
+	tx      pgx.Tx
+	columns []string
+}
+
+// ExportUsers checks the query and declares a cursor over the matching users
+// The cursor lives in a repeatable read transaction, so the export is one consistent snapshot however long
+// it takes. Errors are returned before anything is written, so they can still be sent as problems.
+func (s *UserService) ExportUsers(ctx context.Context, query models.ExportUsersQuery) (_ *UserExport, err error) {
+	ctx, done := s.startOperation(ctx, "ExportUsers")
+	defer done(&err)
+	orgID, err := requestOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkUserFilters(ctx, query.UserFilters); err != nil {
+		return nil, err
+	}
+
+	if query.Sort == "" {
+		query.Sort = defaultSort
+	}
+	queries, ok := userSortQueries[query.Sort]
+	if !ok {
+		return nil, models.NewBadRequestError(models.CodeValidationFailed, "Invalid sort")
+	}
+
+	tx, err := s.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, models.NewInternalServerError("Failed to export users", err)
+	}
+
+	if _, err := tx.Exec(ctx, queries.export, userFilterArgs(orgID, query.UserFilters)...); err != nil {
+		_ = tx.Rollback(ctx)
+		return nil, models.NewInternalServerError("Failed to export users", err)
+	}
+
+	columns := query.Columns
+	if len(columns) == 0 {
+		columns = models.ExportColumns
+	}
+	return &UserExport{tx: tx, columns: columns}, nil
+}
+
+// WriteTo writes the header and every user to w, flushing after each batch, and returns the number of users
+// It stops with ctx's error when ctx is cancelled (the client went away), w is aborted on any error.
+func (e *UserExport) WriteTo(ctx context.Context, w export.Writer) (_ int, err error) {
+	defer func() {
+		if err != nil {
+			w.Abort()
+		}
+	}()
+
+	if err := w.WriteHeader(e.columns); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for {
+		rows, err := e.tx.Query(ctx, fmt.Sprintf("FETCH FORWARD %d FROM user_export", exportFetchSize))
+		if err != nil {
+			return count, err
+		}
+
+		fetched := 0
+		for rows.Next() {
+			user, err := scanUser(rows)
+			if err != nil {
+				rows.Close()
+				return count, err
+			}
+			if err := w.WriteRow(exportValues(utils.ConvertToUserResponse(user), e.columns)); err != nil {
+				rows.Close()
+				return count, err
+			}
+			fetched++
+		}
+		if err := rows.Err(); err != nil {
+			return count, err
+		}
+		count += fetched
+
+		if err := w.Flush(); err != nil {
+			return count, err
+		}
+		if fetched < exportFetchSize {
+			break
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return count, err
+	}
+	logging.FromContext(ctx).Info("users exported", "rows", count)
+	return count, nil
+}
+
+// Close ends the snapshot transaction
+func (e *UserExport) Close(ctx context.Context) error {
+	return e.tx.Rollback(ctx)
+}
+
+// exportValues picks the columns of an export from a user
+func exportValues(user *models.UserResponse, columns []string) []interface{} {
+	values := make([]interface{}, len(columns))
+	for i, column := range columns {
+		switch column {
+		case "userId":
+			values[i] = user.UserID.String()
+		case "firstName":
+			values[i] = user.FirstName
+		case "lastName":
+			values[i] = user.LastName
+		case "email":
+			values[i] = user.Email
+		case "phone":
+			if user.Phone != nil {
+				values[i] = *user.Phone
+			}
+		case "age":
+			if user.Age != nil {
+				values[i] = *user.Age
+			}
+		case "status":
+			values[i] = string(user.Status)
+		case "createdAt":
+			values[i] = user.CreatedAt
+		case "updatedAt":
+			values[i] = user.UpdatedAt
+		case "deletedAt":
+			if user.DeletedAt != nil {
+				values[i] = *user.DeletedAt
+			}
+		case "version":
+			values[i] = user.Version
+		case "emailVerifiedAt":
+			if user.EmailVerifiedAt != nil {
+				values[i] = *user.EmailVerifiedAt
+			}
+		}
+	}
+	return values
+}
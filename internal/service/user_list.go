@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	database "user-management-api/db/sqlc"
+	"user-management-api/internal/auth"
+	"user-management-api/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const defaultSort = "-created_at"
+
+// sortField describes a column the list endpoint is allowed to sort on
+type sortField struct {
+	column string
+	isTime bool // cursor values of time columns are sent as RFC 3339 strings
+}
+
+// sortFields is the allow list of sort fields - anything else is rejected by the validator
+var sortFields = map[string]sortField{
+	"created_at": {column: "created_at", isTime: true},
+	"updated_at": {column: "updated_at", isTime: true},
+	"first_name": {column: "first_name"},
+	"last_name":  {column: "last_name"},
+	"email":      {column: "email"},
+}
+
+// userColumns is the column list of the hand written list queries
+// Same as database.User without password_hash - hashes never need to leave the database here
+const userColumns = "user_id, first_name, last_name, email, phone, age, status, created_at, updated_at, deleted_at, version, org_id, email_verified_at"
+
+// userFilterSQL is the condition of the user list and export queries, $1 to $7 are the values of userFilterArgs
+// A filter that isn't set is NULL and matches every user, so the SQL is the same whatever is filtered on.
+const userFilterSQL = `org_id = $1
+	AND ($2::boolean OR deleted_at IS NULL)
+	AND ($3::user_status IS NULL OR status = $3)
+	AND ($4::text IS NULL OR email ILIKE $4)
+	AND ($5::text IS NULL OR first_name ILIKE $5 OR last_name ILIKE $5 OR first_name || ' ' || last_name ILIKE $5)
+	AND ($6::integer IS NULL OR age >= $6)
+	AND ($7::integer IS NULL OR age <= $7)`
+
+const countUsersSQL = "SELECT COUNT(*) FROM users WHERE " + userFilterSQL
+
+// sortQueries are the list and export queries of one sort
+type sortQueries struct {
+	firstPage string // $8 is the limit
+	nextPage  string // the rows after the cursor row - $8 and $9 are its sort value and user_id, $10 is the limit
+	export    string // declares the user_export cursor over every matching user
+}
+
+// userSortQueries holds the queries of every sort ("created_at", "-created_at", ...)
+// ORDER BY can't take parameters, so each sort has queries of its own, written from the allow list once -
+// client input never ends up in the SQL. Every one of them can read an (org_id, column, user_id) index in order.
+var userSortQueries = newUserSortQueries()
+
+func newUserSortQueries() map[string]sortQueries {
+	queries := make(map[string]sortQueries, 2*len(sortFields))
+	for name, field := range sortFields {
+		for _, desc := range []bool{false, true} {
+			sort, direction, op := name, "ASC", ">"
+			if desc {
+				sort, direction, op = "-"+name, "DESC", "<"
+			}
+			selectSQL := "SELECT " + userColumns + " FROM users WHERE " + userFilterSQL
+			orderSQL := fmt.Sprintf(" ORDER BY %[1]s %[2]s, user_id %[2]s", field.column, direction)
+
+			queries[sort] = sortQueries{
+				firstPage: selectSQL + orderSQL + " LIMIT $8",
+				nextPage:  selectSQL + fmt.Sprintf(" AND (%s, user_id) %s ($8, $9)", field.column, op) + orderSQL + " LIMIT $10",
+				export:    "DECLARE user_export NO SCROLL CURSOR FOR " + selectSQL + orderSQL,
+			}
+		}
+	}
+	return queries
+}
+
+// listCursor is the position after the last row of a page
+// ID (the row's primary key) breaks ties between rows with the same sort value
+type listCursor struct {
+	Sort  string    `json:"s"`
+	Value string    `json:"v"`
+	ID    uuid.UUID `json:"id"`
+}
+
+// encodeCursor turns a cursor into an opaque token for clients
+func encodeCursor(c listCursor) string {
+	data, _ := json.Marshal(c) // marshalling a struct of strings can't fail
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(token string) (listCursor, error) {
+	var c listCursor
+
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, err
+	}
+
+	return c, nil
+}
+
+// checkUserFilters rejects filters that can't be applied for the caller
+func checkUserFilters(ctx context.Context, filters models.UserFilters) error {
+	if filters.MinAge != nil && filters.MaxAge != nil && *filters.MinAge > *filters.MaxAge {
+		return models.NewBadRequestError(models.CodeInvalidAgeRange, "min_age must not be greater than max_age")
+	}
+
+	// Deleted users are only visible to callers who can delete (and so restore) users
+	if filters.IncludeDeleted {
+		if caller, ok := auth.PrincipalFromContext(ctx); ok && !caller.HasPermission(auth.PermUsersDelete) {
+			return models.NewForbiddenError(models.CodePermissionDenied, "You do not have permission to list deleted users")
+		}
+	}
+	return nil
+}
+
+// userFilterArgs returns the values of userFilterSQL for the filters of a list or export query
+func userFilterArgs(orgID uuid.UUID, filters models.UserFilters) []interface{} {
+	args := []interface{}{orgID, filters.IncludeDeleted, nil, nil, nil, nil, nil}
+	if filters.Status != "" {
+		args[2] = string(filters.Status)
+	}
+	if filters.Email != "" {
+		args[3] = containsPattern(filters.Email)
+	}
+	if filters.Name != "" {
+		args[4] = containsPattern(filters.Name)
+	}
+	if filters.MinAge != nil {
+		args[5] = *filters.MinAge
+	}
+	if filters.MaxAge != nil {
+		args[6] = *filters.MaxAge
+	}
+	return args
+}
+
+// containsPattern builds an ILIKE pattern matching s anywhere, with LIKE wildcards escaped
+func containsPattern(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+	return "%" + s + "%"
+}
+
+// parseSort returns the field of a sort such as "-created_at"
+func parseSort(sort string) sortField {
+	return sortFields[strings.TrimPrefix(sort, "-")]
+}
+
+// cursorValue reads the sort value of a user so it can be stored in a cursor
+func cursorValue(user database.User, field sortField) string {
+	switch field.column {
+	case "created_at":
+		return user.CreatedAt.Time.Format(time.RFC3339Nano)
+	case "updated_at":
+		return user.UpdatedAt.Time.Format(time.RFC3339Nano)
+	case "first_name":
+		return user.FirstName
+	case "last_name":
+		return user.LastName
+	default:
+		return user.Email
+	}
+}
+
+// cursorArgs returns the sort value and user_id of the cursor row, the $8 and $9 of nextPage
+func cursorArgs(c listCursor, field sortField) ([]interface{}, error) {
+	var value interface{} = c.Value
+	if field.isTime {
+		t, err := time.Parse(time.RFC3339Nano, c.Value)
+		if err != nil {
+			return nil, err
+		}
+		value = t
+	}
+	return []interface{}{value, c.ID}, nil
+}
+
+// scanUser scans a row selected with userColumns
+func scanUser(row pgx.Row) (database.User, error) {
+	var u database.User
+	err := row.Scan(
+		&u.UserID,
+		&u.FirstName,
+		&u.LastName,
+		&u.Email,
+		&u.Phone,
+		&u.Age,
+		&u.Status,
+		&u.CreatedAt,
+		&u.UpdatedAt,
+		&u.DeletedAt,
+		&u.Version,
+		&u.OrgID,
+		&u.EmailVerifiedAt,
+	)
+	return u, err
+}
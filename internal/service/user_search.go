@@ -0,0 +1,210 @@
+package service
+
+import (
+	"context"
+	"html"
+	"strconv"
+	"strings"
+	"unicode"
+
+	database "user-management-api/db/sqlc"
+	"user-management-api/internal/models"
+	"user-management-api/internal/utils"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// maxSearchTerms caps the words of a search, later words are ignored
+const maxSearchTerms = 8
+
+// SearchUsers finds users by name, email or phone, best match first
+// Every word of a full-text match has to match, while a single word similar to a field (a typo) is enough for
+// a fuzzy match - full-text matches always rank first. Pages are keyset paginated on (score, user_id).
+func (s *UserService) SearchUsers(ctx context.Context, query models.SearchUsersQuery) (_ *models.SearchUsersResponse, err error) {
+	ctx, done := s.startOperation(ctx, "SearchUsers")
+	defer done(&err)
+	orgID, err := requestOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if query.Mode == "" {
+		query.Mode = models.SearchModeFull
+	}
+	terms := searchTerms(query.Q)
+	if len(terms) == 0 {
+		return nil, models.NewBadRequestError(models.CodeInvalidSearchQuery, "q must contain a letter or digit")
+	}
+
+	params := database.SearchUsersParams{
+		Tsquery:   searchTSQuery(terms, query.Mode == models.SearchModePrefix),
+		Terms:     terms,
+		OrgID:     orgID,
+		PageLimit: int32(query.Limit + 1), // one extra row tells us whether there is a next page
+	}
+
+	// The cursor belongs to one search, it is useless (and rejected) for another
+	cursorSort := "search:" + query.Mode + ":" + strings.Join(terms, " ")
+	if query.Cursor != "" {
+		cursor, err := decodeCursor(query.Cursor)
+		if err != nil || cursor.Sort != cursorSort {
+			return nil, models.NewBadRequestError(models.CodeInvalidCursor, "Invalid cursor")
+		}
+		score, err := strconv.ParseFloat(cursor.Value, 64)
+		if err != nil {
+			return nil, models.NewBadRequestError(models.CodeInvalidCursor, "Invalid cursor")
+		}
+		params.AfterScore = pgtype.Float8{Float64: score, Valid: true}
+		params.AfterID = pgtype.UUID{Bytes: cursor.ID, Valid: true}
+	}
+
+	rows, err := s.queries.SearchUsers(ctx, params)
+	if err != nil {
+		return nil, models.NewInternalServerError("Failed to search users", err)
+	}
+
+	hasMore := len(rows) > query.Limit
+	if hasMore {
+		rows = rows[:query.Limit]
+	}
+
+	response := &models.SearchUsersResponse{
+		Results: make([]models.UserSearchResult, len(rows)),
+		Limit:   query.Limit,
+		HasMore: hasMore,
+	}
+	for i, row := range rows {
+		user := utils.ConvertToUserResponse(database.User{
+			UserID:          row.UserID,
+			FirstName:       row.FirstName,
+			LastName:        row.LastName,
+			Email:           row.Email,
+			Phone:           row.Phone,
+			Age:             row.Age,
+			Status:          row.Status,
+			CreatedAt:       row.CreatedAt,
+			UpdatedAt:       row.UpdatedAt,
+			DeletedAt:       row.DeletedAt,
+			Version:         row.Version,
+			OrgID:           row.OrgID,
+			EmailVerifiedAt: row.EmailVerifiedAt,
+		})
+		response.Results[i] = models.UserSearchResult{
+			UserResponse: *user,
+			Score:        row.Score,
+			Highlights:   searchHighlights(user, terms),
+		}
+	}
+	if hasMore {
+		last := rows[len(rows)-1]
+		response.NextCursor = encodeCursor(listCursor{
+			Sort:  cursorSort,
+			Value: strconv.FormatFloat(last.Score, 'g', -1, 64),
+			ID:    last.UserID,
+		})
+	}
+
+	return response, nil
+}
+
+// searchTerms splits a search into lower case words, keeping only letters, digits and the characters
+// of emails and phone numbers - so the words are safe to quote in a tsquery
+func searchTerms(q string) []string {
+	var terms []string
+	for _, word := range strings.Fields(strings.ToLower(q)) {
+		var b strings.Builder
+		hasWordChar := false
+		for _, r := range word {
+			switch {
+			case unicode.IsLetter(r) || unicode.IsDigit(r):
+				hasWordChar = true
+				b.WriteRune(r)
+			case strings.ContainsRune("@._+-", r):
+				b.WriteRune(r)
+			}
+		}
+		if hasWordChar {
+			terms = append(terms, b.String())
+		}
+		if len(terms) == maxSearchTerms {
+			break
+		}
+	}
+	return terms
+}
+
+// searchTSQuery joins the words with & - in prefix mode every word matches the start of a word
+func searchTSQuery(terms []string, prefix bool) string {
+	parts := make([]string, len(terms))
+	for i, term := range terms {
+		parts[i] = "'" + term + "'" // searchTerms dropped quotes and backslashes
+		if prefix {
+			parts[i] += ":*"
+		}
+	}
+	return strings.Join(parts, " & ")
+}
+
+// searchHighlights returns the searched fields of user that contain a word, with the word marked
+func searchHighlights(user *models.UserResponse, terms []string) map[string]string {
+	fields := map[string]string{
+		"firstName": user.FirstName,
+		"lastName":  user.LastName,
+		"email":     user.Email,
+	}
+	if user.Phone != nil {
+		fields["phone"] = *user.Phone
+	}
+
+	highlights := make(map[string]string)
+	for name, value := range fields {
+		if marked, ok := highlight(value, terms); ok {
+			highlights[name] = marked
+		}
+	}
+	if len(highlights) == 0 {
+		return nil
+	}
+	return highlights
+}
+
+// highlight HTML escapes value and wraps every part matching one of the terms (ignoring case) in <mark>
+// ok is false when nothing matched. Fuzzy matches can't be located, so they are not highlighted.
+func highlight(value string, terms []string) (marked string, ok bool) {
+	runes := []rune(value)
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	hit := make([]bool, len(runes))
+	for _, term := range terms {
+		t := []rune(term)
+		for i := 0; i+len(t) <= len(lower); i++ {
+			if string(lower[i:i+len(t)]) == term {
+				for j := i; j < i+len(t); j++ {
+					hit[j] = true
+				}
+				ok = true
+			}
+		}
+	}
+	if !ok {
+		return "", false
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(runes); {
+		j := i
+		for j < len(runes) && hit[j] == hit[i] {
+			j++
+		}
+		part := html.EscapeString(string(runes[i:j]))
+		if hit[i] {
+			part = "<mark>" + part + "</mark>"
+		}
+		b.WriteString(part)
+		i = j
+	}
+	return b.String(), true
+}
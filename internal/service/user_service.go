@@ -3,37 +3,92 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"log/slog"
+	"time"
 
 	database "user-management-api/db/sqlc"
+	"user-management-api/internal/audit"
+	"user-management-api/internal/auth"
+	"user-management-api/internal/events"
+	"user-management-api/internal/logging"
+	"user-management-api/internal/metrics"
 	"user-management-api/internal/models"
+	"user-management-api/internal/tracing"
 	"user-management-api/internal/utils"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
 type UserService struct {
-	pool    *pgxpool.Pool
-	queries database.Querier
+	pool         *pgxpool.Pool
+	queries      TxQuerier
+	txOptions    TxOptions
+	verification *VerificationService
+	metrics      *metrics.ServiceMetrics
 }
 
 // creating the user service instance - dependency injection
-func NewUserService(pool *pgxpool.Pool, queries database.Querier) *UserService {
+// metrics may be nil when nothing is collected
+func NewUserService(pool *pgxpool.Pool, queries TxQuerier, txOptions TxOptions, verification *VerificationService, metrics *metrics.ServiceMetrics) *UserService {
 	return &UserService{
-		pool:    pool,
-		queries: queries,
+		pool:         pool,
+		queries:      queries,
+		txOptions:    txOptions,
+		verification: verification,
+		metrics:      metrics,
 	}
 }
 
-func (s *UserService) CreateUser(ctx context.Context, req models.CreateUserRequest) (*models.UserResponse, error) {
+// startOperation starts the span of an operation - defer the returned func with the named error result,
+// it ends the span and records the outcome and latency in the metrics
+func (s *UserService) startOperation(ctx context.Context, operation string) (context.Context, func(err *error)) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "UserService."+operation)
+
+	return ctx, func(err *error) {
+		class := statusClass(*err)
+		s.metrics.Observe(operation, class, time.Since(start))
+
+		span.SetAttributes(attribute.String("status_class", class))
+		// 4xx are expected outcomes (not found, conflicts), only failures of the service mark the span
+		if class == "5xx" {
+			span.RecordError(*err)
+			span.SetStatus(codes.Error, (*err).Error())
+		}
+		span.End()
+	}
+}
 
-	exists, err := s.queries.EmailExists(ctx, req.Email)
-	if err != nil {
-		return nil, models.NewInternalServerError("Failed to check email existence", err)
+// statusClass is "ok" without an error, otherwise the class of its HTTP status ("4xx", "5xx")
+// Errors that are not AppErrors end up as 500s in the handlers, so they count as "5xx".
+func statusClass(err error) string {
+	if err == nil {
+		return "ok"
 	}
-	if exists {
-		return nil, models.NewConflictError("Email Already Exists")
+	var appErr *models.AppError
+	if errors.As(err, &appErr) {
+		return fmt.Sprintf("%dxx", appErr.StatusCode/100)
+	}
+	return "5xx"
+}
+
+// withTx runs fn in a transaction using the service's isolation level and retry settings
+func (s *UserService) withTx(ctx context.Context, fn func(q database.Querier) error) error {
+	return WithTx(ctx, s.pool, s.queries, s.txOptions, fn)
+}
+
+func (s *UserService) CreateUser(ctx context.Context, req models.CreateUserRequest) (_ *models.UserResponse, err error) {
+	ctx, done := s.startOperation(ctx, "CreateUser")
+	defer done(&err)
+	orgID, err := requestOrgID(ctx)
+	if err != nil {
+		return nil, err
 	}
 
 	status := req.Status
@@ -43,6 +98,7 @@ func (s *UserService) CreateUser(ctx context.Context, req models.CreateUserReque
 	}
 
 	params := database.CreateUserParams{
+		OrgID:     orgID,
 		FirstName: req.FirstName,
 		LastName:  req.LastName,
 		Email:     req.Email,
@@ -51,30 +107,77 @@ func (s *UserService) CreateUser(ctx context.Context, req models.CreateUserReque
 		Status:    string(status),
 	}
 
-	user, err := s.queries.CreateUser(ctx, params)
+	// Only the hash is stored - the password itself never reaches the database
+	if req.Password != "" {
+		hash, err := auth.HashPassword(req.Password)
+		if err != nil {
+			return nil, models.NewInternalServerError("Failed to hash password", err)
+		}
+		params.PasswordHash = utils.ConvertStringPtrToText(&hash)
+	}
+
+	// No separate "does the email exist" check - the unique index (per organization) decides, so concurrent creates
+	// can't both pass
+	var user database.User
+	err = s.withTx(ctx, func(q database.Querier) error {
+		var err error
+		user, err = q.CreateUser(ctx, params)
+		if err != nil {
+			if isUniqueViolation(err) {
+				return models.NewConflictError(models.CodeUserEmailTaken, "Email already exists")
+			}
+			return models.NewInternalServerError("Failed to create user", err)
+		}
+
+		// Every user starts as a member - admins can grant more roles later
+		err = q.AssignUserRole(ctx, database.AssignUserRoleParams{
+			UserID:   user.UserID,
+			RoleName: auth.DefaultRole,
+		})
+		if err != nil {
+			return models.NewInternalServerError("Failed to assign default role", err)
+		}
+
+		// The address is unconfirmed until the user opens the mailed link
+		if err := s.verification.queueVerification(ctx, q, user); err != nil {
+			return err
+		}
+
+		if err := recordUserEvent(ctx, q, audit.ActionUserCreated, user.UserID, nil, &user); err != nil {
+			return err
+		}
+		return enqueueUserEvent(ctx, q, events.TypeUserCreated, user.OrgID, user.UserID, events.UserCreated{User: eventUser(user)})
+	})
 	if err != nil {
-		return nil, models.NewInternalServerError("Failed to create user", err)
+		return nil, err
 	}
+	logging.FromContext(ctx).Info("user created", "target_user_id", user.UserID)
 
 	// Convert database model to response model
 	return utils.ConvertToUserResponse(user), nil
 
 }
 
-func (s *UserService) GetUserByID(ctx context.Context, userID string) (*models.UserResponse, error) {
+func (s *UserService) GetUserByID(ctx context.Context, userID string) (_ *models.UserResponse, err error) {
+	ctx, done := s.startOperation(ctx, "GetUserByID")
+	defer done(&err)
+	orgID, err := requestOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
 
 	// Parse UUID string to UUID type
 	id, err := uuid.Parse(userID)
 	if err != nil {
-		return nil, models.NewBadRequestError("Invalid user ID format")
+		return nil, models.NewBadRequestError(models.CodeInvalidUserID, "Invalid user ID format")
 	}
 
-	// Query database
-	user, err := s.queries.GetUserByID(ctx, id)
+	// Query database - users of other organizations are not found
+	user, err := s.queries.GetUserByID(ctx, database.GetUserByIDParams{OrgID: orgID, UserID: id})
 	if err != nil {
 		// pgx.ErrNoRows means not found
 		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, models.NewNotFoundError("User not found")
+			return nil, models.NewNotFoundError(models.CodeUserNotFound, "User not found")
 		}
 		return nil, models.NewInternalServerError("Failed to get user", err)
 	}
@@ -82,12 +185,66 @@ func (s *UserService) GetUserByID(ctx context.Context, userID string) (*models.U
 	return utils.ConvertToUserResponse(user), nil
 }
 
-// TODO: Add pagination later
-func (s *UserService) ListUsers(ctx context.Context) (*models.ListUsersResponse, error) {
-	users, err := s.queries.ListUsers(ctx)
+// ListUsers returns one page of users matching the filters, using keyset pagination
+// The cursor holds the sort value and user_id of the last row, so pages stay stable while users are added
+func (s *UserService) ListUsers(ctx context.Context, query models.ListUsersQuery) (_ *models.ListUsersResponse, err error) {
+	ctx, done := s.startOperation(ctx, "ListUsers")
+	defer done(&err)
+	orgID, err := requestOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkUserFilters(ctx, query.UserFilters); err != nil {
+		return nil, err
+	}
+
+	if query.Sort == "" {
+		query.Sort = defaultSort
+	}
+	queries, ok := userSortQueries[query.Sort]
+	if !ok {
+		return nil, models.NewBadRequestError(models.CodeValidationFailed, "Invalid sort")
+	}
+	field := parseSort(query.Sort)
+	args := userFilterArgs(orgID, query.UserFilters)
+
+	// Total counts every matching user, not just this page
+	var total int64
+	if err := s.pool.QueryRow(ctx, countUsersSQL, args...).Scan(&total); err != nil {
+		return nil, models.NewInternalServerError("Failed to count users", err)
+	}
+
+	pageSQL := queries.firstPage
+	if query.Cursor != "" {
+		cursor, err := decodeCursor(query.Cursor)
+		if err != nil || cursor.Sort != query.Sort {
+			return nil, models.NewBadRequestError(models.CodeInvalidCursor, "Invalid cursor")
+		}
+		after, err := cursorArgs(cursor, field)
+		if err != nil {
+			return nil, models.NewBadRequestError(models.CodeInvalidCursor, "Invalid cursor")
+		}
+		pageSQL = queries.nextPage
+		args = append(args, after...)
+	}
+
+	// Fetch one extra row to find out whether there is a next page
+	args = append(args, query.Limit+1)
+	rows, err := s.pool.Query(ctx, pageSQL, args...)
 	if err != nil {
 		return nil, models.NewInternalServerError("Failed to list users", err)
 	}
+	users, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (database.User, error) {
+		return scanUser(row)
+	})
+	if err != nil {
+		return nil, models.NewInternalServerError("Failed to list users", err)
+	}
+
+	hasMore := len(users) > query.Limit
+	if hasMore {
+		users = users[:query.Limit]
+	}
 
 	// Convert slice of database users to response users
 	userResponses := make([]models.UserResponse, len(users))
@@ -95,53 +252,55 @@ func (s *UserService) ListUsers(ctx context.Context) (*models.ListUsersResponse,
 		userResponses[i] = *utils.ConvertToUserResponse(user)
 	}
 
-	return &models.ListUsersResponse{
-		Users: userResponses,
-		Total: len(userResponses),
-	}, nil
+	response := &models.ListUsersResponse{
+		Users:   userResponses,
+		Total:   int(total),
+		Limit:   query.Limit,
+		HasMore: hasMore,
+	}
+	if hasMore {
+		last := users[len(users)-1]
+		response.NextCursor = encodeCursor(listCursor{
+			Sort:  query.Sort,
+			Value: cursorValue(last, field),
+			ID:    last.UserID,
+		})
+	}
+
+	return response, nil
 }
 
-func (s *UserService) UpdateUser(ctx context.Context, userID string, req models.UpdateUserRequest) (*models.UserResponse, error) {
-	id, err := uuid.Parse(userID)
+// UpdateUser applies a partial update
+// expectedVersion comes from If-Match - when set, the update fails with 412 if someone else changed the user first
+func (s *UserService) UpdateUser(ctx context.Context, userID string, req models.UpdateUserRequest, expectedVersion *int) (_ *models.UserResponse, err error) {
+	ctx, done := s.startOperation(ctx, "UpdateUser")
+	defer done(&err)
+	orgID, err := requestOrgID(ctx)
 	if err != nil {
-		return nil, models.NewBadRequestError("Invalid user ID format")
+		return nil, err
 	}
-
-	exists, err := s.queries.UserExists(ctx, id)
+	id, err := uuid.Parse(userID)
 	if err != nil {
-		return nil, models.NewInternalServerError("Failed to check user", err)
-	}
-	if !exists {
-		return nil, models.NewNotFoundError("User not found")
+		return nil, models.NewBadRequestError(models.CodeInvalidUserID, "Invalid user ID format")
 	}
 
-	// If email is being updated, check for conflicts
-	if req.Email != nil {
-		emailExists, err := s.queries.EmailExists(ctx, *req.Email)
-		if err != nil {
-			return nil, models.NewInternalServerError("Failed to check email", err)
-		}
-
-		// Get current user to compare emails
-		currentUser, err := s.queries.GetUserByID(ctx, id)
-		if err != nil {
-			return nil, models.NewInternalServerError("Failed to get user", err)
-		}
-
-		// Email exists and belongs to different user
-		if emailExists && currentUser.Email != *req.Email {
-			return nil, models.NewConflictError("Email already exists")
+	// Users may edit their own profile, but only users:write can change a status (their own included)
+	if req.Status != nil {
+		if caller, ok := auth.PrincipalFromContext(ctx); ok && !caller.HasPermission(auth.PermUsersWrite) {
+			return nil, models.NewForbiddenError(models.CodePermissionDenied, "You do not have permission to change a user's status")
 		}
 	}
 
 	// Build update parameters
 	params := database.UpdateUserParams{
-		UserID:    id,
-		FirstName: utils.ConvertStringPtrToText(req.FirstName),
-		LastName:  utils.ConvertStringPtrToText(req.LastName),
-		Email:     utils.ConvertStringPtrToText(req.Email),
-		Phone:     utils.ConvertStringPtrToText(req.Phone),
-		Age:       utils.ConvertIntPtrToInt4(req.Age),
+		OrgID:           orgID,
+		UserID:          id,
+		ExpectedVersion: utils.ConvertIntPtrToInt4(expectedVersion),
+		FirstName:       utils.ConvertStringPtrToText(req.FirstName),
+		LastName:        utils.ConvertStringPtrToText(req.LastName),
+		Email:           utils.ConvertStringPtrToText(req.Email),
+		Phone:           utils.ConvertStringPtrToText(req.Phone),
+		Age:             utils.ConvertIntPtrToInt4(req.Age),
 		Status: func() database.NullUserStatus {
 			if req.Status != nil {
 				return database.NullUserStatus{
@@ -153,33 +312,212 @@ func (s *UserService) UpdateUser(ctx context.Context, userID string, req models.
 		}(),
 	}
 
-	// Update in database
-	user, err := s.queries.UpdateUser(ctx, params)
+	// Update in database - the row is locked first so the audit event sees exactly what changed
+	var user database.User
+	err = s.withTx(ctx, func(q database.Querier) error {
+		before, err := lockActiveUser(ctx, q, orgID, id)
+		if err != nil {
+			return err
+		}
+
+		user, err = q.UpdateUser(ctx, params)
+		if err != nil {
+			// The row is locked and not deleted, so no row means the version didn't match
+			if errors.Is(err, pgx.ErrNoRows) {
+				return models.NewPreconditionFailedError(models.CodeVersionMismatch, "User has been modified since it was retrieved")
+			}
+			if isUniqueViolation(err) {
+				return models.NewConflictError(models.CodeUserEmailTaken, "Email already exists")
+			}
+			return models.NewInternalServerError("Failed to update user", err)
+		}
+
+		// A new address has to be confirmed - the update query already cleared email_verified_at,
+		// and issuing a token for the new address invalidates the links sent to the old one
+		if user.Email != before.Email {
+			if err := s.verification.queueVerification(ctx, q, user); err != nil {
+				return err
+			}
+		}
+
+		if err := recordUserEvent(ctx, q, audit.ActionUserUpdated, id, &before, &user); err != nil {
+			return err
+		}
+		return enqueueUserUpdatedEvent(ctx, q, before, user)
+	})
 	if err != nil {
-		return nil, models.NewInternalServerError("Failed to update user", err)
+		return nil, err
 	}
 
 	return utils.ConvertToUserResponse(user), nil
 }
 
-func (s *UserService) DeleteUser(ctx context.Context, userID string) error {
+// DeleteUser soft deletes a user - the row stays until the purge job removes it
+// expectedVersion works like in UpdateUser
+func (s *UserService) DeleteUser(ctx context.Context, userID string, expectedVersion *int) (err error) {
+	ctx, done := s.startOperation(ctx, "DeleteUser")
+	defer done(&err)
+	orgID, err := requestOrgID(ctx)
+	if err != nil {
+		return err
+	}
 	id, err := uuid.Parse(userID)
 	if err != nil {
-		return models.NewBadRequestError("Invalid user ID format")
+		return models.NewBadRequestError(models.CodeInvalidUserID, "Invalid user ID format")
+	}
+
+	err = s.withTx(ctx, func(q database.Querier) error {
+		before, err := lockActiveUser(ctx, q, orgID, id)
+		if err != nil {
+			return err
+		}
+
+		deleted, err := q.DeleteUser(ctx, database.DeleteUserParams{
+			OrgID:           orgID,
+			UserID:          id,
+			ExpectedVersion: utils.ConvertIntPtrToInt4(expectedVersion),
+		})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return models.NewPreconditionFailedError(models.CodeVersionMismatch, "User has been modified since it was retrieved")
+			}
+			return models.NewInternalServerError("Failed to delete user", err)
+		}
+
+		// A deleted user must not be able to keep refreshing tokens
+		if err := q.RevokeUserRefreshTokens(ctx, id); err != nil {
+			return models.NewInternalServerError("Failed to revoke user sessions", err)
+		}
+
+		// Deleted users leave their groups - in this transaction, so member lists never show a deleted user.
+		// Restoring the user doesn't bring the memberships back.
+		if _, err := q.DeleteUserGroupMemberships(ctx, id); err != nil {
+			return models.NewInternalServerError("Failed to remove user from groups", err)
+		}
+
+		if err := recordUserEvent(ctx, q, audit.ActionUserDeleted, id, &before, &deleted); err != nil {
+			return err
+		}
+		return enqueueUserEvent(ctx, q, events.TypeUserDeleted, orgID, id, events.UserDeleted{
+			UserID:    id,
+			DeletedAt: deleted.DeletedAt.Time,
+		})
+	})
+	if err != nil {
+		return err
+	}
+	logging.FromContext(ctx).Info("user deleted", "target_user_id", id)
+
+	return nil
+}
+
+// lockActiveUser locks a user row for the rest of the transaction, failing with 404 for missing or deleted users
+// and users of another organization
+func lockActiveUser(ctx context.Context, q database.Querier, orgID, id uuid.UUID) (database.User, error) {
+	user, err := q.GetUserForUpdate(ctx, database.GetUserForUpdateParams{OrgID: orgID, UserID: id})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return user, models.NewNotFoundError(models.CodeUserNotFound, "User not found")
+		}
+		return user, models.NewInternalServerError("Failed to get user", err)
+	}
+	if user.DeletedAt.Valid {
+		return user, models.NewNotFoundError(models.CodeUserNotFound, "User not found")
 	}
+	return user, nil
+}
 
-	exists, err := s.queries.UserExists(ctx, id)
+// RestoreUser undoes a soft delete
+func (s *UserService) RestoreUser(ctx context.Context, userID string) (_ *models.UserResponse, err error) {
+	ctx, done := s.startOperation(ctx, "RestoreUser")
+	defer done(&err)
+	orgID, err := requestOrgID(ctx)
 	if err != nil {
-		return models.NewInternalServerError("Failed to check user", err)
+		return nil, err
 	}
-	if !exists {
-		return models.NewNotFoundError("User not found")
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, models.NewBadRequestError(models.CodeInvalidUserID, "Invalid user ID format")
 	}
 
-	err = s.queries.DeleteUser(ctx, id)
+	var user database.User
+	err = s.withTx(ctx, func(q database.Querier) error {
+		before, err := q.GetUserForUpdate(ctx, database.GetUserForUpdateParams{OrgID: orgID, UserID: id})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return models.NewNotFoundError(models.CodeUserNotFound, "Deleted user not found")
+			}
+			return models.NewInternalServerError("Failed to get user", err)
+		}
+
+		user, err = q.RestoreUser(ctx, database.RestoreUserParams{OrgID: orgID, UserID: id})
+		if err != nil {
+			// Not deleted in the first place
+			if errors.Is(err, pgx.ErrNoRows) {
+				return models.NewNotFoundError(models.CodeUserNotFound, "Deleted user not found")
+			}
+			// The email may have been taken by a new user since the delete
+			if isUniqueViolation(err) {
+				return models.NewConflictError(models.CodeUserEmailTaken, "Email already exists")
+			}
+			return models.NewInternalServerError("Failed to restore user", err)
+		}
+
+		if err := recordUserEvent(ctx, q, audit.ActionUserRestored, id, &before, &user); err != nil {
+			return err
+		}
+		return enqueueUserEvent(ctx, q, events.TypeUserRestored, orgID, id, events.UserRestored{User: eventUser(user)})
+	})
 	if err != nil {
-		return models.NewInternalServerError("Failed to delete user", err)
+		return nil, err
 	}
+	logging.FromContext(ctx).Info("user restored", "target_user_id", id)
 
-	return nil
+	return utils.ConvertToUserResponse(user), nil
+}
+
+// PurgeDeletedUsers hard deletes users that were soft deleted longer than retention ago, in every organization
+func (s *UserService) PurgeDeletedUsers(ctx context.Context, retention time.Duration) (_ int64, err error) {
+	ctx, done := s.startOperation(ctx, "PurgeDeletedUsers")
+	defer done(&err)
+	cutoff := pgtype.Timestamptz{Time: time.Now().Add(-retention), Valid: true}
+
+	purged, err := s.queries.PurgeDeletedUsers(ctx, cutoff)
+	if err != nil {
+		return 0, models.NewInternalServerError("Failed to purge deleted users", err)
+	}
+
+	return purged, nil
+}
+
+// RunPurgeJob purges deleted users and expired verification tokens every interval until ctx is cancelled
+// Run it in its own goroutine
+func (s *UserService) RunPurgeJob(ctx context.Context, interval, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := s.PurgeDeletedUsers(ctx, retention)
+			if err != nil {
+				slog.Error("purge of deleted users failed", "error", err)
+				continue
+			}
+			if purged > 0 {
+				slog.Info("purged deleted users", "count", purged)
+			}
+
+			expired, err := s.verification.deleteExpiredTokens(ctx)
+			if err != nil {
+				slog.Error("cleanup of expired verification tokens failed", "error", err)
+				continue
+			}
+			if expired > 0 {
+				slog.Info("deleted expired verification tokens", "count", expired)
+			}
+		}
+	}
 }
@@ -0,0 +1,274 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"time"
+
+	database "user-management-api/db/sqlc"
+	"user-management-api/internal/audit"
+	"user-management-api/internal/auth"
+	"user-management-api/internal/logging"
+	"user-management-api/internal/mail"
+	"user-management-api/internal/models"
+	"user-management-api/internal/tenant"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// VerificationOptions configures email verification
+type VerificationOptions struct {
+	URL string        // the page the mailed link opens, the token is added as the token query parameter
+	TTL time.Duration // how long a token can be used
+}
+
+// VerificationService confirms that users own their email address by mailing them a single-use link
+type VerificationService struct {
+	pool      *pgxpool.Pool
+	queries   TxQuerier
+	txOptions TxOptions
+	mailer    mail.Mailer
+	templates *mail.Templates
+	opts      VerificationOptions
+}
+
+func NewVerificationService(pool *pgxpool.Pool, queries TxQuerier, txOptions TxOptions, mailer mail.Mailer, templates *mail.Templates, opts VerificationOptions) *VerificationService {
+	return &VerificationService{
+		pool:      pool,
+		queries:   queries,
+		txOptions: txOptions,
+		mailer:    mailer,
+		templates: templates,
+		opts:      opts,
+	}
+}
+
+func (s *VerificationService) withTx(ctx context.Context, fn func(q database.Querier) error) error {
+	return WithTx(ctx, s.pool, s.queries, s.txOptions, fn)
+}
+
+// verificationMail is a token waiting to be mailed - only send it once its transaction committed,
+// or queue it in the mail outbox with the transaction
+type verificationMail struct {
+	userID    uuid.UUID
+	firstName string
+	email     string
+	token     string
+	expiresAt time.Time
+}
+
+// issueToken replaces the outstanding tokens of a user, and the queued mails carrying them, with a new token for
+// their current address. Pass the transaction's queries. The token itself is only in the returned mail,
+// the database holds its hash.
+func (s *VerificationService) issueToken(ctx context.Context, q database.Querier, user database.User) (*verificationMail, error) {
+	// Same 256 random bits as refresh tokens, so a fast hash is fine here too
+	token, hash, err := auth.NewRefreshToken()
+	if err != nil {
+		return nil, models.NewInternalServerError("Failed to create verification token", err)
+	}
+	expiresAt := time.Now().Add(s.opts.TTL)
+
+	// Earlier links stop working, only the newest one is valid
+	if _, err := q.DeleteUserEmailVerificationTokens(ctx, user.UserID); err != nil {
+		return nil, models.NewInternalServerError("Failed to create verification token", err)
+	}
+	if _, err := q.DeleteUserOutboxMails(ctx, user.UserID); err != nil {
+		return nil, models.NewInternalServerError("Failed to create verification token", err)
+	}
+	_, err = q.CreateEmailVerificationToken(ctx, database.CreateEmailVerificationTokenParams{
+		UserID:    user.UserID,
+		OrgID:     user.OrgID,
+		Email:     user.Email,
+		TokenHash: hash,
+		ExpiresAt: pgtype.Timestamptz{Time: expiresAt, Valid: true},
+	})
+	if err != nil {
+		return nil, models.NewInternalServerError("Failed to create verification token", err)
+	}
+
+	return &verificationMail{
+		userID:    user.UserID,
+		firstName: user.FirstName,
+		email:     user.Email,
+		token:     token,
+		expiresAt: expiresAt,
+	}, nil
+}
+
+// queueVerification issues a token for a user and queues its mail in the mail outbox - pass the transaction's
+// queries, so the mail is only sent if the change is committed
+func (s *VerificationService) queueVerification(ctx context.Context, q database.Querier, user database.User) error {
+	v, err := s.issueToken(ctx, q, user)
+	if err != nil {
+		return err
+	}
+	msg, err := s.render(v)
+	if err != nil {
+		return models.NewInternalServerError("Failed to create verification email", err)
+	}
+
+	err = q.CreateOutboxMail(ctx, database.CreateOutboxMailParams{
+		OrgID:     user.OrgID,
+		UserID:    user.UserID,
+		Recipient: msg.To,
+		Subject:   msg.Subject,
+		TextBody:  msg.Text,
+		HtmlBody:  msg.HTML,
+	})
+	if err != nil {
+		return models.NewInternalServerError("Failed to queue verification email", err)
+	}
+	return nil
+}
+
+// render builds the mail with the verification link
+func (s *VerificationService) render(v *verificationMail) (mail.Message, error) {
+	link, err := url.Parse(s.opts.URL)
+	if err != nil {
+		return mail.Message{}, err
+	}
+	query := link.Query()
+	query.Set("token", v.token)
+	link.RawQuery = query.Encode()
+
+	return s.templates.Render(mail.TemplateVerifyEmail, v.email, mail.VerifyEmailData{
+		FirstName: v.firstName,
+		Email:     v.email,
+		Link:      link.String(),
+		Token:     v.token,
+		ExpiresAt: v.expiresAt,
+	})
+}
+
+// send mails the verification link right away
+func (s *VerificationService) send(ctx context.Context, v *verificationMail) error {
+	msg, err := s.render(v)
+	if err != nil {
+		return err
+	}
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		return err
+	}
+
+	logging.FromContext(ctx).Info("verification email sent", "target_user_id", v.userID)
+	return nil
+}
+
+// ResendVerification mails a new verification link to a user whose address isn't confirmed yet
+// Links sent earlier stop working.
+func (s *VerificationService) ResendVerification(ctx context.Context, userID string) error {
+	orgID, err := requestOrgID(ctx)
+	if err != nil {
+		return err
+	}
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return models.NewBadRequestError(models.CodeInvalidUserID, "Invalid user ID format")
+	}
+
+	var pending *verificationMail
+	err = s.withTx(ctx, func(q database.Querier) error {
+		user, err := lockActiveUser(ctx, q, orgID, id)
+		if err != nil {
+			return err
+		}
+		if user.EmailVerifiedAt.Valid {
+			return models.NewConflictError(models.CodeEmailAlreadyVerified, "Email address is already verified")
+		}
+
+		pending, err = s.issueToken(ctx, q, user)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := s.send(ctx, pending); err != nil {
+		return models.NewInternalServerError("Failed to send verification email", err)
+	}
+	return nil
+}
+
+// VerifyEmail confirms the address a token was mailed to and uses the token up
+// The token names the user, so it is checked in the organization of that user rather than the one the
+// request resolved to - the link works without naming an organization.
+func (s *VerificationService) VerifyEmail(ctx context.Context, req models.VerifyEmailRequest) (*models.VerifyEmailResponse, error) {
+	invalid := models.NewBadRequestError(models.CodeInvalidVerificationToken, "Invalid or expired verification token")
+
+	token, err := s.queries.GetEmailVerificationTokenByHash(ctx, auth.HashRefreshToken(req.Token))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, invalid
+		}
+		return nil, models.NewInternalServerError("Failed to get verification token", err)
+	}
+	if !token.ExpiresAt.Time.After(time.Now()) {
+		return nil, invalid
+	}
+	ctx = tenant.WithOrgID(ctx, token.OrgID)
+
+	var user database.User
+	err = s.withTx(ctx, func(q database.Querier) error {
+		// Deleting the token is what makes it single-use - a concurrent request with the same token finds nothing
+		consumed, err := q.ConsumeEmailVerificationToken(ctx, token.TokenID)
+		if err != nil {
+			return models.NewInternalServerError("Failed to use verification token", err)
+		}
+		if consumed == 0 {
+			return invalid
+		}
+
+		before, err := q.GetUserForUpdate(ctx, database.GetUserForUpdateParams{OrgID: token.OrgID, UserID: token.UserID})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return invalid
+			}
+			return models.NewInternalServerError("Failed to get user", err)
+		}
+
+		// No row when the user is deleted or the address changed after the token was sent
+		user, err = q.VerifyUserEmail(ctx, database.VerifyUserEmailParams{
+			OrgID:  token.OrgID,
+			UserID: token.UserID,
+			Email:  token.Email,
+		})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return invalid
+			}
+			return models.NewInternalServerError("Failed to verify email", err)
+		}
+
+		if _, err := q.DeleteUserEmailVerificationTokens(ctx, user.UserID); err != nil {
+			return models.NewInternalServerError("Failed to use verification token", err)
+		}
+
+		if err := recordUserEvent(ctx, q, audit.ActionUserEmailVerified, user.UserID, &before, &user); err != nil {
+			return err
+		}
+		return enqueueUserUpdatedEvent(ctx, q, before, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("email verified", "target_user_id", user.UserID)
+
+	return &models.VerifyEmailResponse{
+		UserID:          user.UserID,
+		Email:           user.Email,
+		EmailVerifiedAt: user.EmailVerifiedAt.Time,
+	}, nil
+}
+
+// deleteExpiredTokens removes the verification tokens that can't be used anymore, in every organization
+func (s *VerificationService) deleteExpiredTokens(ctx context.Context) (int64, error) {
+	deleted, err := s.queries.DeleteExpiredEmailVerificationTokens(ctx, pgtype.Timestamptz{Time: time.Now(), Valid: true})
+	if err != nil {
+		return 0, models.NewInternalServerError("Failed to delete expired verification tokens", err)
+	}
+	return deleted, nil
+}
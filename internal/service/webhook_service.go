@@ -0,0 +1,461 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	database "user-management-api/db/sqlc"
+	"user-management-api/internal/models"
+	"user-management-api/internal/utils"
+	"user-management-api/internal/webhooks"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// WebhookOptions controls how deliveries are sent and retried
+type WebhookOptions struct {
+	MaxAttempts int           // a delivery is dead-lettered after this many failed attempts
+	BackoffBase time.Duration // wait before the first retry, doubled for every following one
+	BackoffMax  time.Duration
+	BatchSize   int           // deliveries sent at once per dispatcher run
+	Timeout     time.Duration // per request - a delivery claimed by a dispatcher that died is retried after twice this
+}
+
+type WebhookService struct {
+	queries database.Querier
+	sender  *webhooks.Sender
+	options WebhookOptions
+}
+
+func NewWebhookService(queries database.Querier, sender *webhooks.Sender, options WebhookOptions) *WebhookService {
+	return &WebhookService{
+		queries: queries,
+		sender:  sender,
+		options: options,
+	}
+}
+
+// CreateWebhook adds a subscription - the secret is generated when the request has none
+// The response is the only place the secret is ever returned
+func (s *WebhookService) CreateWebhook(ctx context.Context, req models.CreateWebhookRequest) (*models.CreateWebhookResponse, error) {
+	orgID, err := requestOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		generated, err := generateWebhookSecret()
+		if err != nil {
+			return nil, models.NewInternalServerError("Failed to generate webhook secret", err)
+		}
+		secret = generated
+	}
+
+	active := true
+	if req.Active != nil {
+		active = *req.Active
+	}
+
+	eventTypes := req.EventTypes
+	if eventTypes == nil {
+		eventTypes = []string{} // the column is NOT NULL, empty means every event type
+	}
+
+	subscription, err := s.queries.CreateWebhookSubscription(ctx, database.CreateWebhookSubscriptionParams{
+		OrgID:       orgID,
+		Url:         req.URL,
+		Secret:      secret,
+		EventTypes:  eventTypes,
+		Description: utils.ConvertStringPtrToText(req.Description),
+		Active:      active,
+	})
+	if err != nil {
+		return nil, models.NewInternalServerError("Failed to create webhook", err)
+	}
+
+	return &models.CreateWebhookResponse{
+		WebhookResponse: convertWebhook(subscription),
+		Secret:          subscription.Secret,
+	}, nil
+}
+
+// ListWebhooks returns the subscriptions of the caller's organization
+func (s *WebhookService) ListWebhooks(ctx context.Context) (*models.ListWebhooksResponse, error) {
+	orgID, err := requestOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	subscriptions, err := s.queries.ListWebhookSubscriptions(ctx, orgID)
+	if err != nil {
+		return nil, models.NewInternalServerError("Failed to list webhooks", err)
+	}
+
+	response := &models.ListWebhooksResponse{
+		Webhooks: make([]models.WebhookResponse, len(subscriptions)),
+	}
+	for i, subscription := range subscriptions {
+		response.Webhooks[i] = convertWebhook(subscription)
+	}
+
+	return response, nil
+}
+
+func (s *WebhookService) GetWebhook(ctx context.Context, webhookID string) (*models.WebhookResponse, error) {
+	orgID, err := requestOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	id, err := parseWebhookID(webhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	subscription, err := s.getWebhook(ctx, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	response := convertWebhook(subscription)
+	return &response, nil
+}
+
+// getWebhook returns a subscription, failing with 404 for unknown ones and those of another organization
+func (s *WebhookService) getWebhook(ctx context.Context, orgID, id uuid.UUID) (database.WebhookSubscription, error) {
+	subscription, err := s.queries.GetWebhookSubscription(ctx, database.GetWebhookSubscriptionParams{
+		OrgID:          orgID,
+		SubscriptionID: id,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return subscription, models.NewNotFoundError(models.CodeWebhookNotFound, "Webhook not found")
+		}
+		return subscription, models.NewInternalServerError("Failed to get webhook", err)
+	}
+	return subscription, nil
+}
+
+func (s *WebhookService) UpdateWebhook(ctx context.Context, webhookID string, req models.UpdateWebhookRequest) (*models.WebhookResponse, error) {
+	orgID, err := requestOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	id, err := parseWebhookID(webhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	params := database.UpdateWebhookSubscriptionParams{
+		OrgID:          orgID,
+		SubscriptionID: id,
+		Url:            utils.ConvertStringPtrToText(req.URL),
+		Secret:         utils.ConvertStringPtrToText(req.Secret),
+		EventTypes:     req.EventTypes, // nil keeps the current types
+		Description:    utils.ConvertStringPtrToText(req.Description),
+	}
+	if req.Active != nil {
+		params.Active = pgtype.Bool{Bool: *req.Active, Valid: true}
+	}
+
+	subscription, err := s.queries.UpdateWebhookSubscription(ctx, params)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, models.NewNotFoundError(models.CodeWebhookNotFound, "Webhook not found")
+		}
+		return nil, models.NewInternalServerError("Failed to update webhook", err)
+	}
+
+	response := convertWebhook(subscription)
+	return &response, nil
+}
+
+// DeleteWebhook removes a subscription together with its delivery log
+func (s *WebhookService) DeleteWebhook(ctx context.Context, webhookID string) error {
+	orgID, err := requestOrgID(ctx)
+	if err != nil {
+		return err
+	}
+	id, err := parseWebhookID(webhookID)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := s.queries.DeleteWebhookSubscription(ctx, database.DeleteWebhookSubscriptionParams{
+		OrgID:          orgID,
+		SubscriptionID: id,
+	})
+	if err != nil {
+		return models.NewInternalServerError("Failed to delete webhook", err)
+	}
+	if deleted == 0 {
+		return models.NewNotFoundError(models.CodeWebhookNotFound, "Webhook not found")
+	}
+
+	return nil
+}
+
+// ListDeliveries returns a page of the delivery log of a subscription, newest first
+func (s *WebhookService) ListDeliveries(ctx context.Context, webhookID string, query models.ListWebhookDeliveriesQuery) (*models.ListWebhookDeliveriesResponse, error) {
+	orgID, err := requestOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	id, err := parseWebhookID(webhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	// An empty log and an unknown webhook look the same to the query, so check first
+	if _, err := s.getWebhook(ctx, orgID, id); err != nil {
+		return nil, err
+	}
+
+	params := database.ListWebhookDeliveriesParams{
+		OrgID:          orgID,
+		SubscriptionID: id,
+		Status:         utils.ConvertStringPtrToText(nonEmpty(query.Status)),
+		PageLimit:      int32(query.Limit + 1), // one extra row tells us whether there is a next page
+	}
+
+	if query.Cursor != "" {
+		cursor, err := decodeCursor(query.Cursor)
+		if err != nil || cursor.Sort != deliveryCursorSort {
+			return nil, models.NewBadRequestError(models.CodeInvalidCursor, "Invalid cursor")
+		}
+		createdAt, err := time.Parse(time.RFC3339Nano, cursor.Value)
+		if err != nil {
+			return nil, models.NewBadRequestError(models.CodeInvalidCursor, "Invalid cursor")
+		}
+		params.CursorCreatedAt = pgtype.Timestamptz{Time: createdAt, Valid: true}
+		params.CursorDeliveryID = pgtype.UUID{Bytes: cursor.ID, Valid: true}
+	}
+
+	deliveries, err := s.queries.ListWebhookDeliveries(ctx, params)
+	if err != nil {
+		return nil, models.NewInternalServerError("Failed to list webhook deliveries", err)
+	}
+
+	hasMore := len(deliveries) > query.Limit
+	if hasMore {
+		deliveries = deliveries[:query.Limit]
+	}
+
+	response := &models.ListWebhookDeliveriesResponse{
+		Deliveries: make([]models.WebhookDeliveryResponse, len(deliveries)),
+		HasMore:    hasMore,
+	}
+	for i, delivery := range deliveries {
+		response.Deliveries[i] = convertWebhookDelivery(delivery)
+	}
+	if hasMore {
+		last := deliveries[len(deliveries)-1]
+		response.NextCursor = encodeCursor(listCursor{
+			Sort:  deliveryCursorSort,
+			Value: last.CreatedAt.Time.Format(time.RFC3339Nano),
+			ID:    last.DeliveryID,
+		})
+	}
+
+	return response, nil
+}
+
+// deliveryCursorSort marks delivery log cursors so they can't be mixed up with other cursors
+const deliveryCursorSort = "webhook_deliveries"
+
+// ReplayDelivery queues a new delivery of the same event, for example after fixing a dead-lettered subscriber
+func (s *WebhookService) ReplayDelivery(ctx context.Context, webhookID, deliveryID string) (*models.WebhookDeliveryResponse, error) {
+	orgID, err := requestOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	id, err := parseWebhookID(webhookID)
+	if err != nil {
+		return nil, err
+	}
+	delivery, err := uuid.Parse(deliveryID)
+	if err != nil {
+		return nil, models.NewBadRequestError(models.CodeInvalidDeliveryID, "Invalid delivery ID format")
+	}
+
+	replay, err := s.queries.ReplayWebhookDelivery(ctx, database.ReplayWebhookDeliveryParams{
+		OrgID:          orgID,
+		DeliveryID:     delivery,
+		SubscriptionID: id,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, models.NewNotFoundError(models.CodeDeliveryNotFound, "Delivery not found")
+		}
+		return nil, models.NewInternalServerError("Failed to replay delivery", err)
+	}
+
+	response := convertWebhookDelivery(replay)
+	return &response, nil
+}
+
+// DispatchDue sends the deliveries that are due and returns how many were attempted
+// Deliveries of one batch are sent concurrently.
+func (s *WebhookService) DispatchDue(ctx context.Context) (int, error) {
+	// The lease keeps other dispatchers away while the batch is being sent
+	deliveries, err := s.queries.ClaimDueWebhookDeliveries(ctx, database.ClaimDueWebhookDeliveriesParams{
+		LeaseUntil: pgtype.Timestamptz{Time: time.Now().Add(2 * s.options.Timeout), Valid: true},
+		BatchSize:  int32(s.options.BatchSize),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func(delivery database.WebhookDelivery) {
+			defer wg.Done()
+			if err := s.attempt(ctx, delivery); err != nil {
+				slog.Error("recording webhook delivery failed", "delivery_id", delivery.DeliveryID, "error", err)
+			}
+		}(delivery)
+	}
+	wg.Wait()
+
+	return len(deliveries), nil
+}
+
+// attempt sends one delivery and records the outcome
+func (s *WebhookService) attempt(ctx context.Context, delivery database.WebhookDelivery) error {
+	params := database.RecordWebhookDeliveryAttemptParams{
+		DeliveryID:    delivery.DeliveryID,
+		Status:        models.WebhookDeliverySucceeded,
+		NextAttemptAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	}
+
+	// The subscription is read per attempt so URL and secret changes apply to pending retries
+	var statusCode int
+	subscription, err := s.queries.GetWebhookSubscription(ctx, database.GetWebhookSubscriptionParams{
+		OrgID:          delivery.OrgID,
+		SubscriptionID: delivery.SubscriptionID,
+	})
+	if err == nil {
+		statusCode, err = s.sender.Send(ctx, webhooks.Delivery{
+			ID:        delivery.DeliveryID.String(),
+			EventType: delivery.EventType,
+			URL:       subscription.Url,
+			Secret:    subscription.Secret,
+			Body:      delivery.Payload,
+		})
+	}
+
+	if statusCode != 0 {
+		params.LastStatusCode = pgtype.Int4{Int32: int32(statusCode), Valid: true}
+	}
+	if err != nil {
+		attempts := int(delivery.Attempts) + 1
+		params.LastError = pgtype.Text{String: err.Error(), Valid: true}
+
+		if attempts >= s.options.MaxAttempts {
+			params.Status = models.WebhookDeliveryDead
+			slog.Warn("webhook delivery dead-lettered", "delivery_id", delivery.DeliveryID, "attempts", attempts, "error", err)
+		} else {
+			params.Status = models.WebhookDeliveryPending
+			params.NextAttemptAt.Time = time.Now().Add(webhooks.Backoff(attempts, s.options.BackoffBase, s.options.BackoffMax))
+		}
+	}
+
+	return s.queries.RecordWebhookDeliveryAttempt(ctx, params)
+}
+
+// RunDispatcher sends due deliveries every interval until ctx is cancelled
+// Run it in its own goroutine
+func (s *WebhookService) RunDispatcher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// Keep going while batches come back full, so a backlog drains without waiting for the ticker
+			for {
+				sent, err := s.DispatchDue(ctx)
+				if err != nil {
+					slog.Error("webhook dispatch failed", "error", err)
+					break
+				}
+				if sent < s.options.BatchSize {
+					break
+				}
+			}
+		}
+	}
+}
+
+// enqueueWebhookDeliveries queues an outbox event for every subscription of its organization that wants it - pass
+// the transaction's queries
+func enqueueWebhookDeliveries(ctx context.Context, q database.Querier, orgID, eventID uuid.UUID, eventType string, payload []byte) error {
+	_, err := q.CreateWebhookDeliveries(ctx, database.CreateWebhookDeliveriesParams{
+		OrgID:     orgID,
+		EventID:   eventID,
+		EventType: eventType,
+		Payload:   payload,
+	})
+	if err != nil {
+		return models.NewInternalServerError("Failed to queue webhook deliveries", err)
+	}
+	return nil
+}
+
+// generateWebhookSecret returns a random 256-bit secret
+func generateWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+func parseWebhookID(webhookID string) (uuid.UUID, error) {
+	id, err := uuid.Parse(webhookID)
+	if err != nil {
+		return id, models.NewBadRequestError(models.CodeInvalidWebhookID, "Invalid webhook ID format")
+	}
+	return id, nil
+}
+
+func convertWebhook(subscription database.WebhookSubscription) models.WebhookResponse {
+	return models.WebhookResponse{
+		ID:          subscription.SubscriptionID,
+		URL:         subscription.Url,
+		EventTypes:  subscription.EventTypes,
+		Description: utils.ConvertTextToStringPtr(subscription.Description),
+		Active:      subscription.Active,
+		CreatedAt:   subscription.CreatedAt.Time,
+		UpdatedAt:   subscription.UpdatedAt.Time,
+	}
+}
+
+func convertWebhookDelivery(delivery database.WebhookDelivery) models.WebhookDeliveryResponse {
+	response := models.WebhookDeliveryResponse{
+		ID:             delivery.DeliveryID,
+		WebhookID:      delivery.SubscriptionID,
+		EventID:        delivery.EventID,
+		EventType:      delivery.EventType,
+		Status:         delivery.Status,
+		Attempts:       int(delivery.Attempts),
+		LastAttemptAt:  utils.ConvertTimestamptzToTimePtr(delivery.LastAttemptAt),
+		LastStatusCode: utils.ConvertInt4ToIntPtr(delivery.LastStatusCode),
+		LastError:      utils.ConvertTextToStringPtr(delivery.LastError),
+		CreatedAt:      delivery.CreatedAt.Time,
+	}
+	if delivery.Status == models.WebhookDeliveryPending {
+		response.NextAttemptAt = utils.ConvertTimestamptzToTimePtr(delivery.NextAttemptAt)
+	}
+	return response
+}
@@ -0,0 +1,34 @@
+package tenant
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// settingKey is where a connection remembers the organization app.org_id is set to
+const settingKey = "tenant.org_id"
+
+// PrepareConn is a pgxpool PrepareConn hook that sets app.org_id, which the row-level security policies check,
+// to the organization of ctx on every connection taken from the pool - reads outside of transactions included.
+// Without an organization it is set to "", and the policies hide every row.
+func PrepareConn(ctx context.Context, conn *pgx.Conn) (bool, error) {
+	orgID := ""
+	if id, ok := OrgIDFromContext(ctx); ok {
+		orgID = id.String()
+	}
+
+	// Connections remember the setting, so only switching organizations costs a round trip
+	data := conn.PgConn().CustomData()
+	if current, ok := data[settingKey]; ok && current == orgID {
+		return true, nil
+	}
+
+	if _, err := conn.Exec(ctx, "SELECT set_config('app.org_id', $1, false)", orgID); err != nil {
+		// The setting of the connection is unknown now, so it is closed rather than reused
+		return false, err
+	}
+	data[settingKey] = orgID
+
+	return true, nil
+}
@@ -0,0 +1,23 @@
+// Package tenant carries the organization a request acts in
+package tenant
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// unexported key type so no other package can overwrite the organization in the context
+type orgKey struct{}
+
+// WithOrgID returns a copy of ctx acting in the organization
+func WithOrgID(ctx context.Context, orgID uuid.UUID) context.Context {
+	return context.WithValue(ctx, orgKey{}, orgID)
+}
+
+// OrgIDFromContext returns the organization of the request, set by middleware.Tenant
+// ok is false outside of a request, for example in background jobs.
+func OrgIDFromContext(ctx context.Context) (uuid.UUID, bool) {
+	orgID, ok := ctx.Value(orgKey{}).(uuid.UUID)
+	return orgID, ok
+}
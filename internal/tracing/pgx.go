@@ -0,0 +1,29 @@
+package tracing
+
+import (
+	"strings"
+
+	"github.com/exaring/otelpgx"
+)
+
+// NewPgxTracer creates a client span for every query - set it as pgx.ConnConfig.Tracer
+// Queries generated by sqlc start with "-- name: GetUserByID :one", that name becomes the span name.
+// No rows is an expected outcome (404s), otelpgx doesn't count it as a failed query.
+func NewPgxTracer() *otelpgx.Tracer {
+	return otelpgx.NewTracer(otelpgx.WithSpanNameFunc(queryName))
+}
+
+// queryName returns the sqlc query name, or the SQL command ("SELECT", "BEGIN", ...) for other statements
+func queryName(sql string) string {
+	sql = strings.TrimSpace(sql)
+	if rest, ok := strings.CutPrefix(sql, "-- name: "); ok {
+		if fields := strings.Fields(rest); len(fields) > 0 {
+			return fields[0]
+		}
+	}
+
+	if fields := strings.Fields(sql); len(fields) > 0 {
+		return strings.ToUpper(fields[0])
+	}
+	return "query"
+}
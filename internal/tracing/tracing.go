@@ -0,0 +1,43 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.43.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Spans are OpenTelemetry spans, so they can be sent to any OTLP collector (Jaeger, Tempo, the
+// OpenTelemetry Collector, ...) and joined with traces of other services through the W3C traceparent header.
+
+// instrumentationName names the tracer of the spans this service creates itself
+const instrumentationName = "user-management-api"
+
+// NewProvider creates the tracer provider of the service - a nil exporter still creates spans, so logs and
+// error responses get trace IDs, but exports nothing
+// Spans are exported in batches, call Shutdown on the provider before the process exits to send the last ones.
+func NewProvider(exporter sdktrace.SpanExporter, serviceName string) *sdktrace.TracerProvider {
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName))),
+	}
+	if exporter != nil {
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+	return sdktrace.NewTracerProvider(opts...)
+}
+
+// SetDefault makes provider the one Start, the HTTP middleware and the pgx tracer use, and propagates
+// traces with the W3C traceparent header - call it once at startup
+func SetDefault(provider trace.TracerProvider) {
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+}
+
+// Start begins a span as a child of the span in ctx - always end the returned span
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, opts...)
+}
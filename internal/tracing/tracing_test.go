@@ -0,0 +1,65 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestQueryName(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"-- name: GetUserByID :one\nSELECT * FROM users WHERE user_id = $1", "GetUserByID"},
+		{"  -- name: ListUsers :many\nSELECT 1", "ListUsers"},
+		{"select set_config('app.org_id', $1, false)", "SELECT"},
+		{"begin", "BEGIN"},
+		{"", "query"},
+	}
+	for _, tt := range tests {
+		if got := queryName(tt.sql); got != tt.want {
+			t.Errorf("queryName(%q) = %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestStart(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	SetDefault(provider)
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+
+	ctx, parent := Start(context.Background(), "parent")
+	_, child := Start(ctx, "child")
+	child.End()
+	parent.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("exported %d spans, want 2", len(spans))
+	}
+	if spans[0].Name != "child" || spans[1].Name != "parent" {
+		t.Errorf("span names are %q and %q, want child and parent", spans[0].Name, spans[1].Name)
+	}
+	if spans[0].Parent.SpanID() != spans[1].SpanContext.SpanID() {
+		t.Error("child span is not a child of the parent span")
+	}
+	if spans[0].SpanContext.TraceID() != spans[1].SpanContext.TraceID() {
+		t.Error("child span is in another trace")
+	}
+}
+
+func TestNewProvider_WithoutExporter(t *testing.T) {
+	provider := NewProvider(nil, "test")
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+
+	// Spans still get IDs for logs and error responses
+	_, span := provider.Tracer("test").Start(context.Background(), "span")
+	defer span.End()
+	if !span.SpanContext().IsValid() {
+		t.Error("span of a provider without exporter has no IDs")
+	}
+}
@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"time"
+
 	database "user-management-api/db/sqlc"
 	"user-management-api/internal/models"
 
@@ -10,15 +12,19 @@ import (
 // ConvertToUserResponse converts database user to API response
 func ConvertToUserResponse(user database.User) *models.UserResponse {
 	return &models.UserResponse{
-		UserID:    user.UserID,
-		FirstName: user.FirstName,
-		LastName:  user.LastName,
-		Email:     user.Email,
-		Phone:     ConvertTextToStringPtr(user.Phone),
-		Age:       ConvertInt4ToIntPtr(user.Age),
-		Status:    models.UserStatus(user.Status),
-		CreatedAt: user.CreatedAt.Time,
-		UpdatedAt: user.UpdatedAt.Time,
+		UserID:          user.UserID,
+		OrgID:           user.OrgID,
+		FirstName:       user.FirstName,
+		LastName:        user.LastName,
+		Email:           user.Email,
+		EmailVerifiedAt: ConvertTimestamptzToTimePtr(user.EmailVerifiedAt),
+		Phone:           ConvertTextToStringPtr(user.Phone),
+		Age:             ConvertInt4ToIntPtr(user.Age),
+		Status:          models.UserStatus(user.Status),
+		CreatedAt:       user.CreatedAt.Time,
+		UpdatedAt:       user.UpdatedAt.Time,
+		DeletedAt:       ConvertTimestamptzToTimePtr(user.DeletedAt),
+		Version:         int(user.Version),
 	}
 }
 
@@ -54,3 +60,19 @@ func ConvertInt4ToIntPtr(i pgtype.Int4) *int {
 	val := int(i.Int32)
 	return &val
 }
+
+// ConvertTimestamptzToTimePtr converts pgtype.Timestamptz to *time.Time
+func ConvertTimestamptzToTimePtr(t pgtype.Timestamptz) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+// ConvertTimePtrToTimestamptz converts *time.Time to pgtype.Timestamptz
+func ConvertTimePtrToTimestamptz(t *time.Time) pgtype.Timestamptz {
+	if t == nil {
+		return pgtype.Timestamptz{Valid: false}
+	}
+	return pgtype.Timestamptz{Time: *t, Valid: true}
+}
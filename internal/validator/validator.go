@@ -2,9 +2,13 @@ package validator
 
 import (
 	"fmt"
+	"reflect"
+	"regexp"
 	"strings"
+	"unicode"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
 )
 
 // wrapper around the validator library - so that we can easily swap it out later if needed
@@ -14,8 +18,23 @@ type Validator struct {
 
 // creating the validator instance
 func NewValidator() *Validator {
+	validate := validator.New()
+
+	// Report errors using the json name of the field (what the client actually sent)
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" || name == "" {
+			return field.Name
+		}
+		return name
+	})
+
+	// Custom rules - registering can only fail for an empty tag name
+	_ = validate.RegisterValidation("password", validatePassword)
+	_ = validate.RegisterValidation("slug", validateSlug)
+
 	return &Validator{
-		validate: validator.New(),
+		validate: validate,
 	}
 }
 
@@ -54,20 +73,98 @@ func formatValidationError(fe validator.FieldError) string {
 	case "email":
 		return fmt.Sprintf("%s must be a valid email address", field)
 	case "min":
+		if isNumber(fe.Kind()) {
+			return fmt.Sprintf("%s must be at least %s", field, fe.Param())
+		}
 		return fmt.Sprintf("%s must be at least %s characters", field, fe.Param())
 	case "max":
+		if isNumber(fe.Kind()) {
+			return fmt.Sprintf("%s must not exceed %s", field, fe.Param())
+		}
 		return fmt.Sprintf("%s must not exceed %s characters", field, fe.Param())
 	case "gt":
 		return fmt.Sprintf("%s must be greater than %s", field, fe.Param())
 	case "e164":
 		return fmt.Sprintf("%s must be a valid phone number in E.164 format", field)
+	case "http_url":
+		return fmt.Sprintf("%s must be an http or https URL", field)
 	case "oneof":
 		return fmt.Sprintf("%s must be one of: %s", field, fe.Param())
+	case "password":
+		return fmt.Sprintf(
+			"%s must be %d-%d characters and contain at least three of: lowercase letters, uppercase letters, digits, symbols",
+			field, minPasswordLength, maxPasswordLength,
+		)
+	case "slug":
+		return fmt.Sprintf("%s must be 1-63 lowercase letters, digits or hyphens, not starting or ending with a hyphen", field)
 	default:
 		return fmt.Sprintf("%s is invalid", field)
 	}
 }
 
+// Password length limits - bcrypt ignores everything after 72 bytes
+const (
+	minPasswordLength = 8
+	maxPasswordLength = 72
+)
+
+// validatePassword checks password strength: length, and at least three character classes
+func validatePassword(fl validator.FieldLevel) bool {
+	password := fl.Field().String()
+	if len(password) < minPasswordLength || len(password) > maxPasswordLength {
+		return false
+	}
+
+	var hasLower, hasUpper, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		default:
+			hasSymbol = true
+		}
+	}
+
+	classes := 0
+	for _, has := range []bool{hasLower, hasUpper, hasDigit, hasSymbol} {
+		if has {
+			classes++
+		}
+	}
+
+	return classes >= 3
+}
+
+// slugPattern is a DNS label in lower case, so every slug can be used as a subdomain
+var slugPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// validateSlug checks an organization slug - slugs that parse as a UUID are rejected,
+// they couldn't be told apart from an organization ID
+func validateSlug(fl validator.FieldLevel) bool {
+	slug := fl.Field().String()
+	if !slugPattern.MatchString(slug) {
+		return false
+	}
+	_, err := uuid.Parse(slug)
+	return err != nil
+}
+
+// isNumber reports whether min/max apply to a value rather than a length
+func isNumber(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
 func firstCharToLowercase(s string) string {
 	if len(s) == 0 {
 		return s
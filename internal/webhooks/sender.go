@@ -0,0 +1,133 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Delivery is one signed HTTP callback
+type Delivery struct {
+	ID        string
+	EventType string
+	URL       string
+	Secret    string
+	Body      []byte
+}
+
+// Sender posts deliveries to subscribers
+type Sender struct {
+	client *http.Client
+}
+
+// ErrBlockedAddress is returned for subscriber URLs that resolve to an address deliveries may not go to
+var ErrBlockedAddress = errors.New("webhook target address is not allowed")
+
+// NewSender creates a sender whose requests give up after timeout
+// Subscriber URLs are chosen by API clients, so unless allowPrivateNetworks is set deliveries only go to public
+// addresses - not to loopback, private or link-local ones such as the database or a cloud metadata endpoint.
+// The address is checked when connecting, after DNS resolution, so a name can't be pointed at one later.
+// Redirects are not followed, a redirect response fails the delivery like any other non-2xx.
+func NewSender(timeout time.Duration, allowPrivateNetworks bool) *Sender {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivateNetworks {
+		dialer.Control = checkAddress
+	}
+
+	return NewSenderWithClient(&http.Client{
+		Timeout: timeout,
+		// No proxy from the environment, it would be the one address the check sees
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConnsPerHost: 4,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	})
+}
+
+// NewSenderWithClient creates a sender using client as it is, e.g. the client of an httptest.Server
+// It doesn't get the address checks and the redirect policy of NewSender.
+func NewSenderWithClient(client *http.Client) *Sender {
+	return &Sender{client: client}
+}
+
+// Send posts a delivery and returns the response status code
+// Any 2xx response is a success - everything else, including redirects, is an error to retry.
+// The status code is 0 when no response was received.
+func (s *Sender) Send(ctx context.Context, d Delivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Body))
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "user-management-api-webhooks/1.0")
+	req.Header.Set(HeaderID, d.ID)
+	req.Header.Set(HeaderEvent, d.EventType)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(HeaderSignature, Sign(d.Secret, now, d.Body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// Drain a little of the body so the connection can be reused, receivers have nothing to tell us
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("subscriber responded with %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// checkAddress is a net.Dialer Control func rejecting connections to addresses that aren't public
+func checkAddress(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, address)
+	}
+
+	addr := addrPort.Addr().Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() || addr.IsUnspecified() || sharedAddressSpace.Contains(addr) {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, addr)
+	}
+	return nil
+}
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), private to a provider's network
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// Backoff returns how long to wait before retry number attempt (1 for the first retry)
+// The wait doubles with each attempt up to max, then jitter picks a random wait between half and all of it
+// so subscribers coming back from an outage aren't hit by every retry at once.
+func Backoff(attempt int, base, max time.Duration) time.Duration {
+	wait := base
+	for i := 1; i < attempt && wait < max; i++ {
+		wait *= 2
+	}
+	if wait > max {
+		wait = max
+	}
+	if wait <= 0 {
+		return 0
+	}
+
+	return wait/2 + rand.N(wait/2+1)
+}
@@ -0,0 +1,175 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newDelivery(url string) Delivery {
+	return Delivery{
+		ID:        "0b9f6a52-6c1e-4a8e-9d43-4f1a3c1f7e21",
+		EventType: "UserCreated",
+		URL:       url,
+		Secret:    "0123456789abcdef0123456789abcdef",
+		Body:      []byte(`{"type":"UserCreated"}`),
+	}
+}
+
+func TestSend_SignsDelivery(t *testing.T) {
+	delivery := newDelivery("")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := Verify(delivery.Secret, r.Header.Get(HeaderTimestamp), r.Header.Get(HeaderSignature), body, time.Minute); err != nil {
+			t.Errorf("signature doesn't verify: %v", err)
+		}
+		if got := r.Header.Get(HeaderID); got != delivery.ID {
+			t.Errorf("%s is %q, want %q", HeaderID, got, delivery.ID)
+		}
+		if got := r.Header.Get(HeaderEvent); got != delivery.EventType {
+			t.Errorf("%s is %q, want %q", HeaderEvent, got, delivery.EventType)
+		}
+		if string(body) != string(delivery.Body) {
+			t.Errorf("body is %s, want %s", body, delivery.Body)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	delivery.URL = server.URL
+
+	status, err := NewSenderWithClient(server.Client()).Send(context.Background(), delivery)
+	if err != nil || status != http.StatusNoContent {
+		t.Fatalf("Send returned %d, %v - want 204 without error", status, err)
+	}
+
+	// A receiver with another secret rejects the delivery
+	now := time.Now()
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	if err := Verify("another secret of 32 characters!", timestamp, Sign(delivery.Secret, now, delivery.Body), delivery.Body, time.Minute); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Verify with another secret returned %v, want ErrInvalidSignature", err)
+	}
+}
+
+func TestSend_RetriesUntilSubscriberRecovers(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Down for the first two attempts
+		if calls.Add(1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	sender := NewSenderWithClient(server.Client())
+	delivery := newDelivery(server.URL)
+	for attempt := 1; attempt <= 2; attempt++ {
+		status, err := sender.Send(context.Background(), delivery)
+		if err == nil || status != http.StatusServiceUnavailable {
+			t.Fatalf("attempt %d returned %d, %v - want 503 with an error", attempt, status, err)
+		}
+	}
+	status, err := sender.Send(context.Background(), delivery)
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("third attempt returned %d, %v - want 200 without error", status, err)
+	}
+}
+
+func TestNewSender_BlocksPrivateAddresses(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer server.Close()
+
+	// httptest listens on loopback
+	status, err := NewSender(time.Second, false).Send(context.Background(), newDelivery(server.URL))
+	if !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("Send to loopback returned %d, %v - want ErrBlockedAddress", status, err)
+	}
+	if calls.Load() != 0 {
+		t.Error("blocked delivery reached the server")
+	}
+
+	status, err = NewSender(time.Second, true).Send(context.Background(), newDelivery(server.URL))
+	if err != nil || status != http.StatusOK {
+		t.Errorf("Send with private networks allowed returned %d, %v - want 200", status, err)
+	}
+}
+
+func TestNewSender_DoesNotFollowRedirects(t *testing.T) {
+	var followed atomic.Bool
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hook", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/internal", http.StatusTemporaryRedirect)
+	})
+	mux.HandleFunc("/internal", func(w http.ResponseWriter, r *http.Request) {
+		followed.Store(true)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	status, err := NewSender(time.Second, true).Send(context.Background(), newDelivery(server.URL+"/hook"))
+	if err == nil || status != http.StatusTemporaryRedirect {
+		t.Errorf("Send returned %d, %v - want 307 with an error", status, err)
+	}
+	if followed.Load() {
+		t.Error("redirect was followed")
+	}
+}
+
+func TestCheckAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		blocked bool
+	}{
+		{"127.0.0.1:80", true},
+		{"10.1.2.3:443", true},
+		{"172.16.0.1:443", true},
+		{"192.168.1.10:8080", true},
+		{"169.254.169.254:80", true}, // cloud metadata endpoint
+		{"100.64.0.1:443", true},
+		{"0.0.0.0:80", true},
+		{"[::1]:80", true},
+		{"[fe80::1]:80", true},
+		{"[fd00::1]:443", true},
+		{"[::ffff:127.0.0.1]:80", true},
+		{"93.184.215.14:443", false},
+		{"[2606:4700:4700::1111]:443", false},
+	}
+	for _, tt := range tests {
+		err := checkAddress("tcp", tt.address, nil)
+		if blocked := errors.Is(err, ErrBlockedAddress); blocked != tt.blocked {
+			t.Errorf("checkAddress(%s) = %v, want blocked %v", tt.address, err, tt.blocked)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	base, max := 10*time.Second, time.Minute
+	tests := []struct {
+		attempt int
+		full    time.Duration
+	}{
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{3, 40 * time.Second},
+		{4, time.Minute},
+		{10, time.Minute},
+	}
+	for _, tt := range tests {
+		for range 20 {
+			// Jitter picks a wait between half and all of the full wait
+			if wait := Backoff(tt.attempt, base, max); wait < tt.full/2 || wait > tt.full {
+				t.Errorf("Backoff(%d) = %v, want between %v and %v", tt.attempt, wait, tt.full/2, tt.full)
+			}
+		}
+	}
+}
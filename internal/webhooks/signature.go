@@ -0,0 +1,61 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers sent with every delivery
+const (
+	HeaderID        = "X-Webhook-Id"        // delivery ID, the same on every retry of a delivery
+	HeaderEvent     = "X-Webhook-Event"     // event type, e.g. UserCreated
+	HeaderTimestamp = "X-Webhook-Timestamp" // Unix seconds when the request was signed
+	HeaderSignature = "X-Webhook-Signature" // "sha256=" + hex HMAC-SHA256 of "<timestamp>.<body>"
+)
+
+const signaturePrefix = "sha256="
+
+var (
+	ErrInvalidSignature = errors.New("webhook signature does not match")
+	ErrTimestampTooOld  = errors.New("webhook timestamp is outside the tolerance")
+)
+
+// Sign returns the signature header value for a body sent at timestamp
+// The timestamp is part of the signed message so a captured request can't be replayed later
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the timestamp and signature headers of a received delivery - this is what receivers should do
+// Requests signed more than tolerance ago (or in the future) are rejected.
+func Verify(secret, timestampHeader, signatureHeader string, body []byte, tolerance time.Duration) error {
+	unix, err := strconv.ParseInt(timestampHeader, 10, 64)
+	if err != nil {
+		return ErrTimestampTooOld
+	}
+	timestamp := time.Unix(unix, 0)
+
+	age := time.Since(timestamp)
+	if age > tolerance || age < -tolerance {
+		return ErrTimestampTooOld
+	}
+
+	if !strings.HasPrefix(signatureHeader, signaturePrefix) {
+		return ErrInvalidSignature
+	}
+	// Constant time compare so the signature can't be guessed byte by byte
+	if !hmac.Equal([]byte(signatureHeader), []byte(Sign(secret, timestamp, body))) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
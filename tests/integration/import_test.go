@@ -0,0 +1,414 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"user-management-api/internal/mail"
+	"user-management-api/internal/models"
+	"user-management-api/internal/service"
+	"user-management-api/internal/validator"
+
+	"github.com/google/uuid"
+)
+
+// importFile builds a CSV file with one row per email
+func importFile(firstName string, emails ...string) []byte {
+	var b strings.Builder
+	b.WriteString("first_name,last_name,email\n")
+	for _, email := range emails {
+		b.WriteString(firstName + ",Imported," + email + "\n")
+	}
+	return []byte(b.String())
+}
+
+// newEmails returns n unused addresses
+func newEmails(t *testing.T, n int) []string {
+	t.Helper()
+	emails := make([]string, n)
+	for i := range emails {
+		emails[i] = "import-" + randomHex(t) + "@example.com"
+	}
+	return emails
+}
+
+// createUserWithEmail adds a user with a known address, named "Existing"
+func createUserWithEmail(t *testing.T, ctx context.Context, s *services, email string) *models.UserResponse {
+	t.Helper()
+	user, err := s.users.CreateUser(ctx, models.CreateUserRequest{FirstName: "Existing", LastName: "User", Email: email})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+// rowStatuses maps the email of every row of a report to its status
+func rowStatuses(rows []models.ImportRowResult) map[string]string {
+	statuses := make(map[string]string, len(rows))
+	for _, row := range rows {
+		statuses[row.Email] = row.Status
+	}
+	return statuses
+}
+
+// firstNames returns the first name of the active users with the emails, by email
+func firstNames(t *testing.T, orgID uuid.UUID, emails ...string) map[string]string {
+	t.Helper()
+	rows, err := db.admin.Query(context.Background(),
+		"SELECT email, first_name FROM users WHERE org_id = $1 AND email = ANY($2) AND deleted_at IS NULL", orgID, emails)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	names := make(map[string]string)
+	for rows.Next() {
+		var email, name string
+		if err := rows.Scan(&email, &name); err != nil {
+			t.Fatal(err)
+		}
+		names[email] = name
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return names
+}
+
+// countVerification counts the verification tokens and the queued mails of the users with the emails
+func countVerification(t *testing.T, orgID uuid.UUID, emails ...string) (tokens, mails int) {
+	t.Helper()
+	err := db.admin.QueryRow(context.Background(), `
+		SELECT
+			(SELECT count(*) FROM email_verification_tokens WHERE org_id = $1 AND email = ANY($2)),
+			(SELECT count(*) FROM mail_outbox WHERE org_id = $1 AND recipient = ANY($2))`,
+		orgID, emails).Scan(&tokens, &mails)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tokens, mails
+}
+
+func TestImport_DryRun(t *testing.T) {
+	s := newServices(t)
+	orgID, ctx := newOrg(t)
+
+	emails := newEmails(t, 2)
+	existing, fresh := emails[0], emails[1]
+	createUserWithEmail(t, ctx, s, existing)
+
+	for _, onConflict := range []string{models.OnConflictSkip, models.OnConflictUpdate, models.OnConflictFail} {
+		t.Run(onConflict, func(t *testing.T) {
+			file := importFile("New", existing, fresh, "not-an-email")
+			report, _, err := s.imports.ImportUsers(ctx, models.ImportUsersQuery{Format: "csv", DryRun: true, OnConflict: onConflict}, file)
+			if err != nil {
+				t.Fatalf("import: %v", err)
+			}
+			if !report.DryRun || report.Total != 3 {
+				t.Errorf("report is dry run %v with %d rows, want a dry run with 3", report.DryRun, report.Total)
+			}
+
+			want := map[string]string{
+				models.OnConflictSkip:   models.ImportRowSkipped,
+				models.OnConflictUpdate: models.ImportRowUpdated,
+				models.OnConflictFail:   models.ImportRowFailed,
+			}[onConflict]
+			statuses := rowStatuses(report.Rows)
+			if statuses[existing] != want {
+				t.Errorf("existing email is %q, want %q", statuses[existing], want)
+			}
+			if statuses["not-an-email"] != models.ImportRowFailed {
+				t.Errorf("invalid row is %q, want failed", statuses["not-an-email"])
+			}
+			// on_conflict=fail reports the rows it wouldn't import because of the failed ones
+			wantFresh := models.ImportRowCreated
+			if onConflict == models.OnConflictFail {
+				wantFresh = models.ImportRowNotImported
+			}
+			if statuses[fresh] != wantFresh {
+				t.Errorf("new email is %q, want %q", statuses[fresh], wantFresh)
+			}
+
+			// Nothing was written
+			names := firstNames(t, orgID, existing, fresh)
+			if len(names) != 1 || names[existing] != "Existing" {
+				t.Errorf("users after a dry run: %v", names)
+			}
+			if tokens, mails := countVerification(t, orgID, fresh); tokens != 0 || mails != 0 {
+				t.Errorf("dry run issued %d tokens and queued %d mails", tokens, mails)
+			}
+		})
+	}
+}
+
+func TestImport_ConflictModes(t *testing.T) {
+	s := newServices(t)
+
+	tests := []struct {
+		onConflict    string
+		wantExisting  string // status of the row of the registered email
+		wantNew       string // status of the row of the new email
+		wantName      string // first name of the registered user afterwards
+		wantNewExists bool
+		wantFailed    bool // the whole import failed
+	}{
+		{models.OnConflictSkip, models.ImportRowSkipped, models.ImportRowCreated, "Existing", true, false},
+		{models.OnConflictUpdate, models.ImportRowUpdated, models.ImportRowCreated, "New", true, false},
+		{models.OnConflictFail, models.ImportRowFailed, models.ImportRowNotImported, "Existing", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.onConflict, func(t *testing.T) {
+			orgID, ctx := newOrg(t)
+			emails := newEmails(t, 2)
+			existing, fresh := emails[0], emails[1]
+			createUserWithEmail(t, ctx, s, existing)
+			existingTokens, existingMails := countVerification(t, orgID, existing)
+
+			report, _, err := s.imports.ImportUsers(ctx, models.ImportUsersQuery{Format: "csv", OnConflict: tt.onConflict}, importFile("New", existing, fresh))
+			if err != nil {
+				t.Fatalf("import: %v", err)
+			}
+			if failed := report.Status == models.ImportJobFailed; failed != tt.wantFailed {
+				t.Errorf("report status is %s (%s)", report.Status, report.Error)
+			}
+
+			statuses := rowStatuses(report.Rows)
+			if statuses[existing] != tt.wantExisting || statuses[fresh] != tt.wantNew {
+				t.Errorf("row statuses are %v, want %s and %s", statuses, tt.wantExisting, tt.wantNew)
+			}
+
+			names := firstNames(t, orgID, existing, fresh)
+			if names[existing] != tt.wantName {
+				t.Errorf("registered user is named %q, want %q", names[existing], tt.wantName)
+			}
+			if _, ok := names[fresh]; ok != tt.wantNewExists {
+				t.Errorf("new user exists: %v, want %v", ok, tt.wantNewExists)
+			}
+
+			// A created user gets a token and its mail in the import's transaction, an updated one nothing new
+			wantVerification := 0
+			if tt.wantNewExists {
+				wantVerification = 1
+			}
+			if tokens, mails := countVerification(t, orgID, fresh); tokens != wantVerification || mails != wantVerification {
+				t.Errorf("new user has %d tokens and %d queued mails, want %d of each", tokens, mails, wantVerification)
+			}
+			if tokens, mails := countVerification(t, orgID, existing); tokens != existingTokens || mails != existingMails {
+				t.Errorf("registered user has %d tokens and %d queued mails, want %d and %d", tokens, mails, existingTokens, existingMails)
+			}
+		})
+	}
+}
+
+// recordingMailer keeps the messages it is asked to send
+type recordingMailer struct {
+	mu       sync.Mutex
+	messages []mail.Message
+}
+
+func (m *recordingMailer) Send(ctx context.Context, msg mail.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// sentTo returns the messages to the address
+func (m *recordingMailer) sentTo(address string) []mail.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var messages []mail.Message
+	for _, msg := range m.messages {
+		if msg.To == address {
+			messages = append(messages, msg)
+		}
+	}
+	return messages
+}
+
+var tokenPattern = regexp.MustCompile(`token=([A-Za-z0-9_-]+)`)
+
+func TestImport_VerificationMailsGoThroughTheOutbox(t *testing.T) {
+	s := newServices(t)
+	orgID, ctx := newOrg(t)
+	emails := newEmails(t, 2)
+
+	report, _, err := s.imports.ImportUsers(ctx, models.ImportUsersQuery{Format: "csv"}, importFile("Mailed", emails...))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if report.Created != 2 {
+		t.Fatalf("created %d users, want 2", report.Created)
+	}
+
+	mailer := &recordingMailer{}
+	relay := service.NewMailRelay(db.jobsPool, db.jobsQueries, mailer, service.MailRelayOptions{
+		BatchSize:   1000,
+		MaxAttempts: 3,
+		BackoffBase: time.Second,
+		BackoffMax:  time.Minute,
+	})
+	for {
+		sent, err := relay.SendPending(context.Background())
+		if err != nil {
+			t.Fatalf("send pending mails: %v", err)
+		}
+		if sent < 1000 {
+			break
+		}
+	}
+
+	for _, email := range emails {
+		messages := mailer.sentTo(email)
+		if len(messages) != 1 {
+			t.Fatalf("%s got %d mails, want 1", email, len(messages))
+		}
+
+		// The link of the mail verifies the address
+		match := tokenPattern.FindStringSubmatch(messages[0].Text)
+		if match == nil {
+			t.Fatalf("mail has no token: %s", messages[0].Text)
+		}
+		if _, err := s.verification.VerifyEmail(context.Background(), models.VerifyEmailRequest{Token: match[1]}); err != nil {
+			t.Errorf("verify %s: %v", email, err)
+		}
+	}
+
+	// Sent mails leave the outbox, the tokens in them aren't kept around
+	if _, mails := countVerification(t, orgID, emails...); mails != 0 {
+		t.Errorf("%d mails are still queued", mails)
+	}
+}
+
+func TestImportJob_ResumesAfterLeaseExpires(t *testing.T) {
+	s := newServices(t)
+	orgID, ctx := newOrg(t)
+
+	// Two rows per batch - the worker stops in the second one
+	worker := service.NewImportService(db.jobsPool, db.jobsQueries, service.TxOptions{}, validator.NewValidator(), s.verification, service.ImportOptions{
+		BatchSize:     2,
+		SyncMaxRows:   100,
+		MaxRows:       1000,
+		LeaseDuration: time.Minute,
+	})
+	// Jobs left by other tests would be claimed first
+	for {
+		processed, err := worker.ProcessNextJob(context.Background())
+		if err != nil {
+			t.Fatalf("process import job: %v", err)
+		}
+		if !processed {
+			break
+		}
+	}
+
+	emails := newEmails(t, 5)
+	createUserWithEmail(t, ctx, s, emails[2])
+
+	_, job, err := s.imports.ImportUsers(ctx, models.ImportUsersQuery{Format: "csv", OnConflict: models.OnConflictUpdate, Async: true}, importFile("New", emails...))
+	if err != nil {
+		t.Fatalf("queue import: %v", err)
+	}
+
+	// Holding the lock of the third row's user blocks the second batch after the first one committed
+	lock, err := db.admin.Begin(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Rollback(context.Background())
+	if _, err := lock.Exec(context.Background(), "SELECT 1 FROM users WHERE org_id = $1 AND email = $2 FOR UPDATE", orgID, emails[2]); err != nil {
+		t.Fatal(err)
+	}
+
+	workerCtx, crash := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := worker.ProcessNextJob(workerCtx)
+		done <- err
+	}()
+
+	processedRows := func() int {
+		var processed int
+		if err := db.admin.QueryRow(context.Background(), "SELECT processed_rows FROM import_jobs WHERE job_id = $1", job.JobID).Scan(&processed); err != nil {
+			t.Fatal(err)
+		}
+		return processed
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for processedRows() < 2 {
+		if time.Now().After(deadline) {
+			crash()
+			t.Fatal("the first batch wasn't committed")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// The worker dies in the middle of the second batch
+	crash()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("worker returned %v, want context.Canceled", err)
+	}
+	if err := lock.Rollback(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The job keeps its lease, no other worker takes it over yet
+	processed, err := worker.ProcessNextJob(context.Background())
+	if err != nil {
+		t.Fatalf("process import job: %v", err)
+	}
+	if processed {
+		t.Fatal("a job with a running lease was taken over")
+	}
+
+	if _, err := db.admin.Exec(context.Background(), "UPDATE import_jobs SET lease_until = CURRENT_TIMESTAMP - interval '1 second' WHERE job_id = $1", job.JobID); err != nil {
+		t.Fatal(err)
+	}
+	processed, err = worker.ProcessNextJob(context.Background())
+	if err != nil {
+		t.Fatalf("process import job: %v", err)
+	}
+	if !processed {
+		t.Fatal("the job with an expired lease wasn't taken over")
+	}
+
+	job, err = s.imports.GetImportJob(ctx, job.JobID.String(), models.GetImportJobQuery{Limit: 100})
+	if err != nil {
+		t.Fatalf("get import job: %v", err)
+	}
+	if job.Status != models.ImportJobCompleted || job.Processed != 5 || job.Created != 4 || job.Updated != 1 {
+		t.Errorf("job is %s with %d processed, %d created, %d updated, want completed with 5, 4 and 1",
+			job.Status, job.Processed, job.Created, job.Updated)
+	}
+
+	// Every row is reported once, the first batch wasn't run again
+	if len(job.Rows) != 5 {
+		t.Errorf("job reports %d rows, want 5", len(job.Rows))
+	}
+	lines := make(map[int]bool)
+	for _, row := range job.Rows {
+		if lines[row.Line] {
+			t.Errorf("line %d is reported twice", row.Line)
+		}
+		lines[row.Line] = true
+	}
+
+	names := firstNames(t, orgID, emails...)
+	if len(names) != 5 {
+		t.Errorf("%d of the 5 users exist", len(names))
+	}
+	for email, name := range names {
+		if name != "New" {
+			t.Errorf("%s is named %q, want New", email, name)
+		}
+	}
+	imported := []string{emails[0], emails[1], emails[3], emails[4]}
+	if tokens, mails := countVerification(t, orgID, imported...); tokens != 4 || mails != 4 {
+		t.Errorf("the imported users have %d tokens and %d queued mails, want 4 of each", tokens, mails)
+	}
+}
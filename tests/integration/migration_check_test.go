@@ -0,0 +1,53 @@
+package integration
+
+import (
+	"context"
+	"testing"
+
+	migrations "user-management-api/db"
+	"user-management-api/internal/health"
+)
+
+func TestMigrationCheck(t *testing.T) {
+	ctx := context.Background()
+	// Same table golang-migrate keeps, the test schema is migrated without it
+	if _, err := db.admin.Exec(ctx, "CREATE TABLE schema_migrations (version BIGINT NOT NULL PRIMARY KEY, dirty BOOLEAN NOT NULL)"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.admin.Exec(context.Background(), "DROP TABLE schema_migrations") })
+
+	expected, err := migrations.SchemaVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := health.MigrationCheck(db.admin, expected)
+	if err := check(ctx); err == nil {
+		t.Error("check passes without any migration applied")
+	}
+
+	tests := []struct {
+		name    string
+		version int64
+		dirty   bool
+		wantErr bool
+	}{
+		{"expected version", expected, false, false},
+		{"newer version", expected + 1, false, false}, // a deployment migrated ahead of this release
+		{"older version", expected - 1, false, true},
+		{"dirty", expected, true, true},
+		{"newer version dirty", expected + 1, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := db.admin.Exec(ctx, "TRUNCATE schema_migrations"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := db.admin.Exec(ctx, "INSERT INTO schema_migrations VALUES ($1, $2)", tt.version, tt.dirty); err != nil {
+				t.Fatal(err)
+			}
+			if err := check(ctx); (err != nil) != tt.wantErr {
+				t.Errorf("check returned %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"user-management-api/internal/events"
+	"user-management-api/internal/models"
+	"user-management-api/internal/service"
+
+	"github.com/google/uuid"
+)
+
+// outboxEventTypes returns the types of the outbox events of a user, in publish order
+func outboxEventTypes(t *testing.T, userID uuid.UUID) []string {
+	t.Helper()
+	rows, err := db.admin.Query(context.Background(),
+		"SELECT event_type FROM outbox_events WHERE aggregate_id = $1 ORDER BY sequence_num", userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var types []string
+	for rows.Next() {
+		var eventType string
+		if err := rows.Scan(&eventType); err != nil {
+			t.Fatal(err)
+		}
+		types = append(types, eventType)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return types
+}
+
+func TestOutbox_OneEventPerChange(t *testing.T) {
+	s := newServices(t)
+	_, ctx := newOrg(t)
+
+	user := createUser(t, ctx, s, "Outboxed")
+	id := user.UserID.String()
+	want := []string{events.TypeUserCreated}
+
+	name, inactive, active := "Renamed", models.UserStatusInactive, models.UserStatusActive
+	steps := []struct {
+		name   string
+		change func() error
+		event  string // "" when nothing may be enqueued
+	}{
+		{"update", func() error {
+			_, err := s.users.UpdateUser(ctx, id, models.UpdateUserRequest{FirstName: &name}, nil)
+			return err
+		}, events.TypeUserUpdated},
+		{"update without changes", func() error {
+			_, err := s.users.UpdateUser(ctx, id, models.UpdateUserRequest{FirstName: &name}, nil)
+			return err
+		}, ""},
+		{"status change", func() error {
+			_, err := s.users.UpdateUser(ctx, id, models.UpdateUserRequest{Status: &inactive}, nil)
+			return err
+		}, events.TypeUserStatusChanged},
+		{"status change with other fields", func() error {
+			lastName := "Reactivated"
+			_, err := s.users.UpdateUser(ctx, id, models.UpdateUserRequest{Status: &active, LastName: &lastName}, nil)
+			return err
+		}, events.TypeUserStatusChanged},
+		{"delete", func() error {
+			return s.users.DeleteUser(ctx, id, nil)
+		}, events.TypeUserDeleted},
+		{"restore", func() error {
+			_, err := s.users.RestoreUser(ctx, id)
+			return err
+		}, events.TypeUserRestored},
+	}
+
+	if got := outboxEventTypes(t, user.UserID); !slices.Equal(got, want) {
+		t.Fatalf("after create the outbox has %v, want %v", got, want)
+	}
+	for _, step := range steps {
+		if err := step.change(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if step.event != "" {
+			want = append(want, step.event)
+		}
+		if got := outboxEventTypes(t, user.UserID); !slices.Equal(got, want) {
+			t.Fatalf("after %s the outbox has %v, want %v", step.name, got, want)
+		}
+	}
+}
+
+// flakyPublisher fails the first failures events of one user
+type flakyPublisher struct {
+	*events.MemoryPublisher
+	userID   uuid.UUID
+	failures int
+}
+
+func (p *flakyPublisher) Publish(ctx context.Context, event events.Event) error {
+	if event.AggregateID == p.userID && p.failures > 0 {
+		p.failures--
+		return errors.New("broker unavailable")
+	}
+	return p.MemoryPublisher.Publish(ctx, event)
+}
+
+func TestOutboxRelay_PublishesInOrderAndRetries(t *testing.T) {
+	ctx := context.Background()
+	s := newServices(t)
+	_, ctxA := newOrg(t)
+
+	// Only the events of this test are pending
+	if _, err := db.admin.Exec(ctx, "UPDATE outbox_events SET published_at = now() WHERE published_at IS NULL"); err != nil {
+		t.Fatal(err)
+	}
+
+	flaky := createUser(t, ctxA, s, "Flaky")
+	other := createUser(t, ctxA, s, "Steady")
+	name, inactive := "Renamed", models.UserStatusInactive
+	if _, err := s.users.UpdateUser(ctxA, flaky.UserID.String(), models.UpdateUserRequest{FirstName: &name}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.users.UpdateUser(ctxA, flaky.UserID.String(), models.UpdateUserRequest{Status: &inactive}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	publisher := &flakyPublisher{MemoryPublisher: events.NewMemoryPublisher(), userID: flaky.UserID, failures: 1}
+	relay := service.NewOutboxRelay(db.jobsPool, db.jobsQueries, publisher, 100)
+
+	// The first event of the flaky user fails, its later events wait so they aren't published out of order,
+	// the events of other users go out
+	published, err := relay.PublishPending(ctx)
+	if err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if published != 1 {
+		t.Errorf("first run published %d events, want 1", published)
+	}
+	var attempts int
+	var lastError *string
+	err = db.admin.QueryRow(ctx, "SELECT attempts, last_error FROM outbox_events WHERE aggregate_id = $1 AND event_type = $2",
+		flaky.UserID, events.TypeUserCreated).Scan(&attempts, &lastError)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 1 || lastError == nil {
+		t.Errorf("failed event has %d attempts and error %v, want 1 attempt with the error", attempts, lastError)
+	}
+
+	// The next run retries the failed event and publishes the rest behind it
+	published, err = relay.PublishPending(ctx)
+	if err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if published != 3 {
+		t.Errorf("second run published %d events, want 3", published)
+	}
+	published, err = relay.PublishPending(ctx)
+	if err != nil || published != 0 {
+		t.Errorf("third run published %d events (%v), want none left", published, err)
+	}
+
+	perUser := make(map[uuid.UUID][]string)
+	for _, event := range publisher.Events() {
+		perUser[event.AggregateID] = append(perUser[event.AggregateID], event.Type)
+	}
+	want := []string{events.TypeUserCreated, events.TypeUserUpdated, events.TypeUserStatusChanged}
+	if got := perUser[flaky.UserID]; !slices.Equal(got, want) {
+		t.Errorf("events of the flaky user were published as %v, want %v", got, want)
+	}
+	if got := perUser[other.UserID]; !slices.Equal(got, []string{events.TypeUserCreated}) {
+		t.Errorf("events of the other user were published as %v, want one UserCreated", got)
+	}
+}
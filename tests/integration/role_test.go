@@ -0,0 +1,61 @@
+package integration
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"user-management-api/internal/auth"
+
+	"github.com/google/uuid"
+)
+
+// grantRole assigns a role directly, without the checks of the role service
+func grantRole(t *testing.T, userID uuid.UUID, role string) {
+	t.Helper()
+	_, err := db.admin.Exec(context.Background(), "INSERT INTO user_roles (user_id, role_name) VALUES ($1, $2)", userID, role)
+	if err != nil {
+		t.Fatalf("grant %s: %v", role, err)
+	}
+}
+
+func TestRemoveRole(t *testing.T) {
+	s := newServices(t)
+	orgID, ctx := newOrg(t)
+
+	// An admin of the organization, with every permission of the admin role but not orgs:manage
+	ctx = auth.WithPrincipal(ctx, &auth.Principal{
+		Subject: "admin@" + orgID.String(),
+		OrgID:   orgID.String(),
+		Roles:   []string{"admin"},
+		Permissions: []string{
+			auth.PermUsersRead, auth.PermUsersWrite, auth.PermUsersDelete, auth.PermRolesAssign,
+			auth.PermAuditRead, auth.PermWebhooksManage, auth.PermGroupsManage,
+		},
+	})
+
+	first := createUser(t, ctx, s, "First")
+	second := createUser(t, ctx, s, "Second")
+	grantRole(t, first.UserID, "admin")
+	grantRole(t, second.UserID, "admin")
+	grantRole(t, second.UserID, "platform_admin")
+
+	// platform_admin grants orgs:manage, which the caller doesn't have
+	_, err := s.roles.RemoveRole(ctx, second.UserID.String(), "platform_admin")
+	wantStatus(t, err, http.StatusForbidden)
+
+	if _, err := s.roles.RemoveRole(ctx, first.UserID.String(), "admin"); err != nil {
+		t.Fatalf("remove the admin role of one of two admins: %v", err)
+	}
+
+	_, err = s.roles.RemoveRole(ctx, second.UserID.String(), "admin")
+	wantStatus(t, err, http.StatusConflict)
+
+	roles, err := s.roles.GetUserRoles(ctx, second.UserID.String())
+	if err != nil {
+		t.Fatalf("get roles: %v", err)
+	}
+	if len(roles.Roles) != 2 {
+		t.Errorf("the last admin has roles %v, want admin and platform_admin", roles.Roles)
+	}
+}
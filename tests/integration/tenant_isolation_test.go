@@ -0,0 +1,425 @@
+package integration
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"user-management-api/internal/auth"
+	"user-management-api/internal/mail"
+	"user-management-api/internal/models"
+	"user-management-api/internal/service"
+	"user-management-api/internal/tenant"
+	"user-management-api/internal/validator"
+	"user-management-api/internal/webhooks"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// services are wired like cmd/api: the API ones on the pool with row-level security, the jobs on the BYPASSRLS one
+type services struct {
+	users        *service.UserService
+	verification *service.VerificationService
+	roles        *service.RoleService
+	audit        *service.AuditService
+	webhooks     *service.WebhookService
+	groups       *service.GroupService
+	imports      *service.ImportService
+
+	importWorker *service.ImportService
+}
+
+func newServices(t *testing.T) *services {
+	t.Helper()
+
+	templates, err := mail.LoadTemplates("")
+	if err != nil {
+		t.Fatalf("load mail templates: %v", err)
+	}
+	verification := service.NewVerificationService(db.pool, db.queries, service.TxOptions{}, mail.NewLogMailer(), templates, service.VerificationOptions{
+		URL: "http://localhost/verify-email",
+		TTL: time.Hour,
+	})
+	importOptions := service.ImportOptions{BatchSize: 100, SyncMaxRows: 100, MaxRows: 1000, LeaseDuration: time.Minute}
+
+	return &services{
+		users:        service.NewUserService(db.pool, db.queries, service.TxOptions{}, verification, nil),
+		verification: verification,
+		roles:        service.NewRoleService(db.pool, db.queries, service.TxOptions{}),
+		audit:        service.NewAuditService(db.queries),
+		webhooks: service.NewWebhookService(db.queries, webhooks.NewSender(time.Second, false), service.WebhookOptions{
+			MaxAttempts: 3,
+			BackoffBase: time.Second,
+			BackoffMax:  time.Minute,
+			BatchSize:   10,
+			Timeout:     time.Second,
+		}),
+		groups:       service.NewGroupService(db.pool, db.queries, service.TxOptions{}),
+		imports:      service.NewImportService(db.pool, db.queries, service.TxOptions{}, validator.NewValidator(), verification, importOptions),
+		importWorker: service.NewImportService(db.jobsPool, db.jobsQueries, service.TxOptions{}, validator.NewValidator(), verification, importOptions),
+	}
+}
+
+// newOrg adds an organization and returns the context of an admin of it
+func newOrg(t *testing.T) (uuid.UUID, context.Context) {
+	t.Helper()
+
+	slug := "org-" + randomHex(t)
+	var orgID uuid.UUID
+	err := db.admin.QueryRow(context.Background(),
+		"INSERT INTO organizations (slug, name) VALUES ($1, $1) RETURNING org_id", slug).Scan(&orgID)
+	if err != nil {
+		t.Fatalf("create organization: %v", err)
+	}
+
+	ctx := tenant.WithOrgID(context.Background(), orgID)
+	ctx = auth.WithPrincipal(ctx, &auth.Principal{
+		Subject: "admin@" + slug,
+		OrgID:   orgID.String(),
+		Roles:   []string{"admin"},
+		Permissions: []string{
+			auth.PermUsersRead, auth.PermUsersWrite, auth.PermUsersDelete, auth.PermAuditRead,
+			auth.PermWebhooksManage, auth.PermGroupsManage,
+		},
+	})
+	return orgID, ctx
+}
+
+func randomHex(t *testing.T) string {
+	t.Helper()
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func createUser(t *testing.T, ctx context.Context, s *services, lastName string) *models.UserResponse {
+	t.Helper()
+	user, err := s.users.CreateUser(ctx, models.CreateUserRequest{
+		FirstName: "Isolated",
+		LastName:  lastName,
+		Email:     randomHex(t) + "@example.com",
+	})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+// wantStatus fails unless err is an AppError with the given status
+func wantStatus(t *testing.T, err error, status int) {
+	t.Helper()
+	var appErr *models.AppError
+	if !errors.As(err, &appErr) || appErr.StatusCode != status {
+		t.Errorf("got error %v, want status %d", err, status)
+	}
+}
+
+func TestTenantIsolation_Users(t *testing.T) {
+	s := newServices(t)
+	_, ctxA := newOrg(t)
+	_, ctxB := newOrg(t)
+
+	lastName := "Tenant" + randomHex(t)
+	userA := createUser(t, ctxA, s, lastName)
+	id := userA.UserID.String()
+
+	_, err := s.users.GetUserByID(ctxB, id)
+	wantStatus(t, err, http.StatusNotFound)
+
+	name := "Changed"
+	_, err = s.users.UpdateUser(ctxB, id, models.UpdateUserRequest{FirstName: &name}, nil)
+	wantStatus(t, err, http.StatusNotFound)
+
+	err = s.users.DeleteUser(ctxB, id, nil)
+	wantStatus(t, err, http.StatusNotFound)
+
+	list, err := s.users.ListUsers(ctxB, models.ListUsersQuery{Limit: models.MaxListLimit, UserFilters: models.UserFilters{IncludeDeleted: true}})
+	if err != nil {
+		t.Fatalf("list users: %v", err)
+	}
+	if list.Total != 0 || len(list.Users) != 0 {
+		t.Errorf("organization B lists %d users, want none", list.Total)
+	}
+
+	search, err := s.users.SearchUsers(ctxB, models.SearchUsersQuery{Q: lastName, Limit: models.MaxListLimit})
+	if err != nil {
+		t.Fatalf("search users: %v", err)
+	}
+	if len(search.Results) != 0 {
+		t.Errorf("organization B finds %d users, want none", len(search.Results))
+	}
+
+	// A deleted user of A can't be restored from B either
+	if err := s.users.DeleteUser(ctxA, id, nil); err != nil {
+		t.Fatalf("delete user: %v", err)
+	}
+	_, err = s.users.RestoreUser(ctxB, id)
+	wantStatus(t, err, http.StatusNotFound)
+
+	// Nothing B tried changed the user
+	restored, err := s.users.RestoreUser(ctxA, id)
+	if err != nil {
+		t.Fatalf("restore user: %v", err)
+	}
+	if restored.FirstName != userA.FirstName {
+		t.Errorf("first name is %q, want %q", restored.FirstName, userA.FirstName)
+	}
+}
+
+func TestTenantIsolation_Audit(t *testing.T) {
+	s := newServices(t)
+	_, ctxA := newOrg(t)
+	_, ctxB := newOrg(t)
+
+	userA := createUser(t, ctxA, s, "Audited")
+
+	events, err := s.audit.ListEvents(ctxA, models.ListAuditEventsQuery{Limit: 100, UserID: &userA.UserID})
+	if err != nil {
+		t.Fatalf("list audit events: %v", err)
+	}
+	if len(events.Events) == 0 {
+		t.Fatal("organization A has no audit event of its user")
+	}
+
+	events, err = s.audit.ListEvents(ctxB, models.ListAuditEventsQuery{Limit: 100, UserID: &userA.UserID})
+	if err != nil {
+		t.Fatalf("list audit events: %v", err)
+	}
+	if len(events.Events) != 0 {
+		t.Errorf("organization B sees %d audit events of a user of A", len(events.Events))
+	}
+
+	events, err = s.audit.ListEvents(ctxB, models.ListAuditEventsQuery{Limit: 100})
+	if err != nil {
+		t.Fatalf("list audit events: %v", err)
+	}
+	if len(events.Events) != 0 {
+		t.Errorf("organization B sees %d audit events, want none", len(events.Events))
+	}
+}
+
+func TestTenantIsolation_Webhooks(t *testing.T) {
+	s := newServices(t)
+	_, ctxA := newOrg(t)
+	_, ctxB := newOrg(t)
+
+	hookA, err := s.webhooks.CreateWebhook(ctxA, models.CreateWebhookRequest{URL: "https://a.example.com/hook"})
+	if err != nil {
+		t.Fatalf("create webhook: %v", err)
+	}
+	hookB, err := s.webhooks.CreateWebhook(ctxB, models.CreateWebhookRequest{URL: "https://b.example.com/hook"})
+	if err != nil {
+		t.Fatalf("create webhook: %v", err)
+	}
+	idA := hookA.ID.String()
+
+	// An event of A only fans out to the subscriptions of A
+	createUser(t, ctxA, s, "Hooked")
+	deliveries, err := s.webhooks.ListDeliveries(ctxA, idA, models.ListWebhookDeliveriesQuery{Limit: 100})
+	if err != nil {
+		t.Fatalf("list deliveries: %v", err)
+	}
+	if len(deliveries.Deliveries) != 1 {
+		t.Errorf("webhook of A has %d deliveries, want 1", len(deliveries.Deliveries))
+	}
+	deliveries, err = s.webhooks.ListDeliveries(ctxB, hookB.ID.String(), models.ListWebhookDeliveriesQuery{Limit: 100})
+	if err != nil {
+		t.Fatalf("list deliveries: %v", err)
+	}
+	if len(deliveries.Deliveries) != 0 {
+		t.Errorf("webhook of B got %d deliveries of an event of A", len(deliveries.Deliveries))
+	}
+
+	_, err = s.webhooks.GetWebhook(ctxB, idA)
+	wantStatus(t, err, http.StatusNotFound)
+
+	url := "https://b.example.com/stolen"
+	_, err = s.webhooks.UpdateWebhook(ctxB, idA, models.UpdateWebhookRequest{URL: &url})
+	wantStatus(t, err, http.StatusNotFound)
+
+	_, err = s.webhooks.ListDeliveries(ctxB, idA, models.ListWebhookDeliveriesQuery{Limit: 100})
+	wantStatus(t, err, http.StatusNotFound)
+
+	err = s.webhooks.DeleteWebhook(ctxB, idA)
+	wantStatus(t, err, http.StatusNotFound)
+
+	list, err := s.webhooks.ListWebhooks(ctxB)
+	if err != nil {
+		t.Fatalf("list webhooks: %v", err)
+	}
+	if len(list.Webhooks) != 1 || list.Webhooks[0].ID != hookB.ID {
+		t.Errorf("organization B lists %v, want only its own webhook", list.Webhooks)
+	}
+
+	got, err := s.webhooks.GetWebhook(ctxA, idA)
+	if err != nil {
+		t.Fatalf("get webhook: %v", err)
+	}
+	if got.URL != hookA.URL {
+		t.Errorf("webhook URL is %q, want %q", got.URL, hookA.URL)
+	}
+}
+
+func TestTenantIsolation_Groups(t *testing.T) {
+	s := newServices(t)
+	_, ctxA := newOrg(t)
+	_, ctxB := newOrg(t)
+
+	userA := createUser(t, ctxA, s, "Grouped")
+	groupA, err := s.groups.CreateGroup(ctxA, models.CreateGroupRequest{Name: "Engineering"})
+	if err != nil {
+		t.Fatalf("create group: %v", err)
+	}
+	groupB, err := s.groups.CreateGroup(ctxB, models.CreateGroupRequest{Name: "Engineering"})
+	if err != nil {
+		t.Fatalf("create group with the name of a group of another organization: %v", err)
+	}
+
+	_, err = s.groups.GetGroup(ctxB, groupA.ID.String())
+	wantStatus(t, err, http.StatusNotFound)
+
+	_, err = s.groups.ListMembers(ctxB, groupA.ID.String(), models.ListGroupMembersQuery{Limit: 100})
+	wantStatus(t, err, http.StatusNotFound)
+
+	_, err = s.groups.AddMembers(ctxB, groupA.ID.String(), models.AddGroupMembersRequest{
+		Members: []models.GroupMemberInput{{UserID: userA.UserID}},
+	})
+	wantStatus(t, err, http.StatusNotFound)
+
+	// Users of A can't be added to a group of B
+	change, err := s.groups.AddMembers(ctxB, groupB.ID.String(), models.AddGroupMembersRequest{
+		Members: []models.GroupMemberInput{{UserID: userA.UserID}},
+	})
+	if err != nil {
+		t.Fatalf("add members: %v", err)
+	}
+	if len(change.Added) != 0 || len(change.NotFound) != 1 || change.NotFound[0] != userA.UserID {
+		t.Errorf("adding a user of A to a group of B gave added %v, not found %v", change.Added, change.NotFound)
+	}
+
+	err = s.groups.DeleteGroup(ctxB, groupA.ID.String())
+	wantStatus(t, err, http.StatusNotFound)
+
+	groups, err := s.groups.ListGroups(ctxB)
+	if err != nil {
+		t.Fatalf("list groups: %v", err)
+	}
+	if len(groups.Groups) != 1 || groups.Groups[0].ID != groupB.ID {
+		t.Errorf("organization B lists %v, want only its own group", groups.Groups)
+	}
+}
+
+func TestTenantIsolation_ImportJobs(t *testing.T) {
+	s := newServices(t)
+	orgA, ctxA := newOrg(t)
+	_, ctxB := newOrg(t)
+
+	file := "first_name,last_name,email\nImported,Person," + randomHex(t) + "@example.com\n"
+	_, job, err := s.imports.ImportUsers(ctxA, models.ImportUsersQuery{Format: "csv", Async: true}, []byte(file))
+	if err != nil {
+		t.Fatalf("queue import: %v", err)
+	}
+	if job == nil {
+		t.Fatal("async import returned no job")
+	}
+	jobID := job.JobID.String()
+
+	_, err = s.imports.GetImportJob(ctxB, jobID, models.GetImportJobQuery{Limit: 100})
+	wantStatus(t, err, http.StatusNotFound)
+
+	// The worker sees the jobs of every organization and imports into the one of the job
+	for {
+		processed, err := s.importWorker.ProcessNextJob(context.Background())
+		if err != nil {
+			t.Fatalf("process import job: %v", err)
+		}
+		if !processed {
+			break
+		}
+	}
+
+	job, err = s.imports.GetImportJob(ctxA, jobID, models.GetImportJobQuery{Limit: 100})
+	if err != nil {
+		t.Fatalf("get import job: %v", err)
+	}
+	if job.Status != models.ImportJobCompleted || job.Created != 1 {
+		t.Fatalf("import job is %s with %d created, want completed with 1", job.Status, job.Created)
+	}
+
+	list, err := s.users.ListUsers(ctxB, models.ListUsersQuery{Limit: models.MaxListLimit})
+	if err != nil {
+		t.Fatalf("list users: %v", err)
+	}
+	if list.Total != 0 {
+		t.Errorf("organization B lists %d users after an import of A", list.Total)
+	}
+	var imported int
+	if err := db.admin.QueryRow(context.Background(), "SELECT count(*) FROM users WHERE org_id = $1", orgA).Scan(&imported); err != nil {
+		t.Fatal(err)
+	}
+	if imported != 1 {
+		t.Errorf("organization A has %d users, want 1", imported)
+	}
+}
+
+// TestRowLevelSecurity checks the policies themselves, for queries that would forget the organization
+func TestRowLevelSecurity(t *testing.T) {
+	s := newServices(t)
+	orgA, ctxA := newOrg(t)
+	_, ctxB := newOrg(t)
+
+	createUser(t, ctxA, s, "Protected")
+	if _, err := s.webhooks.CreateWebhook(ctxA, models.CreateWebhookRequest{URL: "https://a.example.com/hook"}); err != nil {
+		t.Fatalf("create webhook: %v", err)
+	}
+	if _, err := s.groups.CreateGroup(ctxA, models.CreateGroupRequest{Name: "Protected"}); err != nil {
+		t.Fatalf("create group: %v", err)
+	}
+
+	for _, table := range []string{"users", "audit_events", "outbox_events", "webhook_subscriptions", "webhook_deliveries", "groups", "mail_outbox"} {
+		if n := countRows(t, ctxA, db.pool, table, orgA); n == 0 {
+			t.Errorf("%s: organization A sees none of its rows", table)
+		}
+		if n := countRows(t, ctxB, db.pool, table, orgA); n != 0 {
+			t.Errorf("%s: organization B sees %d rows of A", table, n)
+		}
+		// Fails closed - without an organization nothing is visible
+		if n := countRows(t, context.Background(), db.pool, table, orgA); n != 0 {
+			t.Errorf("%s: a connection without organization sees %d rows of A", table, n)
+		}
+		// The jobs connect as a role that bypasses the policies
+		if n := countRows(t, context.Background(), db.jobsPool, table, orgA); n == 0 {
+			t.Errorf("%s: the jobs see none of the rows of A", table)
+		}
+	}
+
+	// Writing rows into another organization is rejected too
+	_, err := db.pool.Exec(ctxB, "INSERT INTO groups (org_id, name) VALUES ($1, $2)", orgA, "Smuggled")
+	if err == nil {
+		t.Error("organization B inserted a group into A")
+	}
+	tag, err := db.pool.Exec(ctxB, "UPDATE users SET first_name = 'Changed' WHERE org_id = $1", orgA)
+	if err != nil {
+		t.Fatalf("update users: %v", err)
+	}
+	if tag.RowsAffected() != 0 {
+		t.Errorf("organization B changed %d users of A", tag.RowsAffected())
+	}
+}
+
+// countRows counts the rows of an organization in table that pool lets ctx see
+func countRows(t *testing.T, ctx context.Context, pool *pgxpool.Pool, table string, orgID uuid.UUID) int {
+	t.Helper()
+	var n int
+	if err := pool.QueryRow(ctx, "SELECT count(*) FROM "+table+" WHERE org_id = $1", orgID).Scan(&n); err != nil {
+		t.Fatalf("count %s: %v", table, err)
+	}
+	return n
+}
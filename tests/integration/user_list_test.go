@@ -0,0 +1,188 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"user-management-api/internal/export"
+	"user-management-api/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// createSameTimeUsers adds n users named "Paged" with the same created_at and updated_at, so only user_id
+// tells them apart when sorting by time. Every second one is Inactive.
+func createSameTimeUsers(t *testing.T, ctx context.Context, s *services, orgID uuid.UUID, n int) []uuid.UUID {
+	t.Helper()
+
+	ids := make([]uuid.UUID, n)
+	for i := range ids {
+		req := models.CreateUserRequest{
+			FirstName: "Paged",
+			LastName:  fmt.Sprintf("User%02d", i),
+			Email:     "paged-" + randomHex(t) + "@example.com",
+		}
+		if i%2 == 1 {
+			req.Status = models.UserStatusInactive
+		}
+		user, err := s.users.CreateUser(ctx, req)
+		if err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		ids[i] = user.UserID
+	}
+
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, err := db.admin.Exec(context.Background(),
+		"UPDATE users SET created_at = $2, updated_at = $2 WHERE org_id = $1", orgID, at)
+	if err != nil {
+		t.Fatalf("set timestamps: %v", err)
+	}
+	return ids
+}
+
+// listAll pages through the users with the smallest page size and returns their IDs in order
+func listAll(t *testing.T, ctx context.Context, s *services, query models.ListUsersQuery) []uuid.UUID {
+	t.Helper()
+
+	var ids []uuid.UUID
+	query.Limit = 2
+	for pages := 0; ; pages++ {
+		if pages > 50 {
+			t.Fatal("paging does not end")
+		}
+		page, err := s.users.ListUsers(ctx, query)
+		if err != nil {
+			t.Fatalf("list users: %v", err)
+		}
+		for _, user := range page.Users {
+			ids = append(ids, user.UserID)
+		}
+		if !page.HasMore {
+			return ids
+		}
+		query.Cursor = page.NextCursor
+	}
+}
+
+func TestListUsers_KeysetPagingVisitsEveryUserOnce(t *testing.T) {
+	s := newServices(t)
+	orgID, ctx := newOrg(t)
+	created := createSameTimeUsers(t, ctx, s, orgID, 7)
+
+	for _, sort := range []string{"", "created_at", "-updated_at", "first_name", "-last_name", "email"} {
+		t.Run("sort="+sort, func(t *testing.T) {
+			ids := listAll(t, ctx, s, models.ListUsersQuery{Sort: sort})
+
+			seen := make(map[uuid.UUID]bool)
+			for _, id := range ids {
+				if seen[id] {
+					t.Errorf("user %s is listed twice", id)
+				}
+				seen[id] = true
+			}
+			for _, id := range created {
+				if !seen[id] {
+					t.Errorf("user %s is missing", id)
+				}
+			}
+		})
+	}
+
+	// Last names are User00, User01, ... so -last_name lists the users in reverse creation order
+	ids := listAll(t, ctx, s, models.ListUsersQuery{Sort: "-last_name"})
+	for i, id := range ids {
+		if id != created[len(created)-1-i] {
+			t.Fatalf("-last_name lists %v, want the users in reverse creation order %v", ids, created)
+		}
+	}
+}
+
+func TestListUsers_Filters(t *testing.T) {
+	s := newServices(t)
+	orgID, ctx := newOrg(t)
+	created := createSameTimeUsers(t, ctx, s, orgID, 5)
+
+	if err := s.users.DeleteUser(ctx, created[0].String(), nil); err != nil {
+		t.Fatalf("delete user: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		filters models.UserFilters
+		want    int
+	}{
+		{name: "none", want: 4},
+		{name: "include deleted", filters: models.UserFilters{IncludeDeleted: true}, want: 5},
+		{name: "status", filters: models.UserFilters{Status: models.UserStatusInactive}, want: 2},
+		{name: "name", filters: models.UserFilters{Name: "paged user03"}, want: 1},
+		{name: "email", filters: models.UserFilters{Email: "PAGED-"}, want: 4},
+		{name: "wildcards are literal", filters: models.UserFilters{Name: "%"}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := s.users.ListUsers(ctx, models.ListUsersQuery{Limit: models.MaxListLimit, UserFilters: tt.filters})
+			if err != nil {
+				t.Fatalf("list users: %v", err)
+			}
+			if page.Total != tt.want || len(page.Users) != tt.want {
+				t.Errorf("got %d users of a total of %d, want %d", len(page.Users), page.Total, tt.want)
+			}
+		})
+	}
+}
+
+func TestExportUsers(t *testing.T) {
+	s := newServices(t)
+	orgID, ctx := newOrg(t)
+	created := createSameTimeUsers(t, ctx, s, orgID, 5)
+
+	userExport, err := s.users.ExportUsers(ctx, models.ExportUsersQuery{
+		Columns:     []string{"userId"},
+		UserFilters: models.UserFilters{Status: models.UserStatusActive},
+		Sort:        "last_name",
+	})
+	if err != nil {
+		t.Fatalf("export users: %v", err)
+	}
+	defer userExport.Close(context.Background())
+
+	var buf bytes.Buffer
+	w, err := export.NewWriter("csv", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, err := userExport.WriteTo(ctx, w)
+	if err != nil {
+		t.Fatalf("write export: %v", err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("read export: %v", err)
+	}
+	want := [][]string{{"userId"}, {created[0].String()}, {created[2].String()}, {created[4].String()}}
+	if count != 3 || !reflect.DeepEqual(records, want) {
+		t.Errorf("exported %d users %q, want %q", count, records, want)
+	}
+}
+
+func TestUsersTimestampsAreRequired(t *testing.T) {
+	orgID, _ := newOrg(t)
+	for _, column := range []string{"created_at", "updated_at"} {
+		_, err := db.admin.Exec(context.Background(), fmt.Sprintf(
+			"INSERT INTO users (org_id, first_name, last_name, email, %s) VALUES ($1, 'No', 'Time', $2, NULL)", column),
+			orgID, randomHex(t)+"@example.com")
+		var pgErr *pgconn.PgError
+		if !errors.As(err, &pgErr) || pgErr.Code != "23502" {
+			t.Errorf("inserting a user with %s NULL: got %v, want a not-null violation", column, err)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package integration
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"user-management-api/internal/models"
+	"user-management-api/internal/service"
+	"user-management-api/internal/webhooks"
+)
+
+// TestWebhookDelivery sends a delivery through the dispatcher: it is signed with the secret of the
+// subscription, retried after a failure and marked succeeded once the subscriber accepts it
+func TestWebhookDelivery(t *testing.T) {
+	ctx := context.Background()
+	s := newServices(t)
+	_, ctxA := newOrg(t)
+
+	// Only the delivery of this test may be due, the other tests subscribe URLs that must not be called
+	if _, err := db.admin.Exec(ctx, "UPDATE webhook_deliveries SET status = 'dead' WHERE status = 'pending'"); err != nil {
+		t.Fatal(err)
+	}
+
+	var secret string
+	var calls, verified atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if webhooks.Verify(secret, r.Header.Get(webhooks.HeaderTimestamp), r.Header.Get(webhooks.HeaderSignature), body, time.Minute) == nil {
+			verified.Add(1)
+		}
+		// The first attempt fails
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	hook, err := s.webhooks.CreateWebhook(ctxA, models.CreateWebhookRequest{URL: server.URL, EventTypes: []string{"UserCreated"}})
+	if err != nil {
+		t.Fatalf("create webhook: %v", err)
+	}
+	secret = hook.Secret
+	createUser(t, ctxA, s, "Delivered")
+
+	// The dispatcher runs on the jobs pool like in cmd/api, the httptest client reaches the loopback server
+	dispatcher := service.NewWebhookService(db.jobsQueries, webhooks.NewSenderWithClient(server.Client()), service.WebhookOptions{
+		MaxAttempts: 3,
+		BackoffBase: time.Hour,
+		BackoffMax:  time.Hour,
+		BatchSize:   10,
+		Timeout:     time.Second,
+	})
+
+	delivery := func() models.WebhookDeliveryResponse {
+		t.Helper()
+		list, err := s.webhooks.ListDeliveries(ctxA, hook.ID.String(), models.ListWebhookDeliveriesQuery{Limit: 10})
+		if err != nil {
+			t.Fatalf("list deliveries: %v", err)
+		}
+		if len(list.Deliveries) != 1 {
+			t.Fatalf("webhook has %d deliveries, want 1", len(list.Deliveries))
+		}
+		return list.Deliveries[0]
+	}
+
+	if n, err := dispatcher.DispatchDue(ctx); err != nil || n != 1 {
+		t.Fatalf("first dispatch attempted %d deliveries (%v), want 1", n, err)
+	}
+	first := delivery()
+	if first.Status != models.WebhookDeliveryPending || first.Attempts != 1 {
+		t.Errorf("after a failed attempt the delivery is %s with %d attempts, want pending with 1", first.Status, first.Attempts)
+	}
+	if first.LastStatusCode == nil || *first.LastStatusCode != http.StatusInternalServerError {
+		t.Errorf("last status code is %v, want 500", first.LastStatusCode)
+	}
+	if first.NextAttemptAt == nil || time.Until(*first.NextAttemptAt) < 29*time.Minute {
+		t.Errorf("retry is due at %v, want after the backoff", first.NextAttemptAt)
+	}
+
+	// Not due yet
+	if n, err := dispatcher.DispatchDue(ctx); err != nil || n != 0 {
+		t.Fatalf("dispatch before the backoff attempted %d deliveries (%v), want 0", n, err)
+	}
+
+	if _, err := db.admin.Exec(ctx, "UPDATE webhook_deliveries SET next_attempt_at = now() WHERE delivery_id = $1", first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := dispatcher.DispatchDue(ctx); err != nil || n != 1 {
+		t.Fatalf("retry attempted %d deliveries (%v), want 1", n, err)
+	}
+	second := delivery()
+	if second.Status != models.WebhookDeliverySucceeded || second.Attempts != 2 {
+		t.Errorf("after the retry the delivery is %s with %d attempts, want succeeded with 2", second.Status, second.Attempts)
+	}
+	if calls.Load() != 2 || verified.Load() != 2 {
+		t.Errorf("subscriber got %d requests, %d with a valid signature - want 2 and 2", calls.Load(), verified.Load())
+	}
+}